@@ -0,0 +1,47 @@
+// Package authctx records the authenticated caller of a request on its gin context, so the middleware that
+// authenticates and the handlers that scope data by caller share one place to read it from.
+package authctx
+
+import (
+	"simvizlab-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Context keys the authenticated user is stored under
+const (
+	userIDKey   = "authUserID"
+	userRoleKey = "authUserRole"
+)
+
+// SetUser records an authenticated user and their role
+func SetUser(ctx *gin.Context, userID primitive.ObjectID, role string) {
+	ctx.Set(userIDKey, userID)
+	ctx.Set(userRoleKey, role)
+}
+
+// SetRole records the role of a caller that is not a user, such as the holder of the admin key
+func SetRole(ctx *gin.Context, role string) {
+	ctx.Set(userRoleKey, role)
+}
+
+// UserID returns the authenticated user id, if any
+func UserID(ctx *gin.Context) (primitive.ObjectID, bool) {
+	value, ok := ctx.Get(userIDKey)
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	userID, ok := value.(primitive.ObjectID)
+	return userID, ok
+}
+
+// Role returns the authenticated role, empty when the request is not authenticated
+func Role(ctx *gin.Context) string {
+	return ctx.GetString(userRoleKey)
+}
+
+// HasPermission reports whether the authenticated role grants permission
+func HasPermission(ctx *gin.Context, permission models.Permission) bool {
+	return models.HasPermission(Role(ctx), permission)
+}
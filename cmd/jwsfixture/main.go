@@ -0,0 +1,103 @@
+// Command jwsfixture signs App Store transactions, renewal info and notifications from JSON templates
+// with a test certificate chain shaped like Apple's.
+//
+//	go run ./cmd/jwsfixture -chain fixtures.pem -root root.pem -out signed.json templates.json
+//
+// The chain and its signing key are read from -chain, or minted and written there when the file does not exist,
+// so fixtures signed across runs verify against the same root. Pointing APPSTORE_TRUSTED_ROOTS_FILE at the
+// -root file of a sandbox server makes it accept the signed payloads. See cmd/jwsfixture/testdata/templates.json
+// for the template format.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"simvizlab-backend/models/fixtures"
+)
+
+func main() {
+	chainFile := flag.String("chain", "fixtures.pem", "PEM file holding the certificate chain and signing key, created if missing")
+	rootFile := flag.String("root", "", "file to write the PEM root certificate to")
+	outFile := flag.String("out", "", "file to write the signed payloads to instead of stdout")
+	days := flag.Int("days", 365, "days a newly minted chain is valid for either side of today, bounding the signedDate of its payloads")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: jwsfixture [flags] templates.json\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	chain, err := loadChain(*chainFile, *days)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rootFile != "" {
+		if err := os.WriteFile(*rootFile, chain.RootPEM(), 0o644); err != nil {
+			log.Fatalf("failed to write root certificate: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to read templates: %v", err)
+	}
+	templates, err := fixtures.ParseTemplates(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signed, err := chain.SignTemplates(templates)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	out = append(out, '\n')
+	if *outFile == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*outFile, out, 0o644); err != nil {
+		log.Fatalf("failed to write signed payloads: %v", err)
+	}
+}
+
+// loadChain reads the chain in file, minting one valid for days either side of now and saving it there if the file does not exist.
+// Payloads are verified as of their signedDate, so a chain valid in the past lets templates backdate it.
+func loadChain(file string, days int) (*fixtures.Chain, error) {
+	data, err := os.ReadFile(file)
+	if err == nil {
+		return fixtures.ParseChainPEM(data)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read chain: %w", err)
+	}
+
+	now := time.Now()
+	chain, err := fixtures.NewChain(now.AddDate(0, 0, -days), now.AddDate(0, 0, days))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint chain: %w", err)
+	}
+	data, err = chain.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+	// the file holds the signing key
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write chain: %w", err)
+	}
+	log.Printf("minted a chain valid until %s into %s", now.AddDate(0, 0, days).Format(time.DateOnly), file)
+	return chain, nil
+}
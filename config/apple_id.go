@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"simvizlab-backend/models"
+)
+
+// AppleIDConfig builds the Sign in with Apple configuration from environment variables.
+// APPLE_SIGNIN_CLIENT_ID defaults to the app's bundle ID, the audience of identity tokens from native apps.
+func AppleIDConfig() *models.AppleIDConfig {
+	clientID := os.Getenv("APPLE_SIGNIN_CLIENT_ID")
+	if clientID == "" {
+		clientID = os.Getenv("APPSTORE_BUNDLE_ID")
+	}
+
+	// SetupConfig has already rejected an unusable key set file
+	keySource, _ := AppleIDKeySet()
+
+	cfg := &models.AppleIDConfig{
+		ClientID:    clientID,
+		TeamID:      os.Getenv("APPLE_TEAM_ID"),
+		KeyID:       os.Getenv("APPLE_SIGNIN_KEY_ID"),
+		KeyContent:  []byte(strings.ReplaceAll(os.Getenv("APPLE_SIGNIN_PRIVATE_KEY"), `\n`, "\n")),
+		RedirectURI: os.Getenv("APPLE_SIGNIN_REDIRECT_URI"),
+	}
+	// a nil *StaticKeySet must not become a non-nil interface
+	if keySource != nil {
+		cfg.KeySource = keySource
+	}
+	return cfg
+}
+
+// AppleIDKeySet reads the JSON Web Key Set in APPLE_SIGNIN_JWKS_FILE, trusted for identity tokens in place of
+// Apple's published keys so tests can sign in offline. It returns nil when the variable is unset, and is
+// refused outside the sandbox.
+func AppleIDKeySet() (*models.StaticKeySet, error) {
+	file := os.Getenv("APPLE_SIGNIN_JWKS_FILE")
+	if file == "" {
+		return nil, nil
+	}
+	if !AppStoreSandbox() {
+		return nil, errors.New("APPLE_SIGNIN_JWKS_FILE is only allowed with a sandbox BASE_URL")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPLE_SIGNIN_JWKS_FILE: %w", err)
+	}
+	keySet, err := models.NewStaticKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPLE_SIGNIN_JWKS_FILE: %w", err)
+	}
+	return keySet, nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+// AppStoreConfig builds the App Store Server API configuration from environment variables
+func AppStoreConfig() *models.StoreConfig {
+	// Replace escaped newlines with actual newlines
+	privateKey := strings.ReplaceAll(os.Getenv("APPSTORE_PRIVATE_KEY"), `\n`, "\n")
+
+	// SetupConfig has already rejected an unusable trusted roots file
+	trustedRoots, _ := AppStoreTrustedRoots()
+
+	return &models.StoreConfig{
+		KeyContent:      []byte(privateKey),
+		KeyID:           os.Getenv("APPSTORE_KEY_ID"),
+		BundleID:        os.Getenv("APPSTORE_BUNDLE_ID"),
+		Issuer:          os.Getenv("APPSTORE_ISSUER_ID"),
+		Sandbox:         AppStoreSandbox(),
+		TrustedCertPool: trustedRoots,
+	}
+}
+
+// AppStoreTrustedRoots reads the PEM root certificates in APPSTORE_TRUSTED_ROOTS_FILE, trusted for signed
+// App Store data in place of Apple Root CA - G3 so payloads minted by cmd/jwsfixture verify.
+// It returns nil when the variable is unset, and is refused outside the sandbox.
+func AppStoreTrustedRoots() (*x509.CertPool, error) {
+	file := os.Getenv("APPSTORE_TRUSTED_ROOTS_FILE")
+	if file == "" {
+		return nil, nil
+	}
+	if !AppStoreSandbox() {
+		return nil, errors.New("APPSTORE_TRUSTED_ROOTS_FILE is only allowed with a sandbox BASE_URL")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPSTORE_TRUSTED_ROOTS_FILE: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("invalid APPSTORE_TRUSTED_ROOTS_FILE: no PEM certificates in %s", file)
+	}
+	return pool, nil
+}
+
+// AppStoreSandbox reports whether BASE_URL points at the App Store sandbox host
+func AppStoreSandbox() bool {
+	return strings.Contains(os.Getenv("BASE_URL"), "sandbox")
+}
+
+// AppStoreSandboxFallback reports whether production lookups that find no transaction are retried on the sandbox host.
+// TestFlight and App Review purchases only exist in the sandbox.
+func AppStoreSandboxFallback() bool {
+	return os.Getenv("APPSTORE_SANDBOX_FALLBACK") == "true"
+}
+
+// NotificationBackfillDays is how many days of notification history the backfill job covers on its first run
+func NotificationBackfillDays() int {
+	days, err := strconv.Atoi(os.Getenv("APPSTORE_BACKFILL_DAYS"))
+	if err != nil || days <= 0 {
+		return 7
+	}
+	return days
+}
+
+// NotificationBackfillInterval is how often the notification backfill job runs
+func NotificationBackfillInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("APPSTORE_BACKFILL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Hour
+	}
+	return interval
+}
+
+// NotificationBackfillOnlyFailures limits the backfill job to notifications Apple failed to deliver
+func NotificationBackfillOnlyFailures() bool {
+	return os.Getenv("APPSTORE_BACKFILL_ONLY_FAILURES") == "true"
+}
+
+// NotificationMaxAttempts is how many times the backfill job tries to apply a stored notification before
+// leaving it failed for an operator to look at
+func NotificationMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("APPSTORE_NOTIFICATION_MAX_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		return 5
+	}
+	return attempts
+}
+
+// NotificationHealthCheckInterval is how often a TEST notification is sent to check notification delivery
+func NotificationHealthCheckInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("APPSTORE_HEALTH_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 6 * time.Hour
+	}
+	return interval
+}
+
+// ProductFeatures maps App Store productIds to the named features they unlock, read as a JSON object
+// such as {"com.example.pro.monthly": ["pro", "export"]} from ENTITLEMENT_PRODUCT_FEATURES
+func ProductFeatures() (map[string][]string, error) {
+	features := map[string][]string{}
+	raw := os.Getenv("ENTITLEMENT_PRODUCT_FEATURES")
+	if raw == "" {
+		return features, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &features); err != nil {
+		return nil, fmt.Errorf("invalid ENTITLEMENT_PRODUCT_FEATURES: %w", err)
+	}
+	return features, nil
+}
+
+// OfferSigningKey returns the subscription offer key used to sign promotional offers.
+// APPSTORE_OFFER_KEY_ID and APPSTORE_OFFER_PRIVATE_KEY default to the App Store Server API key.
+func OfferSigningKey() (keyID string, keyContent []byte) {
+	keyID = os.Getenv("APPSTORE_OFFER_KEY_ID")
+	privateKey := os.Getenv("APPSTORE_OFFER_PRIVATE_KEY")
+	if keyID == "" || privateKey == "" {
+		keyID = os.Getenv("APPSTORE_KEY_ID")
+		privateKey = os.Getenv("APPSTORE_PRIVATE_KEY")
+	}
+	return keyID, []byte(strings.ReplaceAll(privateKey, `\n`, "\n"))
+}
+
+// PromotionalOffer describes who may redeem a promotional offer configured in App Store Connect
+type PromotionalOffer struct {
+	ProductId                   string `json:"productId"`
+	SubscriptionGroupIdentifier string `json:"subscriptionGroupIdentifier"`
+	// LapsedOnly restricts the offer to customers without access in the group
+	LapsedOnly bool `json:"lapsedOnly"`
+}
+
+// PromotionalOffers maps offer identifiers to their eligibility rules, read as a JSON object such as
+// {"RETAIN50": {"productId": "com.example.pro.monthly", "subscriptionGroupIdentifier": "21000000", "lapsedOnly": true}}
+// from APPSTORE_PROMOTIONAL_OFFERS
+func PromotionalOffers() (map[string]PromotionalOffer, error) {
+	offers := map[string]PromotionalOffer{}
+	raw := os.Getenv("APPSTORE_PROMOTIONAL_OFFERS")
+	if raw == "" {
+		return offers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &offers); err != nil {
+		return nil, fmt.Errorf("invalid APPSTORE_PROMOTIONAL_OFFERS: %w", err)
+	}
+	return offers, nil
+}
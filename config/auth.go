@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// minAuthTokenSecretBytes is the shortest HS256 secret accepted, matching the hash size
+const minAuthTokenSecretBytes = 32
+
+// AuthTokenSecret is the key access tokens are signed with, read from AUTH_TOKEN_SECRET
+func AuthTokenSecret() ([]byte, error) {
+	secret := os.Getenv("AUTH_TOKEN_SECRET")
+	if len(secret) < minAuthTokenSecretBytes {
+		return nil, errors.New("AUTH_TOKEN_SECRET must be at least 32 bytes")
+	}
+	return []byte(secret), nil
+}
+
+// AccessTokenTTL is how long an access token is accepted, 15 minutes unless AUTH_ACCESS_TOKEN_TTL says otherwise
+func AccessTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("AUTH_ACCESS_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// RefreshTokenTTL is how long a refresh token can be exchanged, 30 days unless AUTH_REFRESH_TOKEN_TTL says otherwise
+func RefreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("AUTH_REFRESH_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
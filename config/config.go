@@ -27,5 +27,24 @@ func SetupConfig() error {
 		}
 	}
 
+	if _, err := ProductFeatures(); err != nil {
+		return err
+	}
+	if _, err := PromotionalOffers(); err != nil {
+		return err
+	}
+	if _, err := AuthTokenSecret(); err != nil {
+		return err
+	}
+	if _, err := AppStoreTrustedRoots(); err != nil {
+		return err
+	}
+	if _, err := AppleIDKeySet(); err != nil {
+		return err
+	}
+	if _, err := ReportingCurrency(); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ReportingCurrency is the ISO 4217 code revenue reports are normalized to, USD unless REPORTING_CURRENCY says otherwise
+func ReportingCurrency() (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(os.Getenv("REPORTING_CURRENCY")))
+	if currency == "" {
+		return "USD", nil
+	}
+	if len(currency) != 3 {
+		return "", fmt.Errorf("invalid REPORTING_CURRENCY: %q is not an ISO 4217 code", currency)
+	}
+	return currency, nil
+}
+
+// ExchangeRatesFile is the CSV file exchange rates are imported from. Empty leaves the exchangeRates collection as is.
+func ExchangeRatesFile() string {
+	return os.Getenv("EXCHANGE_RATES_FILE")
+}
+
+// TaxRatesFile is the CSV file storefront tax rates are imported from, refreshed with the exchange rates.
+// Empty leaves the taxRates collection as is.
+func TaxRatesFile() string {
+	return os.Getenv("TAX_RATES_FILE")
+}
+
+// ExchangeRatesRefreshInterval is how often the exchange rates file is re-imported and the rates reloaded
+func ExchangeRatesRefreshInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("EXCHANGE_RATES_REFRESH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 6 * time.Hour
+	}
+	return interval
+}
+
+// AppStoreSmallBusinessProgram tells whether the developer account is in the App Store Small Business Program,
+// which lowers Apple's commission on every sale
+func AppStoreSmallBusinessProgram() bool {
+	return os.Getenv("APPSTORE_SMALL_BUSINESS_PROGRAM") == "true"
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackfillAppAccountTokens sets appAccountTokens on purchases made before we issued them.
+// The optional limit query parameter caps how many users are processed in one call.
+func BackfillAppAccountTokens(ctx *gin.Context) {
+	var limit int64
+	if raw := ctx.Query("limit"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	result, err := services.BackfillAppAccountTokens(ctx.Request.Context(), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to backfill appAccountTokens", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BackfillNotifications runs the notification history backfill now. An empty body resumes from the high-water mark.
+func BackfillNotifications(ctx *gin.Context) {
+	var opts services.BackfillOptions
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&opts); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backfill request", "details": err.Error()})
+			return
+		}
+	}
+
+	result, err := services.BackfillNotifications(ctx.Request.Context(), opts)
+	if errors.Is(err, services.ErrBackfillRunning) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Notification backfill failed", "details": services.AppStoreErrorMessage(err), "result": result})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// GetBackfillState returns the high-water mark and last run of the notification backfill job
+func GetBackfillState(ctx *gin.Context) {
+	state, err := mongoRepo.GetJobState(services.NotificationBackfillJob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Notification backfill has not run yet"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch backfill state", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, state)
+}
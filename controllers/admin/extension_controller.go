@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ExtendRequest is the body of a single subscription renewal-date extension
+type ExtendRequest struct {
+	ExtendByDays     int32                   `json:"extendByDays" binding:"required"`
+	ExtendReasonCode models.ExtendReasonCode `json:"extendReasonCode"`
+}
+
+// MassExtendRequest is the body of a renewal-date extension for every active subscriber of a product
+type MassExtendRequest struct {
+	ProductId              string                  `json:"productId" binding:"required"`
+	StorefrontCountryCodes []string                `json:"storefrontCountryCodes"`
+	ExtendByDays           int32                   `json:"extendByDays" binding:"required"`
+	ExtendReasonCode       models.ExtendReasonCode `json:"extendReasonCode"`
+}
+
+// ExtendSubscription extends the renewal date of one subscription
+func ExtendSubscription(ctx *gin.Context) {
+	var req ExtendRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid extension request", "details": err.Error()})
+		return
+	}
+
+	extension, err := services.ExtendSubscription(ctx.Request.Context(), ctx.Param("originalTransactionId"), req.ExtendByDays, req.ExtendReasonCode)
+	if err != nil {
+		respondWithExtensionError(ctx, extension, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, extension)
+}
+
+// MassExtendSubscriptions starts a renewal-date extension for every active subscriber of a product.
+// Apple completes it asynchronously; its progress is available from GetRenewalExtension.
+func MassExtendSubscriptions(ctx *gin.Context) {
+	var req MassExtendRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid extension request", "details": err.Error()})
+		return
+	}
+
+	extension, err := services.MassExtendSubscriptions(ctx.Request.Context(), req.ProductId, req.StorefrontCountryCodes, req.ExtendByDays, req.ExtendReasonCode)
+	if err != nil {
+		respondWithExtensionError(ctx, extension, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, extension)
+}
+
+// GetRenewalExtension returns a stored renewal-date extension. Pending mass extensions are refreshed from Apple first.
+func GetRenewalExtension(ctx *gin.Context) {
+	extension, err := mongoRepo.GetRenewalExtension(ctx.Param("requestIdentifier"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Renewal extension not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch renewal extension", "details": err.Error()})
+		return
+	}
+
+	if extension.Kind == models.RenewalExtensionMass && extension.Status == models.RenewalExtensionPending {
+		if err := services.RefreshMassExtension(ctx.Request.Context(), extension); err != nil {
+			ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to refresh renewal extension", "details": services.AppStoreErrorMessage(err)})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, extension)
+}
+
+// ListRenewalExtensions returns the most recent renewal-date extensions, optionally filtered by kind, status and productId
+func ListRenewalExtensions(ctx *gin.Context) {
+	filter := bson.M{}
+	for _, key := range []string{"kind", "status", "productId"} {
+		if value := ctx.Query(key); value != "" {
+			filter[key] = value
+		}
+	}
+
+	extensions, err := mongoRepo.GetRenewalExtensions(filter, 100)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch renewal extensions", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"extensions": extensions, "count": len(extensions)})
+}
+
+func respondWithExtensionError(ctx *gin.Context, extension *models.RenewalExtension, err error) {
+	if errors.Is(err, services.ErrInvalidRenewalExtension) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if extension == nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store renewal extension", "details": err.Error()})
+		return
+	}
+	ctx.JSON(services.AppStoreErrorStatus(err), gin.H{
+		"error":             "Failed to extend renewal date",
+		"details":           services.AppStoreErrorMessage(err),
+		"requestIdentifier": extension.RequestIdentifier,
+	})
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RunNotificationHealthCheck sends a TEST notification and answers 202 with the check's id right away.
+// The round trip takes up to two minutes; GET /notifications/health?id=<id> reports its outcome.
+func RunNotificationHealthCheck(ctx *gin.Context) {
+	check, err := services.BeginNotificationHealthCheck(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to request test notification", "details": services.AppStoreErrorMessage(err)})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"id": check.TestNotificationToken, "check": check})
+}
+
+// GetNotificationHealth returns the latest health checks, answering 503 when the most recent one failed.
+// With an id it returns that check alone, which is pending until its round trip completes.
+func GetNotificationHealth(ctx *gin.Context) {
+	if id := ctx.Query("id"); id != "" {
+		check, err := mongoRepo.GetNotificationHealthCheck(id)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Health check not found"})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch health check", "details": err.Error()})
+			return
+		}
+		ctx.JSON(healthCheckStatus(check), check)
+		return
+	}
+
+	checks, err := mongoRepo.GetNotificationHealthChecks(20)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch health checks", "details": err.Error()})
+		return
+	}
+	if len(checks) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No notification health check has run yet"})
+		return
+	}
+
+	ctx.JSON(healthCheckStatus(checks[0]), gin.H{"latest": checks[0], "checks": checks})
+}
+
+func healthCheckStatus(check *models.NotificationHealthCheck) int {
+	if check.Status == models.NotificationHealthFailed {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"net/http"
+
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRefundHistory fetches a customer's refund history from the App Store
+func GetRefundHistory(ctx *gin.Context) {
+	originalTransactionId := ctx.Param("originalTransactionId")
+
+	refunds, err := services.GetRefundHistory(ctx.Request.Context(), originalTransactionId)
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to fetch refund history", "details": services.AppStoreErrorMessage(err)})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"originalTransactionId": originalTransactionId,
+		"refunds":               refunds,
+		"count":                 len(refunds),
+	})
+}
+
+// SyncRefundHistory fetches a customer's refund history from the App Store and stores the refunded transactions
+func SyncRefundHistory(ctx *gin.Context) {
+	originalTransactionId := ctx.Param("originalTransactionId")
+
+	refunds, err := services.SyncRefundHistory(ctx.Request.Context(), originalTransactionId)
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to sync refund history", "details": services.AppStoreErrorMessage(err)})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"originalTransactionId": originalTransactionId,
+		"refunds":               refunds,
+		"count":                 len(refunds),
+	})
+}
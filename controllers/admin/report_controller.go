@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindReportRange reads the startDate, endDate and environment query parameters of a report
+func bindReportRange(ctx *gin.Context) (services.ReportRange, bool) {
+	r, err := services.ParseReportRange(ctx.Query("startDate"), ctx.Query("endDate"), ctx.Query("environment"), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report range", "details": err.Error()})
+		return r, false
+	}
+	return r, true
+}
+
+// GetActiveSubscribersReport returns the active subscribers of each product at the end of the date range
+func GetActiveSubscribersReport(ctx *gin.Context) {
+	r, ok := bindReportRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := services.GetActiveSubscribersReport(r, time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build active subscribers report", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetDailyActivityReport returns the new, renewing and churned subscriptions of each day in the date range
+func GetDailyActivityReport(ctx *gin.Context) {
+	r, ok := bindReportRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := services.GetDailyActivityReport(r, time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build daily activity report", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetTrialConversionReport returns the trial-to-paid conversion of the trials started in the date range
+func GetTrialConversionReport(ctx *gin.Context) {
+	r, ok := bindReportRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := services.GetTrialConversionReport(r)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build trial conversion report", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetRevenueReport returns the revenue of the date range by currency and storefront
+func GetRevenueReport(ctx *gin.Context) {
+	r, ok := bindReportRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := services.GetRevenueReport(r)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build revenue report", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// RefreshExchangeRates re-imports the exchange rates and tax rates files and reloads the rates revenue reports use
+func RefreshExchangeRates(ctx *gin.Context) {
+	if err := services.RefreshExchangeRates(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh exchange rates", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Exchange rates refreshed"})
+}
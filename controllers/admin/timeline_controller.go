@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetUserTimeline returns every purchase event we know of for a user, oldest first
+func GetUserTimeline(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	user, err := mongoRepo.GetUserByID(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user", "details": err.Error()})
+		return
+	}
+
+	timeline, err := services.GetUserTimeline(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build user timeline", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, timeline)
+}
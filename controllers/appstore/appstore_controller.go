@@ -1,18 +1,16 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
-	"simvizlab-backend/models"
-	"simvizlab-backend/utils"
-
 	"simvizlab-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// EnvironmentHeader reports which App Store environment answered a lookup
+const EnvironmentHeader = "X-AppStore-Environment"
+
 type TransactionRequest struct {
 	TransactionID string `json:"transaction_id" binding:"required"`
 }
@@ -23,35 +21,18 @@ func GetTransactionInfo(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid transaction_id"})
 		return
 	}
-
-	jwtToken, err := utils.GenerateAppStoreJWT()
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "JWT generation failed"})
+	if !authorizePurchase(ctx, req.TransactionID) {
 		return
 	}
 
-	data, err := services.FetchTransaction(jwtToken, req.TransactionID)
+	transaction, environment, err := services.Gateway().GetTransaction(ctx.Request.Context(), req.TransactionID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to fetch transaction", "details": services.AppStoreErrorMessage(err)})
 		return
 	}
 
-	var response models.TransactionInfoResponse
-
-	if err := json.Unmarshal(data, &response); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction response"})
-		return
-	}
-
-	results, err := utils.DecodeSignedTransactionInfo(string(response.SignedTransactionInfo))
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode transaction data"})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, results)
+	ctx.Header(EnvironmentHeader, string(environment))
+	ctx.JSON(http.StatusOK, transaction)
 }
 
 func GetHistoryInfo(ctx *gin.Context) {
@@ -60,35 +41,21 @@ func GetHistoryInfo(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid transaction_id"})
 		return
 	}
-
-	jwtToken, err := utils.GenerateAppStoreJWT()
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "JWT generation failed"})
+	if !authorizePurchase(ctx, req.TransactionID) {
 		return
 	}
 
-	data, err := services.FetchTransactionHistory(jwtToken, req.TransactionID)
+	history, err := services.Gateway().GetTransactionHistory(ctx.Request.Context(), req.TransactionID, nil)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to fetch transaction history", "details": services.AppStoreErrorMessage(err)})
 		return
 	}
 
-	var historyResponse models.HistoryResponse
-	var AppleAppId int64
-	if err := json.Unmarshal(data, &historyResponse); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse history response"})
-		return
-	}
-	AppleAppId = historyResponse.AppAppleId
-	fmt.Println("AppleAppId:", AppleAppId)
-	results, err := utils.DecodeSignedTransactionInfo(string(historyResponse.SignedTransactions[0]))
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode transaction data"})
+	ctx.Header(EnvironmentHeader, string(history.Environment))
+	if len(history.Transactions) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No transactions found"})
 		return
 	}
-	// ctx.Data(http.StatusOK, "application/json", data)
 
-	ctx.JSON(http.StatusOK, results)
+	ctx.JSON(http.StatusOK, history.Transactions[0])
 }
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/authctx"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// authorizePurchase lets staff who may read subscriptions look up any purchase and users only their own,
+// answering the request when the caller may not. Purchases of other users are reported as not found.
+func authorizePurchase(ctx *gin.Context, transactionId string) bool {
+	if authctx.HasPermission(ctx, models.PermReadSubscriptions) {
+		return true
+	}
+
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	user, err := mongoRepo.GetUserByID(userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "database error", "details": err.Error()})
+		return false
+	}
+
+	owns, err := services.UserOwnsPurchase(ctx.Request.Context(), user, transactionId)
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to check purchase owner", "details": services.AppStoreErrorMessage(err)})
+		return false
+	}
+	if !owns {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "purchase not found"})
+		return false
+	}
+	return true
+}
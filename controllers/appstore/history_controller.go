@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simvizlab-backend/models"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyFilterValues lists the accepted values of Apple's enumerated history filters
+// https://developer.apple.com/documentation/appstoreserverapi/get-transaction-history
+var historyFilterValues = map[string][]string{
+	"productType":        {"AUTO_RENEWABLE", "NON_RENEWABLE", "CONSUMABLE", "NON_CONSUMABLE"},
+	"inAppOwnershipType": {"FAMILY_SHARED", "PURCHASED"},
+	"revoked":            {"true", "false"},
+	"sort":               {"ASCENDING", "DESCENDING"},
+}
+
+// HistoryPage is one page of a verified transaction history, as Apple pages it
+type HistoryPage struct {
+	OriginalTransactionId string                   `json:"originalTransactionId"`
+	Environment           models.Environment       `json:"environment"`
+	BundleId              string                   `json:"bundleId"`
+	Transactions          []*models.JWSTransaction `json:"transactions"`
+	NextCursor            string                   `json:"nextCursor,omitempty"`
+	HasMore               bool                     `json:"hasMore"`
+}
+
+// historyCursor continues a history at Apple's revision, in the environment that issued the revision
+type historyCursor struct {
+	Revision    string             `json:"r"`
+	Environment models.Environment `json:"e"`
+}
+
+// GetTransactionHistory returns the verified transactions of an original transaction, filtered with Apple's
+// history query parameters. Each response is one page of Apple's history; nextCursor fetches the next page
+// and must be sent with the same filters. Users may only read the history of their own purchases.
+func GetTransactionHistory(ctx *gin.Context) {
+	originalTransactionId := ctx.Param("originalTransactionId")
+	if !authorizePurchase(ctx, originalTransactionId) {
+		return
+	}
+
+	query, err := historyQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor, err := decodeHistoryCursor(ctx.Query("cursor"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+
+	history, err := services.Gateway().GetTransactionHistoryPage(ctx.Request.Context(), originalTransactionId, query, cursor.Revision, cursor.Environment)
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to fetch transaction history", "details": services.AppStoreErrorMessage(err)})
+		return
+	}
+
+	page := HistoryPage{
+		OriginalTransactionId: originalTransactionId,
+		Environment:           history.Environment,
+		BundleId:              history.BundleId,
+		Transactions:          history.Transactions,
+		HasMore:               history.HasMore,
+	}
+	if history.HasMore {
+		page.NextCursor = encodeHistoryCursor(historyCursor{Revision: history.Revision, Environment: history.Environment})
+	}
+
+	ctx.Header(EnvironmentHeader, string(history.Environment))
+	ctx.JSON(http.StatusOK, page)
+}
+
+// historyQuery validates the filters of a history request and copies them into an App Store query
+func historyQuery(params url.Values) (url.Values, error) {
+	query := url.Values{}
+
+	for _, key := range []string{"productId", "subscriptionGroupIdentifier"} {
+		for _, value := range params[key] {
+			if value == "" {
+				return nil, fmt.Errorf("%s must not be empty", key)
+			}
+			query.Add(key, value)
+		}
+	}
+
+	for _, key := range []string{"startDate", "endDate"} {
+		if value := params.Get(key); value != "" {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || ms < 0 {
+				return nil, fmt.Errorf("%s must be a timestamp in milliseconds", key)
+			}
+			query.Set(key, value)
+		}
+	}
+	if query.Get("startDate") != "" && query.Get("endDate") != "" {
+		start, _ := strconv.ParseInt(query.Get("startDate"), 10, 64)
+		end, _ := strconv.ParseInt(query.Get("endDate"), 10, 64)
+		if start >= end {
+			return nil, fmt.Errorf("startDate must precede endDate")
+		}
+	}
+
+	for key, allowed := range historyFilterValues {
+		for _, value := range params[key] {
+			if !contains(allowed, value) {
+				return nil, fmt.Errorf("%s must be one of %v", key, allowed)
+			}
+			if key == "productType" {
+				query.Add(key, value)
+			} else {
+				query.Set(key, value)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeHistoryCursor(cursor historyCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeHistoryCursor(encoded string) (historyCursor, error) {
+	var cursor historyCursor
+	if encoded == "" {
+		return cursor, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.Revision == "" || (cursor.Environment != models.Production && cursor.Environment != models.Sandbox) {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReceiveNotification handles App Store Server Notifications V2.
+// Apple retries any delivery that does not get a 200, so it is only returned after the
+// notification has been durably stored.
+func ReceiveNotification(ctx *gin.Context) {
+	var req models.NotificationV2
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.SignedPayload == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid signedPayload"})
+		return
+	}
+
+	notification, created, err := services.IngestNotification(req.SignedPayload)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNotification) {
+			logger.Warnf("rejected app store notification: %v", err)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signedPayload"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store notification"})
+		return
+	}
+
+	if !created {
+		logger.Infof("duplicate app store notification %s", notification.NotificationUUID)
+	}
+
+	// Processing is deduplicated by notificationUUID: a redelivery of an applied notification is not applied
+	// again, while one that was never applied or whose processing failed is attempted once more
+	go func() {
+		if _, err := services.ProcessNotification(notification); err != nil {
+			logger.Errorf("processing notification %s failed: %v", notification.NotificationUUID, err)
+		}
+	}()
+
+	ctx.JSON(http.StatusOK, gin.H{"notificationUUID": notification.NotificationUUID})
+}
@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"net/http"
+
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LookupOrder resolves the invoice order ID from a customer's Apple receipt to verified purchases and linked users
+func LookupOrder(ctx *gin.Context) {
+	result, err := services.LookupOrder(ctx.Request.Context(), ctx.Param("orderId"))
+	if err != nil {
+		ctx.JSON(services.AppStoreErrorStatus(err), gin.H{"error": "Failed to look up order", "details": services.AppStoreErrorMessage(err)})
+		return
+	}
+
+	ctx.Header(EnvironmentHeader, string(result.Environment))
+	ctx.JSON(http.StatusOK, result)
+}
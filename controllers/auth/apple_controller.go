@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/authctx"
+	"simvizlab-backend/models"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// appleErrorStatus maps a Sign in with Apple failure to a response status
+func appleErrorStatus(err error) int {
+	var appleErr *models.AppleIDError
+	switch {
+	case errors.Is(err, services.ErrAppleSignInMissingToken), errors.Is(err, services.ErrAppleNonceRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrAppleIDInvalidToken), errors.Is(err, models.ErrAppleIDNonce),
+		errors.Is(err, services.ErrAppleNonceInvalid), errors.Is(err, services.ErrAppleSubjectMismatch):
+		return http.StatusUnauthorized
+	case errors.As(err, &appleErr):
+		// Apple rejects an expired, reused or foreign authorization code with invalid_grant
+		if appleErr.Code == "invalid_grant" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrAppleIDInUse),
+		errors.Is(err, services.ErrAppleIDLinkedElsewhere), errors.Is(err, services.ErrAppleIDNotLinked),
+		errors.Is(err, services.ErrLastSignInMethod):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func respondWithAppleError(ctx *gin.Context, message string, err error) {
+	status := appleErrorStatus(err)
+	if status == http.StatusInternalServerError || status == http.StatusBadGateway {
+		ctx.JSON(status, gin.H{"error": message, "details": err.Error()})
+		return
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+// IssueAppleNonce returns a single-use nonce the app sets as the nonce of its Sign in with Apple request
+// and sends back with the identity token
+func IssueAppleNonce(ctx *gin.Context) {
+	nonce, expiresAt, err := services.IssueAppleNonce()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue nonce", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"nonce": nonce, "expiresAt": expiresAt})
+}
+
+// SignInWithApple signs in with an identity token or authorization code from Sign in with Apple,
+// creating the account on first sign in
+func SignInWithApple(ctx *gin.Context) {
+	var req services.AppleSignIn
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, tokens, err := services.SignInWithApple(ctx.Request.Context(), req)
+	if err != nil {
+		respondWithAppleError(ctx, "Failed to sign in with Apple", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessionResponse(user, tokens))
+}
+
+// LinkAppleID adds Sign in with Apple to the authenticated user's account
+func LinkAppleID(ctx *gin.Context) {
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req services.AppleSignIn
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, err := services.LinkAppleID(ctx.Request.Context(), userID, req)
+	if err != nil {
+		respondWithAppleError(ctx, "Failed to link Apple ID", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"id": user.ID.Hex(), "appleSub": user.AppleSub})
+}
+
+// UnlinkAppleID revokes Sign in with Apple for the authenticated user and removes it from their account
+func UnlinkAppleID(ctx *gin.Context) {
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := services.UnlinkAppleID(ctx.Request.Context(), userID); err != nil {
+		respondWithAppleError(ctx, "Failed to unlink Apple ID", err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// DeleteAccount deletes the authenticated user's account, revoking Sign in with Apple first
+func DeleteAccount(ctx *gin.Context) {
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := services.DeleteAccount(ctx.Request.Context(), userID); err != nil {
+		respondWithAppleError(ctx, "Failed to delete account", err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"simvizlab-backend/authctx"
+	"simvizlab-backend/models"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type registerRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Username string `json:"username"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// sessionResponse pairs the signed-in account with its tokens, leaving out the password hash
+func sessionResponse(user *models.User, tokens *services.AuthTokens) gin.H {
+	return gin.H{
+		"user": gin.H{
+			"id":       user.ID.Hex(),
+			"email":    user.Email,
+			"username": user.Username,
+			"role":     user.Role,
+		},
+		"tokens": tokens,
+	}
+}
+
+// Register creates an account from an email and password and signs it in
+func Register(ctx *gin.Context) {
+	var req registerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, tokens, err := services.Register(req.Email, req.Password, req.Username)
+	switch {
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrInvalidPassword):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrEmailTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, sessionResponse(user, tokens))
+}
+
+// Login signs in with an email and password
+func Login(ctx *gin.Context) {
+	var req loginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, tokens, err := services.Login(req.Email, req.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessionResponse(user, tokens))
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token
+func Refresh(ctx *gin.Context) {
+	var req refreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	tokens, err := services.RefreshSession(req.RefreshToken)
+	if errors.Is(err, services.ErrInvalidToken) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session of a refresh token. Unknown tokens are accepted so logging out twice is harmless.
+func Logout(ctx *gin.Context) {
+	var req refreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	if err := services.Logout(req.RefreshToken); err != nil && !errors.Is(err, services.ErrInvalidToken) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out", "details": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// LogoutAll revokes every session of the authenticated user
+func LogoutAll(ctx *gin.Context) {
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := services.LogoutAll(userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out", "details": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -1,18 +1,20 @@
 package user
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"simvizlab-backend/authctx"
 	"simvizlab-backend/models"
 	mongoRepo "simvizlab-backend/repository/mongo"
 	"simvizlab-backend/services"
-	"simvizlab-backend/utils"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -81,95 +83,258 @@ func CreateUser(ctx *gin.Context) {
 		return
 	}
 
-	jwtToken, err := utils.GenerateAppStoreJWT()
+	decodedInfo, environment, err := services.Gateway().GetTransaction(ctx.Request.Context(), req.OriginalTransactionId)
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to generate JWT", err.Error())
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to fetch transaction", services.AppStoreErrorMessage(err))
 		return
 	}
 
-	data, err := services.FetchTransaction(jwtToken, req.OriginalTransactionId)
+	// A purchase made with an appAccountToken can only be claimed by the user it was issued to
+	if decodedInfo.AppAccountToken != "" {
+		owner, err := services.FindUserForTransaction(decodedInfo)
+		if err != nil {
+			respondWithError(ctx, http.StatusInternalServerError, "Database error while checking purchase owner", err.Error())
+			return
+		}
+		if owner != nil {
+			respondWithError(ctx, http.StatusConflict, services.ErrPurchaseOwnedByAnotherUser.Error())
+			return
+		}
+	}
+
+	user := models.User{
+		AppleAppId:            req.AppleAppId,
+		OriginalTransactionId: req.OriginalTransactionId,
+	}
+	if decodedInfo.AppAccountToken != "" {
+		// the unclaimed purchase's token becomes the user's, and Apple already has it on the purchase
+		now := time.Now()
+		user.AppAccountToken = strings.ToLower(decodedInfo.AppAccountToken)
+		user.AppAccountTokenSynced = &now
+	}
+	err = mongoRepo.Save("users", &user)
+	if mongo.IsDuplicateKeyError(err) {
+		respondWithError(ctx, http.StatusConflict, services.ErrPurchaseOwnedByAnotherUser.Error())
+		return
+	}
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to fetch transaction", err.Error())
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to save user", err.Error())
+		return
+	}
+
+	transaction := models.JWSTransaction{
+		AppleAppId:                  req.AppleAppId,
+		AppTransactionId:            decodedInfo.AppTransactionId,
+		TransactionID:               decodedInfo.TransactionID,
+		OriginalTransactionId:       req.OriginalTransactionId,
+		AppAccountToken:             decodedInfo.AppAccountToken,
+		WebOrderLineItemId:          decodedInfo.WebOrderLineItemId,
+		BundleID:                    decodedInfo.BundleID,
+		ProductID:                   decodedInfo.ProductID,
+		SubscriptionGroupIdentifier: decodedInfo.SubscriptionGroupIdentifier,
+		Type:                        decodedInfo.Type,
+		PurchaseDate:                decodedInfo.PurchaseDate,
+		ExpiresDate:                 decodedInfo.ExpiresDate,
+		Currency:                    decodedInfo.Currency,
+		OriginalPurchaseDate:        decodedInfo.OriginalPurchaseDate,
+		Storefront:                  decodedInfo.Storefront,
+		TransactionReason:           decodedInfo.TransactionReason,
+		OfferType:                   decodedInfo.OfferType,
+		OfferIdentifier:             decodedInfo.OfferIdentifier,
+		OfferDiscountType:           decodedInfo.OfferDiscountType,
+		RevocationDate:              decodedInfo.RevocationDate,
+		IsUpgraded:                  decodedInfo.IsUpgraded,
+		Environment:                 environment,
+		SignedDate:                  decodedInfo.SignedDate,
+		Price:                       decodedInfo.Price,
+		// Add more fields from results as needed
+	}
+
+	if err := mongoRepo.SaveTransaction(&transaction); err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to save transaction", err.Error())
 		return
 	}
 
-	var response models.TransactionInfoResponse
-	if err := json.Unmarshal(data, &response); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to parse transaction response", err.Error())
+	ctx.JSON(http.StatusCreated, gin.H{
+		"user":        user,
+		"transaction": transaction,
+	})
+}
+
+// GetUserByID returns a user record
+func GetUserByID(ctx *gin.Context) {
+	user, ok := findUserParam(ctx)
+	if !ok {
 		return
 	}
 
-	results, err := utils.DecodeSignedTransactionInfo(string(response.SignedTransactionInfo))
+	ctx.JSON(http.StatusOK, user)
+}
+
+// UpdateUser changes the profile fields of a user. Roles are changed through the admin endpoints.
+func UpdateUser(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to decode transaction data", err.Error())
+		respondWithError(ctx, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+	var req models.UpdateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
 		return
 	}
 
-	var decodedInfo models.JWSTransaction
+	fields := bson.M{"updated_at": time.Now()}
+	if req.Username != nil {
+		fields["username"] = strings.TrimSpace(*req.Username)
+	}
+	if req.ConsumptionDataConsent != nil {
+		fields["consumptionDataConsent"] = *req.ConsumptionDataConsent
+	}
+	if req.PlayTimeMinutes != nil {
+		fields["playTimeMinutes"] = *req.PlayTimeMinutes
+	}
 
-	jsonBytes, err := json.Marshal(results)
+	found, err := mongoRepo.UpdateUserFields(id, fields)
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to re-encode transaction map", err.Error())
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to update user", err.Error())
+		return
+	}
+	if !found {
+		respondWithError(ctx, http.StatusNotFound, "user not found")
 		return
 	}
 
-	if err := json.Unmarshal(jsonBytes, &decodedInfo); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to parse decoded transaction data", err.Error())
+	user, ok := findUserParam(ctx)
+	if !ok {
 		return
 	}
+	ctx.JSON(http.StatusOK, user)
+}
 
-	user := models.User{
-		AppleAppId:            req.AppleAppId,
-		OriginalTransactionId: req.OriginalTransactionId,
+// GetAppAccountToken returns the appAccountToken the app must pass to StoreKit when this user purchases,
+// so Apple signs every transaction with the account it belongs to
+func GetAppAccountToken(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid user id")
+		return
 	}
-	if err := mongoRepo.Save("users", &user); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to save user", err.Error())
+
+	token, err := services.IssueAppAccountToken(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		respondWithError(ctx, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to issue appAccountToken", err.Error())
 		return
 	}
 
-	transaction := models.JWSTransaction{
-		AppleAppId:            req.AppleAppId,
-		AppTransactionId:      decodedInfo.AppTransactionId,
-		OriginalTransactionId: req.OriginalTransactionId,
-		WebOrderLineItemId:    decodedInfo.WebOrderLineItemId,
-		BundleID:              decodedInfo.BundleID,
-		ProductID:             decodedInfo.ProductID,
-		PurchaseDate:          decodedInfo.PurchaseDate,
-		ExpiresDate:           decodedInfo.ExpiresDate,
-		Currency:              decodedInfo.Currency,
-		OriginalPurchaseDate:  decodedInfo.OriginalPurchaseDate,
-		Storefront:            decodedInfo.Storefront,
-		Environment:           decodedInfo.Environment,
-		SignedDate:            decodedInfo.SignedDate,
-		Price:                 decodedInfo.Price,
-		// Add more fields from results as needed
+	ctx.JSON(http.StatusOK, gin.H{"appAccountToken": token})
+}
+
+// GetUserEntitlements returns the features a user can access across all subscription groups and non-consumables
+func GetUserEntitlements(ctx *gin.Context) {
+	user, ok := findUserParam(ctx)
+	if !ok {
+		return
 	}
 
-	if err := mongoRepo.Save("transactions", &transaction); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to save transaction", err.Error())
+	entitlements, err := services.GetUserEntitlements(ctx.Request.Context(), user)
+	if err != nil {
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to evaluate entitlements", services.AppStoreErrorMessage(err))
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"user":        user,
-		"transaction": transaction,
-	})
+	ctx.JSON(http.StatusOK, entitlements)
 }
 
-func UpdateUser(ctx *gin.Context) {
-	var user models.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// OfferSignatureRequest asks for the signature of an offer; productId defaults to the eligible product
+type OfferSignatureRequest struct {
+	ProductId string `json:"productId"`
+	OfferId   string `json:"offerId" binding:"required"`
+}
+
+// GetEligibleOffers lists the promotional offers a user may have signed and the win-back offers they are eligible for
+func GetEligibleOffers(ctx *gin.Context) {
+	user, ok := findUserParam(ctx)
+	if !ok {
 		return
 	}
-	err := mongoRepo.Update("users", map[string]interface{}{"_id": user.ID}, &user)
 
+	offers, err := services.GetEligibleOffers(ctx.Request.Context(), user)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to evaluate offers", services.AppStoreErrorMessage(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	ctx.JSON(http.StatusOK, gin.H{"offers": offers.Promotional, "winBackOffers": offers.WinBack, "count": len(offers.Promotional)})
+}
+
+// SignOffer returns the signature StoreKit needs to redeem an offer the user is eligible for
+func SignOffer(ctx *gin.Context) {
+	var req OfferSignatureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	user, ok := findUserParam(ctx)
+	if !ok {
+		return
+	}
+
+	signature, err := services.SignOffer(ctx.Request.Context(), user, req.ProductId, req.OfferId)
+	if errors.Is(err, services.ErrOfferNotEligible) {
+		respondWithError(ctx, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to sign offer", services.AppStoreErrorMessage(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, signature)
+}
+
+// ownsPurchase reports whether the authenticated user may act on a purchase of their app
+func ownsPurchase(ctx *gin.Context, appleAppId int64, originalTransactionId string) (bool, error) {
+	userID, ok := authctx.UserID(ctx)
+	if !ok {
+		return false, nil
+	}
+	user, err := mongoRepo.GetUserByID(userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.AppleAppId != 0 && user.AppleAppId != appleAppId {
+		return false, nil
+	}
+	return services.UserOwnsPurchase(ctx.Request.Context(), user, originalTransactionId)
+}
+
+// findUserParam loads the user named by the :id path parameter, answering the request when it cannot
+func findUserParam(ctx *gin.Context) (*models.User, bool) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid user id")
+		return nil, false
+	}
+
+	user, err := mongoRepo.GetUserByID(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		respondWithError(ctx, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, "database error", err.Error())
+		return nil, false
+	}
+	return user, true
 }
 
 func CheckUserSubscriptionStatus(ctx *gin.Context) {
@@ -194,6 +359,12 @@ func CheckUserSubscriptionStatus(ctx *gin.Context) {
 		return
 	}
 
+	// end-users may only check their own record
+	if authID, _ := authctx.UserID(ctx); authID != user.ID && !authctx.HasPermission(ctx, models.PermReadUsers) {
+		respondWithError(ctx, http.StatusNotFound, "user not found")
+		return
+	}
+
 	// Prefer explicit transactionId; otherwise use originalTransactionId (query or stored)
 	transactionId := ctx.Query("transactionId")
 	originalTransactionId := ctx.Query("originalTransactionId")
@@ -201,58 +372,36 @@ func CheckUserSubscriptionStatus(ctx *gin.Context) {
 		originalTransactionId = user.OriginalTransactionId
 	}
 
-	jwtToken, err := utils.GenerateAppStoreJWT()
-	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "failed to generate JWT", err.Error())
-		return
-	}
-
-	var data []byte
+	var tx *models.JWSTransaction
+	var environment models.Environment
 	if transactionId != "" {
-		data, err = services.FetchTransaction(jwtToken, transactionId)
+		tx, environment, err = services.Gateway().GetTransaction(ctx.Request.Context(), transactionId)
 	} else {
 		if originalTransactionId == "" {
 			respondWithError(ctx, http.StatusBadRequest, "originalTransactionId is required when transactionId is not provided")
 			return
 		}
-		data, err = services.FetchTransactionHistory(jwtToken, originalTransactionId)
-	}
-	if err != nil {
-		respondWithError(ctx, http.StatusBadRequest, "failed to fetch App Store data", err.Error())
-		return
-	}
-
-	var jws string
-	if transactionId != "" {
-		var tr models.TransactionInfoResponse
-		if err := json.Unmarshal(data, &tr); err != nil {
-			respondWithError(ctx, http.StatusInternalServerError, "failed to parse transaction response", err.Error())
-			return
-		}
-		jws = tr.SignedTransactionInfo
-	} else {
-		var hist models.HistoryResponse
-		if err := json.Unmarshal(data, &hist); err != nil {
-			respondWithError(ctx, http.StatusInternalServerError, "failed to parse history response", err.Error())
-			return
+		// The most recent transaction carries the current expiry
+		query := url.Values{}
+		query.Set("sort", "DESCENDING")
+		var history *services.TransactionHistory
+		history, err = services.Gateway().GetTransactionHistory(ctx.Request.Context(), originalTransactionId, &query)
+		if err == nil {
+			if len(history.Transactions) == 0 {
+				respondWithError(ctx, http.StatusNotFound, "no transactions found for originalTransactionId")
+				return
+			}
+			tx = history.Transactions[0]
+			environment = history.Environment
 		}
-		if len(hist.SignedTransactions) == 0 {
-			respondWithError(ctx, http.StatusNotFound, "no transactions found for originalTransactionId")
-			return
-		}
-		jws = hist.SignedTransactions[0]
 	}
-
-	decoded, err := utils.DecodeSignedTransactionInfo(jws)
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "failed to decode transaction JWS", err.Error())
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "failed to fetch App Store data", services.AppStoreErrorMessage(err))
 		return
 	}
 
-	var tx models.JWSTransaction
-	raw, _ := json.Marshal(decoded)
-	if err := json.Unmarshal(raw, &tx); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "failed to parse decoded transaction", err.Error())
+	if err := services.CheckPurchaseOwner(&user, tx); err != nil {
+		respondWithError(ctx, http.StatusForbidden, err.Error())
 		return
 	}
 
@@ -264,11 +413,21 @@ func CheckUserSubscriptionStatus(ctx *gin.Context) {
 	}
 	active := expires == 0 || expires > nowMs
 
+	// Refunded or revoked purchases never grant access
+	revoked, err := services.IsTransactionRevoked(tx)
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, "failed to check refunds", err.Error())
+		return
+	}
+	active = active && !revoked
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"exists":      true,
 		"active":      active,
+		"revoked":     revoked,
 		"expiresAtMs": expires,
 		"nowMs":       nowMs,
+		"environment": environment,
 		"user":        user,
 		"transaction": tx,
 	})
@@ -285,6 +444,19 @@ func LoginAndCheckStatus(ctx *gin.Context) {
 		return
 	}
 
+	// end-users may only check their own purchases
+	if !authctx.HasPermission(ctx, models.PermReadUsers) {
+		owns, err := ownsPurchase(ctx, req.AppleAppId, req.OriginalTransactionId)
+		if err != nil {
+			respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to check purchase owner", services.AppStoreErrorMessage(err))
+			return
+		}
+		if !owns {
+			respondWithError(ctx, http.StatusNotFound, "purchase not found")
+			return
+		}
+	}
+
 	// Find existing transactionApple record (if any)
 	var existing models.TransactionApple
 	findErr := mongoRepo.GetOne(
@@ -293,34 +465,33 @@ func LoginAndCheckStatus(ctx *gin.Context) {
 		&existing,
 	)
 
-	// Generate App Store JWT
-	jwtToken, err := utils.GenerateAppStoreJWT()
+	// Call Apple get-all-subscription-statuses
+	statusResp, err := services.Gateway().GetSubscriptionStatuses(ctx.Request.Context(), req.OriginalTransactionId)
 	if err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to generate JWT", err.Error())
+		respondWithError(ctx, services.AppStoreErrorStatus(err), "Failed to fetch subscription statuses", services.AppStoreErrorMessage(err))
 		return
 	}
 
-	// Call Apple get-all-subscription-statuses
-	data, err := services.FetchAllSubscriptionStatuses(jwtToken, req.OriginalTransactionId)
-	if err != nil {
-		respondWithError(ctx, http.StatusBadRequest, "Failed to fetch subscription statuses", err.Error())
+	// Every group is evaluated; the record keeps the status granting the most access
+	groups, err := services.SummarizeSubscriptionStatuses(statusResp, time.Now())
+	if errors.Is(err, services.ErrUnverifiedSubscriptionStatus) {
+		respondWithError(ctx, http.StatusBadGateway, "Failed to verify subscription statuses", err.Error())
 		return
 	}
-
-	var statusResp models.StatusResponse
-	if err := json.Unmarshal(data, &statusResp); err != nil {
-		respondWithError(ctx, http.StatusInternalServerError, "Failed to parse subscription statuses", err.Error())
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to check refunds", err.Error())
 		return
 	}
 
-	// Extract a representative status (pick first group -> first lastTransaction)
 	var statusCode int32
-	if len(statusResp.Data) > 0 && len(statusResp.Data[0].LastTransactions) > 0 {
-		statusCode = statusResp.Data[0].LastTransactions[0].Status
+	var renewal *models.RenewalSummary
+	if best := services.BestSubscriptionStatus(groups); best != nil {
+		statusCode = best.Status
+		renewal = best.Renewal
 	}
 
 	// Map status to text
-	statusText := mapStatusText(statusCode)
+	statusText := models.StatusText(statusCode)
 
 	// Decide active (not expired) – treat Active(1) and Grace Period(4) as active
 	active := statusCode == 1 || statusCode == 4
@@ -335,6 +506,7 @@ func LoginAndCheckStatus(ctx *gin.Context) {
 		StatusText:            statusText,
 		BundleId:              statusResp.BundleId,
 		Environment:           statusResp.Environment,
+		Renewal:               renewal,
 		UpdatedAt:             now,
 	}
 
@@ -367,22 +539,7 @@ func LoginAndCheckStatus(ctx *gin.Context) {
 		"active":     active,
 		"bundleId":   statusResp.BundleId,
 		"env":        statusResp.Environment,
+		"renewal":    renewal,
+		"groups":     groups,
 	})
 }
-
-func mapStatusText(s int32) string {
-	switch s {
-	case 1:
-		return "Active"
-	case 2:
-		return "Expired"
-	case 3:
-		return "Billing Retry"
-	case 4:
-		return "Billing Grace Period"
-	case 5:
-		return "Revoked"
-	default:
-		return "Unknown"
-	}
-}
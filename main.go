@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -8,7 +9,9 @@ import (
 	"simvizlab-backend/config"
 	"simvizlab-backend/infra/database"
 	"simvizlab-backend/infra/logger"
+	mongoRepo "simvizlab-backend/repository/mongo"
 	"simvizlab-backend/routers"
+	"simvizlab-backend/services"
 
 	"github.com/spf13/viper"
 	"github.com/subosito/gotenv"
@@ -51,6 +54,15 @@ func main() {
 		logger.Fatalf("MongoDB connection error: %s", err)
 	}
 
+	if err := mongoRepo.EnsureIndexes(); err != nil {
+		logger.Fatalf("MongoDB index setup failed: %s", err)
+	}
+
+	services.StartMassExtensionPoller(context.Background())
+	services.StartNotificationBackfill(context.Background())
+	services.StartNotificationHealthCheck(context.Background())
+	services.StartExchangeRateRefresh(context.Background())
+
 	log.Println("Setting up router...")
 	router := routers.SetupRoute()
 
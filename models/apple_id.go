@@ -0,0 +1,224 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Sign in with Apple REST API
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi
+const (
+	HostAppleID = "https://appleid.apple.com"
+
+	PathAppleIDKeys   = "/auth/keys"
+	PathAppleIDToken  = "/auth/token"
+	PathAppleIDRevoke = "/auth/revoke"
+)
+
+var (
+	ErrAppleIDInvalidToken = errors.New("appleid: invalid identity token")
+	ErrAppleIDNonce        = errors.New("appleid: identity token nonce does not match")
+)
+
+// AppleIDConfig configures Sign in with Apple for one client ID, the app's bundle ID for native apps
+// or a Services ID for the web
+type AppleIDConfig struct {
+	ClientID    string           // Your app's bundle ID or Services ID, the audience of identity tokens
+	TeamID      string           // Your 10-character Team ID, the issuer of client secrets
+	KeyID       string           // The key ID of your Sign in with Apple private key
+	KeyContent  []byte           // Loads the Sign in with Apple .p8 private key
+	RedirectURI string           // The redirect URI of the web authorization request, empty for native apps
+	KeySource   AppleIDKeySource // Verifies identity tokens. Default is Apple's key set, fetched and cached.
+}
+
+// AppleIDClaims are the claims of a Sign in with Apple identity token
+// Doc: https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_rest_api/authenticating_users_with_sign_in_with_apple
+type AppleIDClaims struct {
+	Email          string   `json:"email,omitempty"`
+	EmailVerified  FlexBool `json:"email_verified,omitempty"`
+	IsPrivateEmail FlexBool `json:"is_private_email,omitempty"`
+	Nonce          string   `json:"nonce,omitempty"`
+	NonceSupported bool     `json:"nonce_supported,omitempty"`
+	RealUserStatus int32    `json:"real_user_status,omitempty"`
+	AuthTime       int64    `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// FlexBool decodes the boolean claims Apple sends either as JSON booleans or as "true" and "false" strings
+type FlexBool bool
+
+func (b *FlexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null", "":
+		*b = false
+	default:
+		return fmt.Errorf("appleid: invalid boolean claim %s", data)
+	}
+	return nil
+}
+
+// AppleIDTokenResponse is the response of the token endpoint
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi/tokenresponse
+type AppleIDTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+}
+
+// AppleIDError is an error response of the Sign in with Apple REST API, such as invalid_grant
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi/errorresponse
+type AppleIDError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *AppleIDError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("appleid: %s (%d): %s", e.Code, e.StatusCode, e.Description)
+	}
+	return fmt.Sprintf("appleid: %s (%d)", e.Code, e.StatusCode)
+}
+
+// AppleIDClient verifies identity tokens and calls the Sign in with Apple REST API
+type AppleIDClient struct {
+	Token     *Token
+	clientID  string
+	redirect  string
+	keySource AppleIDKeySource
+	httpCli   HTTPClient
+	hostUrl   string
+	now       func() time.Time
+}
+
+// NewAppleIDClient creates a Sign in with Apple client
+func NewAppleIDClient(config *AppleIDConfig) *AppleIDClient {
+	return NewAppleIDClientWithHTTPClient(config, &http.Client{Timeout: 30 * time.Second}, HostAppleID)
+}
+
+// NewAppleIDClientWithHTTPClient creates a Sign in with Apple client sending requests to hostUrl through httpClient
+func NewAppleIDClientWithHTTPClient(config *AppleIDConfig, httpClient HTTPClient, hostUrl string) *AppleIDClient {
+	// the client secret is an App Store style token whose subject is the client ID
+	token := &Token{
+		KeyContent: append(config.KeyContent[:0:0], config.KeyContent...),
+		KeyID:      config.KeyID,
+		Issuer:     config.TeamID,
+		Audience:   HostAppleID,
+		Subject:    config.ClientID,
+	}
+	keySource := config.KeySource
+	if keySource == nil {
+		keySource = NewRemoteKeySet(hostUrl+PathAppleIDKeys, httpClient)
+	}
+	return &AppleIDClient{
+		Token:     token,
+		clientID:  config.ClientID,
+		redirect:  config.RedirectURI,
+		keySource: keySource,
+		httpCli:   httpClient,
+		hostUrl:   hostUrl,
+		now:       time.Now,
+	}
+}
+
+// VerifyIdentityToken checks an identity token's signature, issuer, audience and expiry, and that its nonce
+// equals nonce, the value the app passed to the authorization request. An empty nonce expects a token
+// without one, so a token issued for a nonce cannot be used without presenting that nonce.
+func (c *AppleIDClient) VerifyIdentityToken(ctx context.Context, identityToken, nonce string) (*AppleIDClaims, error) {
+	claims := &AppleIDClaims{}
+	_, err := jwt.ParseWithClaims(identityToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.keySource.PublicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(HostAppleID),
+		jwt.WithAudience(c.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(c.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAppleIDInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrAppleIDInvalidToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrAppleIDNonce
+	}
+	return claims, nil
+}
+
+// ExchangeCode validates an authorization code, returning Apple's refresh token and an identity token
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi/generate_and_validate_tokens
+func (c *AppleIDClient) ExchangeCode(ctx context.Context, code string) (*AppleIDTokenResponse, error) {
+	form := url.Values{"code": {code}, "grant_type": {"authorization_code"}}
+	if c.redirect != "" {
+		form.Set("redirect_uri", c.redirect)
+	}
+	var rsp AppleIDTokenResponse
+	if err := c.post(ctx, PathAppleIDToken, form, &rsp); err != nil {
+		return nil, err
+	}
+	return &rsp, nil
+}
+
+// RevokeToken invalidates a refresh or access token, which Apple requires when a user deletes their account
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi/revoke_tokens
+func (c *AppleIDClient) RevokeToken(ctx context.Context, token, tokenTypeHint string) error {
+	form := url.Values{"token": {token}, "token_type_hint": {tokenTypeHint}}
+	return c.post(ctx, PathAppleIDRevoke, form, nil)
+}
+
+// post sends a form authenticated with the client ID and secret and decodes a successful response into out
+func (c *AppleIDClient) post(ctx context.Context, path string, form url.Values, out any) error {
+	secret, err := c.Token.GenerateIfExpired()
+	if err != nil {
+		return fmt.Errorf("appleid generate client secret err %w", err)
+	}
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", secret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.hostUrl+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("appleid new http request err %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return fmt.Errorf("appleid http client do err %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("appleid read http body err %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		rErr := &AppleIDError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(body, rErr); err != nil || rErr.Code == "" {
+			rErr.Code = http.StatusText(resp.StatusCode)
+		}
+		return rErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("appleid decode response err %w", err)
+	}
+	return nil
+}
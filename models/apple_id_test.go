@@ -0,0 +1,276 @@
+package models
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testAppleClientID = "com.example.app"
+
+type testAppleKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+func newTestAppleKey(t *testing.T, kid string) testAppleKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	return testAppleKey{kid: kid, key: key}
+}
+
+func (k testAppleKey) jwk() JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: k.kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}
+}
+
+func (k testAppleKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+func identityClaims(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            HostAppleID,
+		"aud":            testAppleClientID,
+		"sub":            "001234.abcdef.1234",
+		"iat":            now.Unix(),
+		"exp":            now.Add(10 * time.Minute).Unix(),
+		"nonce":          "n-0S6_WzA2Mj",
+		"email":          "user@privaterelay.appleid.com",
+		"email_verified": "true",
+	}
+}
+
+func keySetJSON(t *testing.T, keys ...testAppleKey) []byte {
+	t.Helper()
+	set := JWKSet{}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.jwk())
+	}
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return b
+}
+
+func TestVerifyIdentityToken(t *testing.T) {
+	key := newTestAppleKey(t, "apple-1")
+	keySet, err := NewStaticKeySet(keySetJSON(t, key))
+	if err != nil {
+		t.Fatalf("NewStaticKeySet() error = %v", err)
+	}
+	client := NewAppleIDClient(&AppleIDConfig{ClientID: testAppleClientID, KeySource: keySet})
+	now := time.Now()
+
+	claims, err := client.VerifyIdentityToken(context.Background(), key.sign(t, identityClaims(now)), "n-0S6_WzA2Mj")
+	if err != nil {
+		t.Fatalf("VerifyIdentityToken() error = %v", err)
+	}
+	if claims.Subject != "001234.abcdef.1234" || !claims.EmailVerified {
+		t.Errorf("VerifyIdentityToken() = sub %s email_verified %v, want the token's claims", claims.Subject, claims.EmailVerified)
+	}
+
+	with := func(name string, value any) jwt.MapClaims {
+		c := identityClaims(now)
+		c[name] = value
+		return c
+	}
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, identityClaims(now)).SignedString([]byte("secret"))
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr error
+	}{
+		{name: "other audience", token: key.sign(t, with("aud", "com.example.other")), wantErr: ErrAppleIDInvalidToken},
+		{name: "other issuer", token: key.sign(t, with("iss", "https://example.com")), wantErr: ErrAppleIDInvalidToken},
+		{name: "expired", token: key.sign(t, with("exp", now.Add(-time.Minute).Unix())), wantErr: ErrAppleIDInvalidToken},
+		{name: "no subject", token: key.sign(t, with("sub", "")), wantErr: ErrAppleIDInvalidToken},
+		{name: "unknown key", token: newTestAppleKey(t, "apple-2").sign(t, identityClaims(now)), wantErr: ErrAppleIDInvalidToken},
+		{name: "hmac signed", token: hmacToken, wantErr: ErrAppleIDInvalidToken},
+		{name: "other nonce", token: key.sign(t, identityClaims(now)), nonce: "replayed", wantErr: ErrAppleIDNonce},
+		{name: "nonce when none is expected", token: key.sign(t, identityClaims(now)), wantErr: ErrAppleIDNonce},
+		{name: "no nonce when one is expected", token: key.sign(t, with("nonce", "")), nonce: "n-0S6_WzA2Mj", wantErr: ErrAppleIDNonce},
+		{name: "no nonce expected", token: key.sign(t, with("nonce", ""))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.VerifyIdentityToken(context.Background(), tt.token, tt.nonce)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyIdentityToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	first, second := newTestAppleKey(t, "apple-1"), newTestAppleKey(t, "apple-2")
+	published := keySetJSON(t, first)
+	var fetches, failing atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(published)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	keySet := NewRemoteKeySet(srv.URL, srv.Client())
+	keySet.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := keySet.PublicKey(ctx, "apple-1"); err != nil {
+		t.Fatalf("PublicKey() error = %v", err)
+	}
+	if _, err := keySet.PublicKey(ctx, "apple-1"); err != nil || fetches.Load() != 1 {
+		t.Fatalf("PublicKey() of a cached key error = %v after %d fetches, want 1 fetch", err, fetches.Load())
+	}
+
+	// Apple rotates in a new key: unknown key ids refetch, at most once a minute
+	published = keySetJSON(t, first, second)
+	if _, err := keySet.PublicKey(ctx, "apple-2"); !errors.Is(err, ErrAppleIDUnknownKey) || fetches.Load() != 1 {
+		t.Errorf("PublicKey() right after a fetch error = %v after %d fetches, want %v without fetching", err, fetches.Load(), ErrAppleIDUnknownKey)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := keySet.PublicKey(ctx, "apple-2"); err != nil || fetches.Load() != 2 {
+		t.Errorf("PublicKey() of a rotated key error = %v after %d fetches, want 2 fetches", err, fetches.Load())
+	}
+
+	// once the cache is stale an outage falls back to the keys last fetched
+	failing.Store(1)
+	now = now.Add(48 * time.Hour)
+	if _, err := keySet.PublicKey(ctx, "apple-1"); err != nil {
+		t.Errorf("PublicKey() of a stale key during an outage error = %v", err)
+	}
+	if _, err := keySet.PublicKey(ctx, "apple-3"); err == nil {
+		t.Error("PublicKey() of an unknown key during an outage succeeded")
+	}
+}
+
+func TestAppleIDClient_ExchangeAndRevoke(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signingKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+
+	var forms []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		form := map[string]string{"path": r.URL.Path}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		forms = append(forms, form)
+
+		switch {
+		case r.URL.Path == PathAppleIDToken && form["code"] == "valid-code":
+			w.Write([]byte(`{"access_token":"a","token_type":"Bearer","expires_in":3600,"refresh_token":"r","id_token":"i"}`))
+		case r.URL.Path == PathAppleIDRevoke:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"The code has expired or has been revoked."}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewAppleIDClientWithHTTPClient(&AppleIDConfig{
+		ClientID:    testAppleClientID,
+		TeamID:      "TEAM123456",
+		KeyID:       "KEY1234567",
+		KeyContent:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		RedirectURI: "https://example.com/callback",
+	}, srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	rsp, err := client.ExchangeCode(ctx, "valid-code")
+	if err != nil {
+		t.Fatalf("ExchangeCode() error = %v", err)
+	}
+	if rsp.RefreshToken != "r" || rsp.IDToken != "i" {
+		t.Errorf("ExchangeCode() = %+v, want the token response", rsp)
+	}
+
+	_, err = client.ExchangeCode(ctx, "expired-code")
+	var appleErr *AppleIDError
+	if !errors.As(err, &appleErr) || appleErr.Code != "invalid_grant" || appleErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("ExchangeCode() of an expired code error = %v, want invalid_grant", err)
+	}
+
+	if err := client.RevokeToken(ctx, "r", "refresh_token"); err != nil {
+		t.Errorf("RevokeToken() error = %v", err)
+	}
+
+	if len(forms) != 3 {
+		t.Fatalf("server received %d requests, want 3", len(forms))
+	}
+	if forms[0]["grant_type"] != "authorization_code" || forms[0]["redirect_uri"] != "https://example.com/callback" {
+		t.Errorf("token request = %v, want an authorization_code grant with the redirect URI", forms[0])
+	}
+	if forms[2]["token"] != "r" || forms[2]["token_type_hint"] != "refresh_token" {
+		t.Errorf("revoke request = %v, want the refresh token", forms[2])
+	}
+
+	// every request carries the client ID and an ES256 client secret issued by the team for the client ID
+	for _, form := range forms {
+		if form["client_id"] != testAppleClientID {
+			t.Errorf("%s client_id = %q, want %q", form["path"], form["client_id"], testAppleClientID)
+		}
+		claims := jwt.MapClaims{}
+		secret, err := jwt.ParseWithClaims(form["client_secret"], claims, func(*jwt.Token) (interface{}, error) {
+			return &signingKey.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(HostAppleID), jwt.WithIssuer("TEAM123456"), jwt.WithSubject(testAppleClientID))
+		if err != nil {
+			t.Errorf("%s client_secret error = %v", form["path"], err)
+			continue
+		}
+		if secret.Header["kid"] != "KEY1234567" {
+			t.Errorf("%s client_secret kid = %v, want KEY1234567", form["path"], secret.Header["kid"])
+		}
+		if _, ok := claims["bid"]; ok {
+			t.Errorf("%s client_secret has an App Store bid claim", form["path"])
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAppleIDUnknownKey = errors.New("appleid: identity token signed with an unknown key")
+	ErrAppleIDInvalidKey = errors.New("appleid: invalid JSON web key")
+)
+
+const (
+	// defaultKeySetTTL is how long fetched Apple keys are used before they are fetched again
+	defaultKeySetTTL = 24 * time.Hour
+	// defaultKeySetMinRefresh is the least time between fetches, so tokens with made-up key ids cannot flood Apple
+	defaultKeySetMinRefresh = time.Minute
+)
+
+// JWK is an RSA public key in the JSON Web Key Set Apple publishes
+// Doc: https://developer.apple.com/documentation/signinwithapplerestapi/jwkset/keys
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSet is the response of https://appleid.apple.com/auth/keys
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// AppleIDKeySource looks up the public key an identity token names in its kid header
+type AppleIDKeySource interface {
+	PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// PublicKey decodes the RSA key of a JWK
+func (k JWK) PublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("%w: kty %q", ErrAppleIDInvalidKey, k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("%w: modulus of %s", ErrAppleIDInvalidKey, k.Kid)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("%w: exponent of %s", ErrAppleIDInvalidKey, k.Kid)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// publicKeys indexes the RSA keys of a set by key id
+func (s *JWKSet) publicKeys() (map[string]*rsa.PublicKey, error) {
+	keys := make(map[string]*rsa.PublicKey, len(s.Keys))
+	for _, k := range s.Keys {
+		key, err := k.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// StaticKeySet is a fixed set of keys, for tests and for sandbox deployments that sign their own identity tokens
+type StaticKeySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// NewStaticKeySet parses a JSON Web Key Set
+func NewStaticKeySet(jwks []byte) (*StaticKeySet, error) {
+	var set JWKSet
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAppleIDInvalidKey, err)
+	}
+	keys, err := set.publicKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+func (s *StaticKeySet) PublicKey(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrAppleIDUnknownKey
+	}
+	return key, nil
+}
+
+// RemoteKeySet fetches Apple's public keys and caches them. A key id missing from the cache triggers
+// a refetch, since Apple rotates keys, and Apple being unreachable falls back to the keys last fetched.
+type RemoteKeySet struct {
+	URL        string
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	httpCli HTTPClient
+	now     func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet creates a key set fetched from url through httpClient
+func NewRemoteKeySet(url string, httpClient HTTPClient) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:        url,
+		TTL:        defaultKeySetTTL,
+		MinRefresh: defaultKeySetMinRefresh,
+		httpCli:    httpClient,
+		now:        time.Now,
+	}
+}
+
+func (s *RemoteKeySet) PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key, cached := s.keys[kid]
+	age := now.Sub(s.fetchedAt)
+	if cached && age < s.TTL {
+		return key, nil
+	}
+	if !cached && s.keys != nil && age < s.MinRefresh {
+		return nil, ErrAppleIDUnknownKey
+	}
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		if cached {
+			return key, nil
+		}
+		return nil, err
+	}
+	s.keys, s.fetchedAt = keys, now
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrAppleIDUnknownKey
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("appleid new http request err %w", err)
+	}
+	resp, err := s.httpCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("appleid fetch keys err %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("appleid fetch keys: received invalid status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("appleid read http body err %w", err)
+	}
+	var set JWKSet
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("appleid decode keys err %w", err)
+	}
+	return set.publicKeys()
+}
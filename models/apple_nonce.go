@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AppleNonce is a nonce issued for one Sign in with Apple request, kept only as the SHA-256 hash of the
+// nonce handed out. It is deleted when a sign in uses it, so an identity token cannot be replayed.
+type AppleNonce struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	NonceHash string             `bson:"nonceHash" json:"-"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
+
+func (n *AppleNonce) CollectionName() string {
+	return "appleNonces"
+}
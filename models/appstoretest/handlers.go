@@ -0,0 +1,668 @@
+package appstoretest
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"simvizlab-backend/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxExtendByDays is the longest renewal date extension Apple grants at once
+	maxExtendByDays = 90
+	// maxExtensionsPerYear is how many renewal date extensions a subscription can get in a year
+	maxExtensionsPerYear = 2
+	// notificationHistoryDays is how far back notification history can be requested
+	notificationHistoryDays = 180
+
+	ownershipFamilyShared = "FAMILY_SHARED"
+)
+
+// productTypes maps the productType history filter to transaction types
+var productTypes = map[string]models.IAPType{
+	"AUTO_RENEWABLE": models.AutoRenewable,
+	"NON_RENEWABLE":  models.NonRenewable,
+	"CONSUMABLE":     models.Consumable,
+	"NON_CONSUMABLE": models.NonConsumable,
+}
+
+// transaction returns the seeded transaction with transactionId. The caller holds s.mu.
+func (s *Server) transaction(transactionId string) *models.JWSTransaction {
+	for _, tx := range s.transactions {
+		if tx.TransactionID == transactionId {
+			return tx
+		}
+	}
+	return nil
+}
+
+// customer returns the transactions sharing an original transaction id with transactionId in environment,
+// in the order they were seeded. The fake treats each original transaction as its own customer.
+// The caller holds s.mu.
+func (s *Server) customer(environment models.Environment, transactionId string) []*models.JWSTransaction {
+	tx := s.transaction(transactionId)
+	if tx == nil || tx.Environment != environment {
+		return nil
+	}
+	var history []*models.JWSTransaction
+	for _, candidate := range s.transactions {
+		if candidate.OriginalTransactionId == tx.OriginalTransactionId && candidate.Environment == environment {
+			history = append(history, candidate)
+		}
+	}
+	return history
+}
+
+// latest returns the transaction with the latest purchase date
+func latest(transactions []*models.JWSTransaction) *models.JWSTransaction {
+	var last *models.JWSTransaction
+	for _, tx := range transactions {
+		if last == nil || tx.PurchaseDate >= last.PurchaseDate {
+			last = tx
+		}
+	}
+	return last
+}
+
+// signTransactions signs each transaction, failing the request on error
+func (s *Server) signTransactions(w http.ResponseWriter, transactions []*models.JWSTransaction) ([]string, bool) {
+	signed := make([]string, 0, len(transactions))
+	for _, tx := range transactions {
+		jws, err := s.Chain.SignTransaction(tx)
+		if err != nil {
+			writeError(w, models.GeneralInternalError)
+			return nil, false
+		}
+		signed = append(signed, jws)
+	}
+	return signed, true
+}
+
+// page cuts a page out of items starting at the offset encoded in token, returning the token of the next page
+func page[T any](items []T, token string, size int) ([]T, string, bool, bool) {
+	offset := 0
+	if token != "" {
+		parsed, err := strconv.Atoi(token)
+		if err != nil || parsed < 0 || parsed > len(items) {
+			return nil, "", false, false
+		}
+		offset = parsed
+	}
+	end := min(offset+size, len(items))
+	return items[offset:end], strconv.Itoa(end), end < len(items), true
+}
+
+func (s *Server) getTransactionInfo(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	s.mu.Lock()
+	tx := s.transaction(r.PathValue("transactionId"))
+	s.mu.Unlock()
+
+	if tx == nil || tx.Environment != environment {
+		writeError(w, models.TransactionIdNotFoundError)
+		return
+	}
+	signed, ok := s.signTransactions(w, []*models.JWSTransaction{tx})
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.TransactionInfoResponse{SignedTransactionInfo: signed[0]})
+}
+
+func (s *Server) lookUpOrder(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	s.mu.Lock()
+	var transactions []*models.JWSTransaction
+	for _, transactionId := range s.orders[r.PathValue("orderId")] {
+		if tx := s.transaction(transactionId); tx != nil && tx.Environment == environment {
+			transactions = append(transactions, tx)
+		}
+	}
+	s.mu.Unlock()
+
+	signed, ok := s.signTransactions(w, transactions)
+	if !ok {
+		return
+	}
+	// an order id Apple does not know is reported with status 1 rather than an error
+	status := 0
+	if len(signed) == 0 {
+		status = 1
+	}
+	writeJSON(w, http.StatusOK, models.OrderLookupResponse{Status: status, SignedTransactions: signed})
+}
+
+func (s *Server) getTransactionHistory(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	query := r.URL.Query()
+
+	sort := query.Get("sort")
+	if sort != "" && sort != "ASCENDING" && sort != "DESCENDING" {
+		writeError(w, models.InvalidSortError)
+		return
+	}
+	var types []models.IAPType
+	for _, productType := range query["productType"] {
+		t, ok := productTypes[productType]
+		if !ok {
+			writeError(w, models.InvalidProductTypeError)
+			return
+		}
+		types = append(types, t)
+	}
+	revoked := query.Get("revoked")
+	if revoked != "" && revoked != "true" && revoked != "false" {
+		writeError(w, models.InvalidRevokedError)
+		return
+	}
+	var startDate, endDate int64
+	if v := query.Get("startDate"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, models.InvalidStartDateError)
+			return
+		}
+		startDate = parsed
+	}
+	if v := query.Get("endDate"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, models.InvalidEndDateError)
+			return
+		}
+		endDate = parsed
+	}
+
+	s.mu.Lock()
+	customer := s.customer(environment, r.PathValue("originalTransactionId"))
+	s.mu.Unlock()
+	if customer == nil {
+		writeError(w, models.TransactionIdNotFoundError)
+		return
+	}
+
+	var history []*models.JWSTransaction
+	for _, tx := range customer {
+		switch {
+		case len(types) > 0 && !slices.Contains(types, tx.Type):
+		case len(query["productId"]) > 0 && !slices.Contains(query["productId"], tx.ProductID):
+		case len(query["subscriptionGroupIdentifier"]) > 0 && !slices.Contains(query["subscriptionGroupIdentifier"], tx.SubscriptionGroupIdentifier):
+		case revoked != "" && (tx.RevocationDate != 0) != (revoked == "true"):
+		case startDate != 0 && tx.PurchaseDate < startDate:
+		case endDate != 0 && tx.PurchaseDate >= endDate:
+		default:
+			history = append(history, tx)
+		}
+	}
+	slices.SortStableFunc(history, func(a, b *models.JWSTransaction) int {
+		if sort == "DESCENDING" {
+			a, b = b, a
+		}
+		return cmp.Compare(a.PurchaseDate, b.PurchaseDate)
+	})
+
+	items, revision, hasMore, ok := page(history, query.Get("revision"), s.PageSize)
+	if !ok {
+		writeError(w, models.InvalidRequestRevisionError)
+		return
+	}
+	signed, ok := s.signTransactions(w, items)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.HistoryResponse{
+		AppAppleId:         AppAppleID,
+		BundleId:           BundleID,
+		Environment:        environment,
+		HasMore:            hasMore,
+		Revision:           revision,
+		SignedTransactions: signed,
+	})
+}
+
+func (s *Server) getRefundHistory(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	s.mu.Lock()
+	customer := s.customer(environment, r.PathValue("originalTransactionId"))
+	s.mu.Unlock()
+	if customer == nil {
+		writeError(w, models.TransactionIdNotFoundError)
+		return
+	}
+
+	var refunded []*models.JWSTransaction
+	for _, tx := range customer {
+		if tx.RevocationDate != 0 {
+			refunded = append(refunded, tx)
+		}
+	}
+	slices.SortStableFunc(refunded, func(a, b *models.JWSTransaction) int {
+		return cmp.Compare(a.RevocationDate, b.RevocationDate)
+	})
+
+	items, revision, hasMore, ok := page(refunded, r.URL.Query().Get("revision"), s.PageSize)
+	if !ok {
+		writeError(w, models.InvalidRequestRevisionError)
+		return
+	}
+	signed, ok := s.signTransactions(w, items)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.RefundLookupResponse{HasMore: hasMore, Revision: revision, SignedTransactions: signed})
+}
+
+// subscriptionStatus derives Apple's subscription status from a transaction: 1 active, 2 expired, 5 revoked
+func subscriptionStatus(tx *models.JWSTransaction, now int64) int32 {
+	switch {
+	case tx.RevocationDate != 0:
+		return 5
+	case tx.ExpiresDate > now:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (s *Server) getAllSubscriptionStatuses(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var filter []int32
+	for _, v := range r.URL.Query()["status"] {
+		status, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || status < 1 || status > 5 {
+			writeError(w, models.InvalidStatusError)
+			return
+		}
+		filter = append(filter, int32(status))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	customer := s.customer(environment, r.PathValue("originalTransactionId"))
+	if customer == nil {
+		writeError(w, models.TransactionIdNotFoundError)
+		return
+	}
+
+	var subscriptions []*models.JWSTransaction
+	for _, tx := range customer {
+		if tx.Type == models.AutoRenewable {
+			subscriptions = append(subscriptions, tx)
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	groups := []models.SubscriptionGroupIdentifierItem{}
+	if tx := latest(subscriptions); tx != nil {
+		status, ok := s.statuses[tx.OriginalTransactionId]
+		if !ok {
+			status = subscriptionStatus(tx, now)
+		}
+		if len(filter) > 0 && !slices.Contains(filter, status) {
+			writeJSON(w, http.StatusOK, models.StatusResponse{Environment: environment, AppAppleId: AppAppleID, BundleId: BundleID, Data: groups})
+			return
+		}
+
+		renewal := s.renewals[tx.OriginalTransactionId]
+		if renewal == nil {
+			renewal = &models.JWSRenewalInfoDecodedPayload{
+				AutoRenewProductId:          tx.ProductID,
+				Environment:                 environment,
+				OriginalTransactionId:       tx.OriginalTransactionId,
+				ProductId:                   tx.ProductID,
+				RecentSubscriptionStartDate: tx.OriginalPurchaseDate,
+				RenewalDate:                 tx.ExpiresDate,
+			}
+			if status == 1 {
+				renewal.AutoRenewStatus = 1
+			}
+		}
+
+		signedTransaction, err := s.Chain.SignTransaction(tx)
+		if err != nil {
+			writeError(w, models.GeneralInternalError)
+			return
+		}
+		signedRenewal, err := s.Chain.SignRenewalInfo(renewal)
+		if err != nil {
+			writeError(w, models.GeneralInternalError)
+			return
+		}
+		groups = append(groups, models.SubscriptionGroupIdentifierItem{
+			SubscriptionGroupIdentifier: tx.SubscriptionGroupIdentifier,
+			LastTransactions: []models.LastTransactionsItem{{
+				OriginalTransactionId: tx.OriginalTransactionId,
+				Status:                status,
+				SignedRenewalInfo:     signedRenewal,
+				SignedTransactionInfo: signedTransaction,
+			}},
+		})
+	}
+	writeJSON(w, http.StatusOK, models.StatusResponse{Environment: environment, AppAppleId: AppAppleID, BundleId: BundleID, Data: groups})
+}
+
+func (s *Server) sendConsumptionInfo(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var body models.ConsumptionRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.GeneralBadRequestError)
+		return
+	}
+	if !body.CustomerConsented {
+		writeError(w, models.InvalidCustomerConsentedError)
+		return
+	}
+	if body.AppAccountToken != "" && uuid.Validate(body.AppAccountToken) != nil {
+		writeError(w, models.InvalidAppAccountTokenError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.transaction(r.PathValue("originalTransactionId"))
+	if tx == nil || tx.Environment != environment {
+		writeError(w, models.TransactionIdNotFoundError)
+		return
+	}
+	s.consumption[tx.OriginalTransactionId] = append(s.consumption[tx.OriginalTransactionId], body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// extendable reports why a subscription cannot get a renewal date extension, if it cannot
+func extendable(tx *models.JWSTransaction, now int64) *models.Error {
+	switch {
+	case tx.InAppOwnershipType == ownershipFamilyShared:
+		return models.FamilySharedSubscriptionExtensionIneligibleError
+	case tx.Type != models.AutoRenewable || subscriptionStatus(tx, now) != 1:
+		return models.SubscriptionExtensionIneligibleError
+	}
+	return nil
+}
+
+// validExtension checks the shared fields of a renewal date extension request
+func validExtension(extendByDays, extendReasonCode int32, requestIdentifier string) *models.Error {
+	switch {
+	case extendByDays < 1 || extendByDays > maxExtendByDays:
+		return models.InvalidExtendByDaysError
+	case extendReasonCode < models.UndeclaredExtendReasonCode || extendReasonCode > models.ServiceIssueOrOutage:
+		return models.InvalidExtendReasonCodeError
+	case requestIdentifier == "":
+		return models.InvalidRequestIdentifierError
+	}
+	return nil
+}
+
+func (s *Server) extendRenewalDate(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var body models.ExtendRenewalDateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.GeneralBadRequestError)
+		return
+	}
+	if apiErr := validExtension(body.ExtendByDays, int32(body.ExtendReasonCode), body.RequestIdentifier); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := latest(s.customer(environment, r.PathValue("originalTransactionId")))
+	if tx == nil {
+		writeError(w, models.OriginalTransactionIdNotFoundError)
+		return
+	}
+	if apiErr := extendable(tx, time.Now().UnixMilli()); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if s.extensions[tx.OriginalTransactionId] >= maxExtensionsPerYear {
+		writeError(w, models.SubscriptionMaxExtensionError)
+		return
+	}
+
+	s.extensions[tx.OriginalTransactionId]++
+	tx.ExpiresDate += int64(body.ExtendByDays) * int64(24*time.Hour/time.Millisecond)
+	writeJSON(w, http.StatusOK, models.ExtendRenewalDateResponse{
+		EffectiveDate:         tx.ExpiresDate,
+		OriginalTransactionId: tx.OriginalTransactionId,
+		Success:               true,
+		WebOrderLineItemId:    tx.WebOrderLineItemId,
+	})
+}
+
+// massExtensionKey identifies a mass extension request by environment, product and request identifier
+func massExtensionKey(environment models.Environment, productId, requestIdentifier string) string {
+	return string(environment) + "/" + productId + "/" + requestIdentifier
+}
+
+// massExtendRenewalDate extends every active subscription to the product at once and completes immediately
+func (s *Server) massExtendRenewalDate(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var body models.MassExtendRenewalDateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.GeneralBadRequestError)
+		return
+	}
+	if apiErr := validExtension(body.ExtendByDays, body.ExtendReasonCode, body.RequestIdentifier); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if body.ProductId == "" {
+		writeError(w, models.InvalidProductIdError)
+		return
+	}
+	if body.StorefrontCountryCodes != nil && len(body.StorefrontCountryCodes) == 0 {
+		writeError(w, models.InvalidEmptyStorefrontCountryCodeListError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := map[string]*models.JWSTransaction{}
+	for _, tx := range s.transactions {
+		if tx.Environment != environment || tx.ProductID != body.ProductId {
+			continue
+		}
+		if last := subscriptions[tx.OriginalTransactionId]; last == nil || tx.PurchaseDate >= last.PurchaseDate {
+			subscriptions[tx.OriginalTransactionId] = tx
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	status := &models.MassExtendRenewalDateStatusResponse{RequestIdentifier: body.RequestIdentifier, Complete: true, CompleteDate: now}
+	for _, tx := range subscriptions {
+		if len(body.StorefrontCountryCodes) > 0 && !slices.Contains(body.StorefrontCountryCodes, tx.Storefront) {
+			continue
+		}
+		if subscriptionStatus(tx, now) != 1 {
+			continue
+		}
+		if extendable(tx, now) != nil || s.extensions[tx.OriginalTransactionId] >= maxExtensionsPerYear {
+			status.FailedCount++
+			continue
+		}
+		s.extensions[tx.OriginalTransactionId]++
+		tx.ExpiresDate += int64(body.ExtendByDays) * int64(24*time.Hour/time.Millisecond)
+		status.SucceededCount++
+	}
+	s.massExtensions[massExtensionKey(environment, body.ProductId, body.RequestIdentifier)] = status
+
+	writeJSON(w, http.StatusOK, map[string]string{"requestIdentifier": body.RequestIdentifier})
+}
+
+func (s *Server) getMassExtensionStatus(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	s.mu.Lock()
+	status := s.massExtensions[massExtensionKey(environment, r.PathValue("productId"), r.PathValue("requestIdentifier"))]
+	s.mu.Unlock()
+
+	if status == nil {
+		writeError(w, models.StatusRequestNotFoundError)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) getNotificationHistory(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var body models.NotificationHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.GeneralBadRequestError)
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case body.StartDate <= 0:
+		writeError(w, models.InvalidStartDateError)
+		return
+	case body.EndDate <= 0:
+		writeError(w, models.InvalidEndDateError)
+		return
+	case body.EndDate <= body.StartDate:
+		writeError(w, models.StartDateAfterEndDateError)
+		return
+	case body.StartDate < now.AddDate(0, 0, -notificationHistoryDays).UnixMilli():
+		writeError(w, models.StartDateTooFarInPastError)
+		return
+	case body.TransactionId != "" && body.OriginalTransactionId != "":
+		writeError(w, models.MultipleFiltersSuppliedError)
+		return
+	case body.TransactionId != "" && body.NotificationType != "":
+		writeError(w, models.MultipleFiltersSuppliedError)
+		return
+	case body.NotificationSubtype != "" && body.NotificationType == "":
+		writeError(w, models.InvalidNotificationTypeError)
+		return
+	}
+
+	s.mu.Lock()
+	// a transaction id selects the notifications of its whole subscription
+	originalTransactionId, filtered := body.OriginalTransactionId, body.OriginalTransactionId != ""
+	if body.TransactionId != "" {
+		filtered = true
+		if tx := s.transaction(body.TransactionId); tx != nil {
+			originalTransactionId = tx.OriginalTransactionId
+		}
+	}
+	var history []*Notification
+	for _, n := range s.notifications {
+		p := n.Payload
+		switch {
+		case models.Environment(p.Data.Environment) != environment:
+		case p.SignedDate < body.StartDate || p.SignedDate >= body.EndDate:
+		case body.NotificationType != "" && p.NotificationType != string(body.NotificationType):
+		case body.NotificationSubtype != "" && p.Subtype != string(body.NotificationSubtype):
+		case body.OnlyFailures && n.Result == models.FirstSendAttemptResultSuccess:
+		case filtered && (n.Transaction == nil || n.Transaction.OriginalTransactionId != originalTransactionId):
+		default:
+			history = append(history, n)
+		}
+	}
+	s.mu.Unlock()
+	slices.SortStableFunc(history, func(a, b *Notification) int {
+		return cmp.Compare(a.Payload.SignedDate, b.Payload.SignedDate)
+	})
+
+	items, token, hasMore, ok := page(history, r.URL.Query().Get("paginationToken"), s.PageSize)
+	if !ok {
+		writeError(w, models.InvalidPaginationTokenError)
+		return
+	}
+	rsp := models.NotificationHistoryResponses{HasMore: hasMore, NotificationHistory: []models.NotificationHistoryResponseItem{}}
+	if hasMore {
+		rsp.PaginationToken = token
+	}
+	for _, n := range items {
+		signed, err := s.Chain.SignNotification(n.Payload)
+		if err != nil {
+			writeError(w, models.GeneralInternalError)
+			return
+		}
+		rsp.NotificationHistory = append(rsp.NotificationHistory, models.NotificationHistoryResponseItem{
+			SignedPayload:          signed,
+			FirstSendAttemptResult: n.Result,
+			SendAttempts:           []models.SendAttemptItem{{AttemptDate: n.Payload.SignedDate, SendAttemptResult: n.Result}},
+		})
+	}
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+func (s *Server) requestTestNotification(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	token := uuid.NewString()
+	payload := &models.NotificationPayload{
+		NotificationType: string(models.NotificationTypeV2Test),
+		SignedDate:       time.Now().UnixMilli(),
+		Data: models.NotificationData{
+			AppAppleID:  int(AppAppleID),
+			BundleID:    BundleID,
+			Environment: string(environment),
+		},
+	}
+	signed, err := s.Chain.SignNotification(payload)
+	if err != nil {
+		writeError(w, models.GeneralInternalError)
+		return
+	}
+
+	s.mu.Lock()
+	s.testNotifications[token] = &models.CheckTestNotificationResponse{
+		SignedPayload:          signed,
+		FirstSendAttemptResult: s.TestNotificationResult,
+		SendAttempts:           []models.SendAttemptItem{{AttemptDate: payload.SignedDate, SendAttemptResult: s.TestNotificationResult}},
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, models.SendTestNotificationResponse{TestNotificationToken: token})
+}
+
+func (s *Server) getTestNotificationStatus(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	token := r.PathValue("testNotificationToken")
+	if uuid.Validate(token) != nil {
+		writeError(w, models.InvalidTestNotificationTokenError)
+		return
+	}
+
+	s.mu.Lock()
+	status := s.testNotifications[token]
+	s.mu.Unlock()
+
+	if status == nil {
+		writeError(w, models.TestNotificationNotFoundError)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) setAppAccountToken(w http.ResponseWriter, r *http.Request, environment models.Environment) {
+	var body models.UpdateAppAccountTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.GeneralBadRequestError)
+		return
+	}
+	if body.AppAccountToken != "" && uuid.Validate(body.AppAccountToken) != nil {
+		writeError(w, models.InvalidAppAccountTokenUUIDError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.transaction(r.PathValue("originalTransactionId"))
+	switch {
+	case tx == nil || tx.Environment != environment:
+		writeError(w, models.OriginalTransactionIdNotFoundError)
+		return
+	case tx.TransactionID != tx.OriginalTransactionId:
+		writeError(w, models.TransactionIdIsNotOriginalTransactionIdError)
+		return
+	case tx.InAppOwnershipType == ownershipFamilyShared:
+		writeError(w, models.FamilyTransactionNotSupportedError)
+		return
+	}
+
+	for _, candidate := range s.customer(environment, tx.TransactionID) {
+		candidate.AppAccountToken = body.AppAccountToken
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,451 @@
+// Package appstoretest provides an in-process fake of the App Store Server API for tests.
+//
+// The fake serves the endpoints of models.StoreClient from seeded transactions, signs every
+// JWS with a generated certificate chain and checks the bearer token of each request.
+// Point a client at it with NewStoreClient, or pass Client to models.NewStoreClientWithHTTPClient:
+// requests keep their Apple host, which tells the fake the environment, and are sent to the fake.
+package appstoretest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+	"simvizlab-backend/models/fixtures"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// BundleID is the bundle every seeded transaction and issued token belongs to
+	BundleID = "com.example.appstoretest"
+	// AppAppleID is the App Apple ID reported in responses
+	AppAppleID int64 = 1234567890
+	// KeyID and IssuerID identify the generated App Store Connect API key
+	KeyID    = "TESTKEY001"
+	IssuerID = "57246542-96fe-1a63-e053-0824d011072a"
+
+	// DefaultPageSize is how many items a history page holds unless PageSize is set
+	DefaultPageSize = 20
+)
+
+// Request is a request the fake served
+type Request struct {
+	Method      string
+	Path        string // the models.Path* template the request matched
+	URL         *url.URL
+	Environment models.Environment
+}
+
+// Fault scripts an error response. A zero Method or Path matches any method or endpoint,
+// and Times of zero keeps the fault for every matching request.
+type Fault struct {
+	Method     string
+	Path       string        // a models.Path* template
+	Err        *models.Error // sent as the errorCode and errorMessage body, with the status Apple uses for its code
+	Status     int           // overrides the status derived from Err; a bare status sends no body
+	RetryAfter int           // seconds, sent as the Retry-After header
+	Times      int
+}
+
+// Notification is a notification in the notification history. Transaction, when set, is what the
+// transactionId and originalTransactionId filters match and is signed into the payload data.
+type Notification struct {
+	Payload     *models.NotificationPayload
+	Transaction *models.JWSTransaction
+	Result      models.FirstSendAttemptResult
+}
+
+// Server is a fake App Store Server API. Its zero value is not usable; create one with NewServer.
+type Server struct {
+	// URL is the base URL the fake listens on
+	URL string
+	// Chain signs every JWS the fake returns
+	Chain *fixtures.Chain
+	// PageSize bounds the items per page of transaction, refund and notification history
+	PageSize int
+	// TestNotificationResult is the send result reported for test notifications
+	TestNotificationResult models.FirstSendAttemptResult
+
+	server  *httptest.Server
+	authKey *ecdsa.PrivateKey
+
+	mu                sync.Mutex
+	transactions      []*models.JWSTransaction
+	renewals          map[string]*models.JWSRenewalInfoDecodedPayload
+	statuses          map[string]int32
+	orders            map[string][]string
+	notifications     []*Notification
+	testNotifications map[string]*models.CheckTestNotificationResponse
+	massExtensions    map[string]*models.MassExtendRenewalDateStatusResponse
+	extensions        map[string]int
+	consumption       map[string][]models.ConsumptionRequestBody
+	faults            []*Fault
+	requests          []Request
+}
+
+// NewServer starts a fake App Store Server API, closed when the test ends
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+
+	now := time.Now()
+	chain, err := fixtures.NewChain(now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	if err != nil {
+		tb.Fatalf("appstoretest: failed to generate certificate chain: %v", err)
+	}
+	authKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatalf("appstoretest: failed to generate API key: %v", err)
+	}
+
+	s := &Server{
+		Chain:                  chain,
+		PageSize:               DefaultPageSize,
+		TestNotificationResult: models.FirstSendAttemptResultSuccess,
+		authKey:                authKey,
+		renewals:               map[string]*models.JWSRenewalInfoDecodedPayload{},
+		statuses:               map[string]int32{},
+		orders:                 map[string][]string{},
+		testNotifications:      map[string]*models.CheckTestNotificationResponse{},
+		massExtensions:         map[string]*models.MassExtendRenewalDateStatusResponse{},
+		extensions:             map[string]int{},
+		consumption:            map[string][]models.ConsumptionRequestBody{},
+	}
+	s.server = httptest.NewServer(s.routes())
+	s.URL = s.server.URL
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Close shuts the fake down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// KeyContent returns the generated App Store Connect API key as a .p8 PEM file
+func (s *Server) KeyContent() []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(s.authKey)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// Config returns a StoreConfig for environment that authenticates with the fake and trusts its chain
+func (s *Server) Config(environment models.Environment) *models.StoreConfig {
+	return &models.StoreConfig{
+		KeyContent:      s.KeyContent(),
+		KeyID:           KeyID,
+		BundleID:        BundleID,
+		Issuer:          IssuerID,
+		Sandbox:         environment == models.Sandbox,
+		TrustedCertPool: s.Chain.CertPool(),
+	}
+}
+
+// Client returns an HTTPClient sending every request to the fake. The Apple host is kept as the Host header.
+func (s *Server) Client() models.HTTPClient {
+	target, _ := url.Parse(s.URL)
+	client := s.server.Client()
+	return models.DoFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Host = req.URL.Host
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return client.Do(req)
+	})
+}
+
+// NewStoreClient returns a StoreClient for environment talking to the fake
+func (s *Server) NewStoreClient(environment models.Environment) *models.StoreClient {
+	return models.NewStoreClientWithHTTPClient(s.Config(environment), s.Client())
+}
+
+// AddTransaction seeds a transaction. A missing bundle id, environment or original transaction id is filled in,
+// and the transaction replaces any seeded one with the same transaction id.
+func (s *Server) AddTransaction(transaction *models.JWSTransaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := *transaction
+	if tx.BundleID == "" {
+		tx.BundleID = BundleID
+	}
+	if tx.Environment == "" {
+		tx.Environment = models.Production
+	}
+	if tx.OriginalTransactionId == "" {
+		tx.OriginalTransactionId = tx.TransactionID
+	}
+	for i, existing := range s.transactions {
+		if existing.TransactionID == tx.TransactionID {
+			s.transactions[i] = &tx
+			return
+		}
+	}
+	s.transactions = append(s.transactions, &tx)
+}
+
+// Transaction returns a copy of a seeded transaction as the fake currently holds it
+func (s *Server) Transaction(transactionId string) (*models.JWSTransaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tx := s.transaction(transactionId); tx != nil {
+		copied := *tx
+		return &copied, true
+	}
+	return nil, false
+}
+
+// SetSubscriptionStatus overrides the status and renewal info reported for a subscription.
+// Without it the status follows the latest transaction and auto-renew is on.
+func (s *Server) SetSubscriptionStatus(originalTransactionId string, status int32, renewalInfo *models.JWSRenewalInfoDecodedPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statuses[originalTransactionId] = status
+	if renewalInfo != nil {
+		s.renewals[originalTransactionId] = renewalInfo
+	}
+}
+
+// AddOrder makes an order id look up the given transactions
+func (s *Server) AddOrder(orderId string, transactionIds ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[orderId] = append(s.orders[orderId], transactionIds...)
+}
+
+// AddNotification adds a notification to the notification history. The payload's signedDate defaults to now,
+// its notificationUUID to a new one, its data to the transaction's bundle and environment, and a zero result means it was delivered.
+func (s *Server) AddNotification(notification Notification) error {
+	payload := *notification.Payload
+	if payload.SignedDate == 0 {
+		payload.SignedDate = time.Now().UnixMilli()
+	}
+	if payload.NotificationUUID == "" {
+		payload.NotificationUUID = uuid.NewString()
+	}
+	if payload.Data.BundleID == "" {
+		payload.Data.BundleID = BundleID
+		payload.Data.AppAppleID = int(AppAppleID)
+	}
+	if tx := notification.Transaction; tx != nil {
+		if payload.Data.Environment == "" {
+			payload.Data.Environment = string(tx.Environment)
+		}
+		if payload.Data.SignedTransactionInfo == "" {
+			signed, err := s.Chain.SignTransaction(tx)
+			if err != nil {
+				return err
+			}
+			payload.Data.SignedTransactionInfo = signed
+		}
+	}
+	if payload.Data.Environment == "" {
+		payload.Data.Environment = string(models.Production)
+	}
+	notification.Payload = &payload
+	if notification.Result == "" {
+		notification.Result = models.FirstSendAttemptResultSuccess
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notifications = append(s.notifications, &notification)
+	return nil
+}
+
+// ConsumptionInfo returns the consumption information sent for an original transaction
+func (s *Server) ConsumptionInfo(originalTransactionId string) []models.ConsumptionRequestBody {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.ConsumptionRequestBody(nil), s.consumption[originalTransactionId]...)
+}
+
+// Fail scripts an error response for the requests fault matches
+func (s *Server) Fail(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := fault
+	s.faults = append(s.faults, &f)
+}
+
+// RateLimit answers the next times requests to path with Apple's 429 rate limit error
+func (s *Server) RateLimit(path string, times, retryAfter int) {
+	s.Fail(Fault{Path: path, Err: models.RateLimitExceededError, RetryAfter: retryAfter, Times: times})
+}
+
+// Requests returns the requests served so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// endpoint serves one models.Path* template
+type endpoint struct {
+	method  string
+	path    string
+	handler func(http.ResponseWriter, *http.Request, models.Environment)
+}
+
+// match reports whether a request path fits the template, setting its {wildcards} as path values.
+// ServeMux cannot hold every Apple path, as the consumption and appAccountToken templates overlap.
+func (e endpoint) match(r *http.Request) bool {
+	template, path := strings.Split(e.path, "/"), strings.Split(r.URL.Path, "/")
+	if r.Method != e.method || len(template) != len(path) {
+		return false
+	}
+	values := map[string]string{}
+	for i, segment := range template {
+		if name, ok := strings.CutPrefix(segment, "{"); ok && path[i] != "" {
+			values[strings.TrimSuffix(name, "}")] = path[i]
+		} else if segment != path[i] {
+			return false
+		}
+	}
+	for name, value := range values {
+		r.SetPathValue(name, value)
+	}
+	return true
+}
+
+// routes serves every models.Path* endpoint behind authentication and the scripted faults.
+// Templates are tried in order, so literal paths come before the templates they overlap.
+func (s *Server) routes() http.Handler {
+	endpoints := []endpoint{
+		{http.MethodGet, models.PathTransactionInfo, s.getTransactionInfo},
+		{http.MethodGet, models.PathLookUp, s.lookUpOrder},
+		{http.MethodGet, models.PathTransactionHistory, s.getTransactionHistory},
+		{http.MethodGet, models.PathRefundHistory, s.getRefundHistory},
+		{http.MethodGet, models.PathGetALLSubscriptionStatus, s.getAllSubscriptionStatuses},
+		{http.MethodPut, models.PathConsumptionInfo, s.sendConsumptionInfo},
+		{http.MethodPut, models.PathExtendSubscriptionRenewalDate, s.extendRenewalDate},
+		{http.MethodPost, models.PathExtendSubscriptionRenewalDateForAll, s.massExtendRenewalDate},
+		{http.MethodGet, models.PathGetStatusOfSubscriptionRenewalDate, s.getMassExtensionStatus},
+		{http.MethodPost, models.PathGetNotificationHistory, s.getNotificationHistory},
+		{http.MethodPost, models.PathRequestTestNotification, s.requestTestNotification},
+		{http.MethodGet, models.PathGetTestNotificationStatus, s.getTestNotificationStatus},
+		{http.MethodPut, models.PathSetAppAccountToken, s.setAppAccountToken},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := slices.IndexFunc(endpoints, func(e endpoint) bool { return e.match(r) })
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		e := endpoints[i]
+
+		environment := models.Production
+		if r.Host == strings.TrimPrefix(models.HostSandBox, "https://") {
+			environment = models.Sandbox
+		}
+		s.record(Request{Method: e.method, Path: e.path, URL: r.URL, Environment: environment})
+
+		if !s.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if fault := s.fault(e.method, e.path); fault != nil {
+			writeFault(w, fault)
+			return
+		}
+		e.handler(w, r, environment)
+	})
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+}
+
+// authorized checks the bearer token was signed by the generated API key for the test bundle
+func (s *Server) authorized(r *http.Request) bool {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(bearer, claims, func(token *jwt.Token) (interface{}, error) {
+		return &s.authKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithAudience(models.DefaultAudience), jwt.WithIssuer(IssuerID))
+	if err != nil || token.Header["kid"] != KeyID {
+		return false
+	}
+	return claims["bid"] == BundleID
+}
+
+// fault returns the first scripted fault matching a request, using up one of its times
+func (s *Server) fault(method, path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if (f.Method != "" && f.Method != method) || (f.Path != "" && f.Path != path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func writeFault(w http.ResponseWriter, fault *Fault) {
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+	}
+	if fault.Err == nil {
+		w.WriteHeader(fault.Status)
+		return
+	}
+	status := fault.Status
+	if status == 0 {
+		status = errorStatus(fault.Err)
+	}
+	writeJSON(w, status, map[string]interface{}{"errorCode": fault.Err.ErrorCode(), "errorMessage": fault.Err.ErrorMessage()})
+}
+
+// errorStatus is the HTTP status Apple sends an error code with: its first three digits
+func errorStatus(err *models.Error) int {
+	return err.ErrorCode() / 10000
+}
+
+func writeError(w http.ResponseWriter, err *models.Error) {
+	writeFault(w, &Fault{Err: err})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -3,11 +3,13 @@ package models
 import (
 	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem
@@ -50,8 +52,14 @@ func (c *Cert) parseCert(certStr string) (*x509.Certificate, error) {
 }
 
 func (c *Cert) extractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error) {
+	return c.extractPublicKeyFromTokenAt(token, time.Time{})
+}
+
+// extractPublicKeyFromTokenAt verifies the x5c chain of token as of at and returns the leaf public key.
+// A zero at verifies against the current time.
+func (c *Cert) extractPublicKeyFromTokenAt(token string, at time.Time) (*ecdsa.PublicKey, error) {
 	headerStr, _, _ := strings.Cut(token, ".")
-	headerByte, err := base64.RawStdEncoding.DecodeString(headerStr)
+	headerByte, err := base64.RawURLEncoding.DecodeString(headerStr)
 	if err != nil {
 		return nil, err
 	}
@@ -68,12 +76,19 @@ func (c *Cert) extractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error)
 		return nil, errors.New("appstore found no certificates in x5c header field")
 	}
 
-	opts := x509.VerifyOptions{Roots: c.rootCertPool}
+	opts := x509.VerifyOptions{
+		Roots:       c.rootCertPool,
+		CurrentTime: at,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
 
 	leafCert, err := c.parseCert(header.X5c[0])
 	if err != nil {
 		return nil, fmt.Errorf("appstore failed to parse leaf certificate: %w", err)
 	}
+	if !hasExtension(leafCert, oidAppleReceiptSigning) {
+		return nil, errors.New("appstore leaf certificate is not an App Store receipt signing certificate")
+	}
 	header.X5c = header.X5c[1:]
 
 	pk, ok := leafCert.PublicKey.(*ecdsa.PublicKey)
@@ -90,6 +105,9 @@ func (c *Cert) extractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error)
 			if err != nil {
 				return nil, fmt.Errorf("appstore failed to parse intermediate certificate %d: %w", i, err)
 			}
+			if i == 0 && !hasExtension(cert, oidAppleWWDRIntermediate) {
+				return nil, errors.New("appstore intermediate certificate is not an Apple WWDR certificate")
+			}
 			opts.Intermediates.AddCert(cert)
 		}
 	}
@@ -100,4 +118,20 @@ func (c *Cert) extractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error)
 	}
 
 	return pk, nil
-}
\ No newline at end of file
+}
+
+var (
+	// oidAppleReceiptSigning marks the leaf certificate Apple signs App Store data with
+	oidAppleReceiptSigning = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	// oidAppleWWDRIntermediate marks the Apple Worldwide Developer Relations intermediate certificate
+	oidAppleWWDRIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConsumptionResponseStatus is the outcome of answering a CONSUMPTION_REQUEST
+type ConsumptionResponseStatus string
+
+const (
+	ConsumptionSent             ConsumptionResponseStatus = "sent"
+	ConsumptionSkippedNoUser    ConsumptionResponseStatus = "skipped_no_user"
+	ConsumptionSkippedNoConsent ConsumptionResponseStatus = "skipped_no_consent"
+	ConsumptionFailed           ConsumptionResponseStatus = "failed"
+	ConsumptionExpired          ConsumptionResponseStatus = "expired"
+)
+
+// ConsumptionAudit records what we sent to Apple for a CONSUMPTION_REQUEST, keyed by notificationUUID
+type ConsumptionAudit struct {
+	ID                    primitive.ObjectID        `bson:"_id,omitempty" json:"id,omitempty"`
+	NotificationUUID      string                    `bson:"notificationUUID" json:"notificationUUID"`
+	OriginalTransactionId string                    `bson:"originalTransactionId" json:"originalTransactionId"`
+	TransactionId         string                    `bson:"transactionId" json:"transactionId"`
+	UserID                string                    `bson:"userId,omitempty" json:"userId,omitempty"`
+	Environment           Environment               `bson:"environment,omitempty" json:"environment,omitempty"`
+	Status                ConsumptionResponseStatus `bson:"status" json:"status"`
+	Request               *ConsumptionRequestBody   `bson:"request,omitempty" json:"request,omitempty"`
+	Attempts              int                       `bson:"attempts" json:"attempts"`
+	ResponseStatus        int                       `bson:"responseStatus,omitempty" json:"responseStatus,omitempty"`
+	LastError             string                    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	Deadline              time.Time                 `bson:"deadline" json:"deadline"`
+	SentAt                *time.Time                `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	CreatedAt             time.Time                 `bson:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time                 `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (a *ConsumptionAudit) CollectionName() string {
+	return "consumptionResponses"
+}
@@ -14,18 +14,24 @@ type Example struct {
 }
 
 type User struct {
-	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Username              string             `bson:"username" json:"username" binding:"required"`
-	CreatedAt             *time.Time         `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt             *time.Time         `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-	User_id               string             `bson:"user_id" json:"user_id"`
-	Email                 string             `bson:"email" json:"email"`
-	Password              string             `bson:"password" json:"password"`
-	Role                  string             `bson:"role" json:"role"`
-	AppleAppId            int64              `bson:"appleAppId" json:"apple_app_id"`
-	IsAppleConnected      bool               `bson:"isAppleConnected" json:"is_apple_connected"`
-	TransactionAppleId    string             `bson:"transactionAppleId" json:"transaction_apple_id"`
-	OriginalTransactionId string             `bson:"originalTransactionId" json:"original_transaction_id"`
+	ID                     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username               string             `bson:"username" json:"username" binding:"required"`
+	CreatedAt              *time.Time         `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt              *time.Time         `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	User_id                string             `bson:"user_id" json:"user_id"`
+	Email                  string             `bson:"email" json:"email"`
+	Password               string             `bson:"password" json:"-"`
+	Role                   string             `bson:"role" json:"role"`
+	AppleAppId             int64              `bson:"appleAppId" json:"apple_app_id"`
+	IsAppleConnected       bool               `bson:"isAppleConnected" json:"is_apple_connected"`
+	TransactionAppleId     string             `bson:"transactionAppleId" json:"transaction_apple_id"`
+	OriginalTransactionId  string             `bson:"originalTransactionId" json:"original_transaction_id"`
+	ConsumptionDataConsent bool               `bson:"consumptionDataConsent" json:"consumption_data_consent"`
+	PlayTimeMinutes        int64              `bson:"playTimeMinutes" json:"play_time_minutes"`
+	AppAccountToken        string             `bson:"appAccountToken,omitempty" json:"app_account_token,omitempty"`
+	AppAccountTokenSynced  *time.Time         `bson:"appAccountTokenSynced,omitempty" json:"app_account_token_synced,omitempty"`
+	AppleSub               string             `bson:"appleSub,omitempty" json:"apple_sub,omitempty"`
+	AppleRefreshToken      string             `bson:"appleRefreshToken,omitempty" json:"-"`
 }
 
 // CollectionName returns the MongoDB collection name for this model
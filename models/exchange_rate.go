@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExchangeRate is how many units of Currency one unit of Base was worth on a UTC day (YYYY-MM-DD)
+type ExchangeRate struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Date      string             `bson:"date" json:"date"`
+	Base      string             `bson:"base" json:"base"`
+	Currency  string             `bson:"currency" json:"currency"`
+	Rate      float64            `bson:"rate" json:"rate"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (r *ExchangeRate) CollectionName() string {
+	return "exchangeRates"
+}
@@ -0,0 +1,174 @@
+// Package fixtures mints certificate chains shaped like Apple's and signs App Store JWS payloads with them,
+// so verification and notification handling can be exercised without payloads signed by Apple.
+package fixtures
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// oidAppleReceiptSigning marks the leaf certificate Apple signs App Store data with
+	oidAppleReceiptSigning = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	// oidAppleWWDRIntermediate marks the Apple Worldwide Developer Relations intermediate certificate
+	oidAppleWWDRIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// Chain is a root, WWDR intermediate and receipt-signing leaf certificate shaped like Apple's,
+// used to sign JWS payloads a SignedDataVerifier trusting Root accepts
+type Chain struct {
+	Root    *x509.Certificate
+	x5c     []string
+	leafKey *ecdsa.PrivateKey
+}
+
+// NewChain generates a certificate chain valid from notBefore to notAfter
+func NewChain(notBefore, notAfter time.Time) (*Chain, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := func(serial int64, name string, ext ...pkix.Extension) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			ExtraExtensions:       ext,
+		}
+	}
+	root, err := createCertificate(ca(1, "Test Root"), ca(1, "Test Root"), &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := createCertificate(ca(2, "Test WWDR", pkix.Extension{Id: oidAppleWWDRIntermediate, Value: []byte{5, 0}}), root, &intermediateKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := createCertificate(&x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:       notBefore,
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleReceiptSigning, Value: []byte{5, 0}}},
+	}, intermediate, &leafKey.PublicKey, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chain{
+		Root: root,
+		x5c: []string{
+			base64.StdEncoding.EncodeToString(leaf.Raw),
+			base64.StdEncoding.EncodeToString(intermediate.Raw),
+			base64.StdEncoding.EncodeToString(root.Raw),
+		},
+		leafKey: leafKey,
+	}, nil
+}
+
+func createCertificate(template, parent *x509.Certificate, pub *ecdsa.PublicKey, signer *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// CertPool returns a pool trusting only the root of the chain, for StoreConfig.TrustedCertPool
+func (c *Chain) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.Root)
+	return pool
+}
+
+// RootPEM returns the root certificate PEM-encoded, for the services that should trust the chain
+func (c *Chain) RootPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Root.Raw})
+}
+
+// MarshalPEM encodes the chain with its signing key: the leaf, intermediate and root certificates
+// followed by the leaf's PKCS #8 private key
+func (c *Chain) MarshalPEM() ([]byte, error) {
+	var out []byte
+	for _, cert := range c.x5c {
+		der, err := base64.StdEncoding.DecodeString(cert)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(c.leafKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})...), nil
+}
+
+// ParseChainPEM decodes a chain written by MarshalPEM
+func ParseChainPEM(data []byte) (*Chain, error) {
+	chain := &Chain{}
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("fixtures: invalid certificate: %w", err)
+			}
+			certs = append(certs, cert)
+			chain.x5c = append(chain.x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("fixtures: invalid private key: %w", err)
+			}
+			ecKey, ok := key.(*ecdsa.PrivateKey)
+			if !ok {
+				return nil, errors.New("fixtures: private key must be of type ecdsa.PrivateKey")
+			}
+			chain.leafKey = ecKey
+		}
+	}
+
+	if len(certs) != 3 || chain.leafKey == nil {
+		return nil, errors.New("fixtures: a chain needs a leaf, intermediate and root certificate and the leaf key")
+	}
+	if !chain.leafKey.PublicKey.Equal(certs[0].PublicKey) {
+		return nil, errors.New("fixtures: private key does not belong to the leaf certificate")
+	}
+	chain.Root = certs[2]
+	return chain, nil
+}
+
+// Sign signs claims as an App Store JWS with the chain in its x5c header
+func (c *Chain) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = c.x5c
+	return token.SignedString(c.leafKey)
+}
@@ -0,0 +1,131 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"simvizlab-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// refPrefix marks a notification's signedTransactionInfo or signedRenewalInfo as the name of a template to sign in its place
+const refPrefix = "@"
+
+// Templates are the payloads to sign, by name. A notification can embed a transaction or renewal info template
+// by setting data.signedTransactionInfo or data.signedRenewalInfo to "@" followed by the template's name.
+type Templates struct {
+	Transactions  map[string]models.JWSTransaction               `json:"transactions"`
+	RenewalInfos  map[string]models.JWSRenewalInfoDecodedPayload `json:"renewalInfos"`
+	Notifications map[string]models.NotificationPayload          `json:"notifications"`
+}
+
+// Signed holds the JWS of each template, by name
+type Signed struct {
+	Transactions  map[string]string `json:"transactions"`
+	RenewalInfos  map[string]string `json:"renewalInfos"`
+	Notifications map[string]string `json:"notifications"`
+}
+
+// ParseTemplates decodes JSON templates, rejecting fields the payloads do not have
+func ParseTemplates(data []byte) (*Templates, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	templates := &Templates{}
+	if err := decoder.Decode(templates); err != nil {
+		return nil, fmt.Errorf("fixtures: invalid templates: %w", err)
+	}
+	return templates, nil
+}
+
+// SignTransaction signs a transaction, stamping its signedDate with the current time when unset
+func (c *Chain) SignTransaction(transaction *models.JWSTransaction) (string, error) {
+	tx := *transaction
+	if tx.SignedDate == 0 {
+		tx.SignedDate = time.Now().UnixMilli()
+	}
+	return c.Sign(tx)
+}
+
+// SignRenewalInfo signs renewal info, stamping its signedDate with the current time when unset
+func (c *Chain) SignRenewalInfo(renewalInfo *models.JWSRenewalInfoDecodedPayload) (string, error) {
+	info := *renewalInfo
+	if info.SignedDate == 0 {
+		info.SignedDate = time.Now().UnixMilli()
+	}
+	return c.Sign(info)
+}
+
+// SignNotification signs a notification payload. An unset signedDate is stamped with the current time,
+// and an unset notificationUUID and notificationVersion are filled in as Apple would.
+func (c *Chain) SignNotification(payload *models.NotificationPayload) (string, error) {
+	p := *payload
+	if p.SignedDate == 0 {
+		p.SignedDate = time.Now().UnixMilli()
+	}
+	if p.NotificationUUID == "" {
+		p.NotificationUUID = uuid.NewString()
+	}
+	if p.NotificationVersion == "" {
+		p.NotificationVersion = "2.0"
+	}
+	return c.Sign(p)
+}
+
+// SignTemplates signs every template, resolving the templates notifications refer to
+func (c *Chain) SignTemplates(templates *Templates) (*Signed, error) {
+	signed := &Signed{
+		Transactions:  make(map[string]string, len(templates.Transactions)),
+		RenewalInfos:  make(map[string]string, len(templates.RenewalInfos)),
+		Notifications: make(map[string]string, len(templates.Notifications)),
+	}
+
+	for name, tx := range templates.Transactions {
+		jws, err := c.SignTransaction(&tx)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: failed to sign transaction %q: %w", name, err)
+		}
+		signed.Transactions[name] = jws
+	}
+	for name, info := range templates.RenewalInfos {
+		jws, err := c.SignRenewalInfo(&info)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: failed to sign renewal info %q: %w", name, err)
+		}
+		signed.RenewalInfos[name] = jws
+	}
+
+	for name, payload := range templates.Notifications {
+		var err error
+		if payload.Data.SignedTransactionInfo, err = resolve(payload.Data.SignedTransactionInfo, signed.Transactions); err != nil {
+			return nil, fmt.Errorf("fixtures: notification %q: %w", name, err)
+		}
+		if payload.Data.SignedRenewalInfo, err = resolve(payload.Data.SignedRenewalInfo, signed.RenewalInfos); err != nil {
+			return nil, fmt.Errorf("fixtures: notification %q: %w", name, err)
+		}
+
+		jws, err := c.SignNotification(&payload)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: failed to sign notification %q: %w", name, err)
+		}
+		signed.Notifications[name] = jws
+	}
+	return signed, nil
+}
+
+// resolve replaces a reference to a signed template with its JWS, leaving any other value as is
+func resolve(value string, signed map[string]string) (string, error) {
+	name, ok := strings.CutPrefix(value, refPrefix)
+	if !ok {
+		return value, nil
+	}
+	jws, ok := signed[name]
+	if !ok {
+		return "", fmt.Errorf("no template named %q", name)
+	}
+	return jws, nil
+}
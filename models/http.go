@@ -157,7 +157,7 @@ func SetResponseErrorHandler(c HTTPClient, u Unmarshaller, ptr any) DoFunc {
 		}
 
 		if rErr.ErrorCode == 4290000 {
-			retryAfter, err := strconv.ParseInt(req.Header.Get("Retry-After"), 10, 64)
+			retryAfter, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64)
 			if err == nil {
 				return resp, &Error{errorCode: rErr.ErrorCode, errorMessage: rErr.ErrorMessage, retryAfter: retryAfter}
 			}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobState persists the progress of a background job across restarts, keyed by name.
+// The lease keeps a single replica running the job at a time.
+type JobState struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name           string             `bson:"name" json:"name"`
+	HighWaterMark  int64              `bson:"highWaterMark" json:"highWaterMark"`
+	ClaimedBy      string             `bson:"claimedBy,omitempty" json:"claimedBy,omitempty"`
+	LeaseExpiresAt *time.Time         `bson:"leaseExpiresAt,omitempty" json:"leaseExpiresAt,omitempty"`
+	LastRunAt      *time.Time         `bson:"lastRunAt,omitempty" json:"lastRunAt,omitempty"`
+	LastError      string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (s *JobState) CollectionName() string {
+	return "jobState"
+}
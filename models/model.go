@@ -253,6 +253,14 @@ type ExtendRenewalDateRequest struct {
 	RequestIdentifier string           `json:"requestIdentifier"`
 }
 
+// ExtendRenewalDateResponse https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldateresponse
+type ExtendRenewalDateResponse struct {
+	EffectiveDate         int64  `json:"effectiveDate"`
+	OriginalTransactionId string `json:"originalTransactionId"`
+	Success               bool   `json:"success"`
+	WebOrderLineItemId    string `json:"webOrderLineItemId"`
+}
+
 // MassExtendRenewalDateStatusResponse https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldatestatusresponse
 type MassExtendRenewalDateStatusResponse struct {
 	RequestIdentifier string `json:"requestIdentifier"`
@@ -361,6 +369,13 @@ type SendTestNotificationResponse struct {
 	TestNotificationToken string `json:"testNotificationToken"`
 }
 
+// CheckTestNotificationResponse https://developer.apple.com/documentation/appstoreserverapi/checktestnotificationresponse
+type CheckTestNotificationResponse struct {
+	SignedPayload          string                 `json:"signedPayload"`
+	FirstSendAttemptResult FirstSendAttemptResult `json:"firstSendAttemptResult,omitempty"`
+	SendAttempts           []SendAttemptItem      `json:"sendAttempts,omitempty"`
+}
+
 // Notification body https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2
 type NotificationV2 struct {
 	SignedPayload string `json:"signedPayload"`
@@ -373,6 +388,7 @@ type NotificationPayload struct {
 	Subtype             string           `json:"subtype"`
 	NotificationUUID    string           `json:"notificationUUID"`
 	NotificationVersion string           `json:"notificationVersion"`
+	SignedDate          int64            `json:"signedDate"`
 	Data                NotificationData `json:"data"`
 }
 
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when combining amounts of different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an amount in milliunits of an ISO 4217 currency, the unit Apple reports prices in.
+// 9990 USD is $9.99.
+type Money struct {
+	Milliunits int64  `bson:"milliunits" json:"milliunits"`
+	Currency   string `bson:"currency" json:"currency"`
+}
+
+// NewMoney returns milliunits of currency
+func NewMoney(milliunits int64, currency string) Money {
+	return Money{Milliunits: milliunits, Currency: currency}
+}
+
+// TransactionPrice returns the price a transaction was charged
+func TransactionPrice(transaction *JWSTransaction) Money {
+	return NewMoney(transaction.Price, transaction.Currency)
+}
+
+// Add sums two amounts of the same currency. A zero Money takes the currency of the other amount.
+func (m Money) Add(other Money) (Money, error) {
+	switch {
+	case m.Currency == "":
+		return NewMoney(m.Milliunits+other.Milliunits, other.Currency), nil
+	case other.Currency == "" || other.Currency == m.Currency:
+		return NewMoney(m.Milliunits+other.Milliunits, m.Currency), nil
+	}
+	return Money{}, fmt.Errorf("%w: cannot add %s to %s", ErrCurrencyMismatch, other.Currency, m.Currency)
+}
+
+// Scale multiplies the amount by factor, rounding half away from zero to the nearest milliunit
+func (m Money) Scale(factor float64) Money {
+	return NewMoney(int64(math.Round(float64(m.Milliunits)*factor)), m.Currency)
+}
+
+// Convert expresses the amount in currency, given how many units of currency one unit of m is worth
+func (m Money) Convert(currency string, rate float64) Money {
+	return NewMoney(int64(math.Round(float64(m.Milliunits)*rate)), currency)
+}
+
+// Amount formats the amount in whole units with at least two decimals, such as "9.99" or "0.125"
+func (m Money) Amount() string {
+	sign := ""
+	milliunits := m.Milliunits
+	if milliunits < 0 {
+		sign = "-"
+		milliunits = -milliunits
+	}
+	fraction := strings.TrimSuffix(fmt.Sprintf("%03d", milliunits%1000), "0")
+	return fmt.Sprintf("%s%d.%s", sign, milliunits/1000, fraction)
+}
+
+func (m Money) String() string {
+	return m.Amount() + " " + m.Currency
+}
+
+// MarshalJSON adds the formatted amount next to the milliunits
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Milliunits int64  `json:"milliunits"`
+		Amount     string `json:"amount"`
+		Currency   string `json:"currency"`
+	}{m.Milliunits, m.Amount(), m.Currency})
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMoneyAmount(t *testing.T) {
+	tests := []struct {
+		milliunits int64
+		want       string
+	}{
+		{milliunits: 9990, want: "9.99"},
+		{milliunits: 10000, want: "10.00"},
+		{milliunits: 125, want: "0.125"},
+		{milliunits: 0, want: "0.00"},
+		{milliunits: -4990, want: "-4.99"},
+	}
+	for _, tt := range tests {
+		if got := NewMoney(tt.milliunits, "USD").Amount(); got != tt.want {
+			t.Errorf("Amount() of %d = %q, want %q", tt.milliunits, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	sum, err := Money{}.Add(NewMoney(9990, "EUR"))
+	if err != nil || sum != NewMoney(9990, "EUR") {
+		t.Errorf("Add() to zero Money = %v, %v", sum, err)
+	}
+	sum, err = sum.Add(NewMoney(10, "EUR"))
+	if err != nil || sum != NewMoney(10000, "EUR") {
+		t.Errorf("Add() = %v, %v, want 10.00 EUR", sum, err)
+	}
+	if _, err := sum.Add(NewMoney(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add() across currencies error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestMoneyConvertAndScale(t *testing.T) {
+	if got := NewMoney(9990, "EUR").Convert("USD", 1.0845); got != NewMoney(10834, "USD") {
+		t.Errorf("Convert() = %v, want 10.834 USD", got)
+	}
+	if got := NewMoney(9990, "USD").Scale(0.3); got != NewMoney(2997, "USD") {
+		t.Errorf("Scale() = %v, want 2.997 USD", got)
+	}
+	if got := NewMoney(-5, "USD").Scale(0.5); got != NewMoney(-3, "USD") {
+		t.Errorf("Scale() of negative = %v, want rounding away from zero", got)
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	raw, err := json.Marshal(NewMoney(9990, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"milliunits":9990,"amount":"9.99","currency":"USD"}`; string(raw) != want {
+		t.Errorf("MarshalJSON() = %s, want %s", raw, want)
+	}
+}
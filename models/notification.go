@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AppStoreNotification is a received App Store Server Notification V2, stored both raw and decoded
+type AppStoreNotification struct {
+	ID                    primitive.ObjectID            `bson:"_id,omitempty" json:"id,omitempty"`
+	NotificationUUID      string                        `bson:"notificationUUID" json:"notificationUUID"`
+	NotificationType      NotificationTypeV2            `bson:"notificationType" json:"notificationType"`
+	Subtype               SubtypeV2                     `bson:"subtype,omitempty" json:"subtype,omitempty"`
+	NotificationVersion   string                        `bson:"notificationVersion,omitempty" json:"notificationVersion,omitempty"`
+	Environment           Environment                   `bson:"environment,omitempty" json:"environment,omitempty"`
+	BundleId              string                        `bson:"bundleId,omitempty" json:"bundleId,omitempty"`
+	AppAppleId            int64                         `bson:"appAppleId,omitempty" json:"appAppleId,omitempty"`
+	OriginalTransactionId string                        `bson:"originalTransactionId,omitempty" json:"originalTransactionId,omitempty"`
+	TransactionId         string                        `bson:"transactionId,omitempty" json:"transactionId,omitempty"`
+	SignedDate            int64                         `bson:"signedDate" json:"signedDate"`
+	SignedPayload         string                        `bson:"signedPayload" json:"signedPayload"`
+	Transaction           *JWSTransaction               `bson:"transaction,omitempty" json:"transaction,omitempty"`
+	RenewalInfo           *JWSRenewalInfoDecodedPayload `bson:"renewalInfo,omitempty" json:"renewalInfo,omitempty"`
+	ReceivedAt            time.Time                     `bson:"receivedAt" json:"receivedAt"`
+}
+
+func (n *AppStoreNotification) CollectionName() string {
+	return "appStoreNotifications"
+}
+
+// NotificationProcessingState tracks how far a notification has been applied
+type NotificationProcessingState string
+
+const (
+	NotificationReceived   NotificationProcessingState = "received"
+	NotificationProcessing NotificationProcessingState = "processing"
+	NotificationApplied    NotificationProcessingState = "applied"
+	NotificationFailed     NotificationProcessingState = "failed"
+)
+
+// NotificationProcessingRecord is the dedup record guaranteeing a notification is applied exactly once
+type NotificationProcessingRecord struct {
+	ID               primitive.ObjectID          `bson:"_id,omitempty" json:"id,omitempty"`
+	NotificationUUID string                      `bson:"notificationUUID" json:"notificationUUID"`
+	NotificationType NotificationTypeV2          `bson:"notificationType,omitempty" json:"notificationType,omitempty"`
+	State            NotificationProcessingState `bson:"state" json:"state"`
+	Attempts         int                         `bson:"attempts" json:"attempts"`
+	ClaimedBy        string                      `bson:"claimedBy,omitempty" json:"claimedBy,omitempty"`
+	LeaseExpiresAt   *time.Time                  `bson:"leaseExpiresAt,omitempty" json:"leaseExpiresAt,omitempty"`
+	LastError        string                      `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	AppliedAt        *time.Time                  `bson:"appliedAt,omitempty" json:"appliedAt,omitempty"`
+	CreatedAt        time.Time                   `bson:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time                   `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (r *NotificationProcessingRecord) CollectionName() string {
+	return "notificationProcessing"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationHealthStatus is the outcome of a test-notification round trip
+type NotificationHealthStatus string
+
+const (
+	NotificationHealthPending NotificationHealthStatus = "pending"
+	NotificationHealthPassed  NotificationHealthStatus = "passed"
+	NotificationHealthFailed  NotificationHealthStatus = "failed"
+)
+
+// NotificationHealthCheck records one TEST notification requested from Apple and whether our webhook received it
+type NotificationHealthCheck struct {
+	ID                     primitive.ObjectID       `bson:"_id,omitempty" json:"id,omitempty"`
+	TestNotificationToken  string                   `bson:"testNotificationToken" json:"testNotificationToken"`
+	NotificationUUID       string                   `bson:"notificationUUID,omitempty" json:"notificationUUID,omitempty"`
+	Environment            Environment              `bson:"environment,omitempty" json:"environment,omitempty"`
+	Status                 NotificationHealthStatus `bson:"status" json:"status"`
+	FirstSendAttemptResult FirstSendAttemptResult   `bson:"firstSendAttemptResult,omitempty" json:"firstSendAttemptResult,omitempty"`
+	SendAttempts           []SendAttemptItem        `bson:"sendAttempts,omitempty" json:"sendAttempts,omitempty"`
+	RequestedAt            time.Time                `bson:"requestedAt" json:"requestedAt"`
+	ReceivedAt             *time.Time               `bson:"receivedAt,omitempty" json:"receivedAt,omitempty"`
+	LatencyMs              int64                    `bson:"latencyMs,omitempty" json:"latencyMs,omitempty"`
+	Error                  string                   `bson:"error,omitempty" json:"error,omitempty"`
+	CompletedAt            *time.Time               `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
+
+func (c *NotificationHealthCheck) CollectionName() string {
+	return "notificationHealthChecks"
+}
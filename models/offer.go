@@ -0,0 +1,99 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrOfferMissingField is returned when a promotional offer signature lacks a required value
+var ErrOfferMissingField = errors.New("offer: productId and offerId are required")
+
+// offerSeparator is the invisible separator Apple places between the fields of an offer payload
+const offerSeparator = "\u2063"
+
+// PromotionalOfferSignature is what StoreKit needs to redeem a promotional offer
+// https://developer.apple.com/documentation/storekit/in-app_purchase/original_api_for_in-app_purchase/subscriptions_and_offers/generating_a_signature_for_promotional_offers
+type PromotionalOfferSignature struct {
+	KeyIdentifier   string `json:"keyIdentifier"`
+	ProductId       string `json:"productId"`
+	OfferId         string `json:"offerId"`
+	AppAccountToken string `json:"appAccountToken"`
+	Nonce           string `json:"nonce"`
+	Timestamp       int64  `json:"timestamp"`
+	Signature       string `json:"signature"`
+}
+
+// OfferSigner signs promotional offers with a subscription offer key from App Store Connect
+type OfferSigner struct {
+	keyID    string
+	bundleID string
+	key      *ecdsa.PrivateKey
+}
+
+// NewOfferSigner loads the .p8 offer key the same way the API token does
+func NewOfferSigner(keyContent []byte, keyID, bundleID string) (*OfferSigner, error) {
+	key, err := (&Token{}).passKeyFromByte(keyContent)
+	if err != nil {
+		return nil, err
+	}
+	return &OfferSigner{keyID: keyID, bundleID: bundleID, key: key}, nil
+}
+
+// Sign creates a signature for productId and offerId bound to appAccountToken, with a fresh nonce and the current time
+func (s *OfferSigner) Sign(productId, offerId, appAccountToken string) (*PromotionalOfferSignature, error) {
+	return s.SignWith(productId, offerId, appAccountToken, uuid.New(), time.Now())
+}
+
+// SignWith creates a signature with the given nonce and timestamp
+func (s *OfferSigner) SignWith(productId, offerId, appAccountToken string, nonce uuid.UUID, timestamp time.Time) (*PromotionalOfferSignature, error) {
+	if productId == "" || offerId == "" {
+		return nil, ErrOfferMissingField
+	}
+
+	offer := &PromotionalOfferSignature{
+		KeyIdentifier:   s.keyID,
+		ProductId:       productId,
+		OfferId:         offerId,
+		AppAccountToken: strings.ToLower(appAccountToken),
+		Nonce:           strings.ToLower(nonce.String()),
+		Timestamp:       timestamp.UnixMilli(),
+	}
+
+	digest := sha256.Sum256([]byte(offer.payload(s.bundleID)))
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	offer.Signature = base64.StdEncoding.EncodeToString(signature)
+	return offer, nil
+}
+
+// VerifyOffer checks a signature against the public half of the offer key
+func (s *OfferSigner) VerifyOffer(offer *PromotionalOfferSignature) bool {
+	signature, err := base64.StdEncoding.DecodeString(offer.Signature)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(offer.payload(s.bundleID)))
+	return ecdsa.VerifyASN1(&s.key.PublicKey, digest[:], signature)
+}
+
+func (o *PromotionalOfferSignature) payload(bundleID string) string {
+	return strings.Join([]string{
+		bundleID,
+		o.KeyIdentifier,
+		o.ProductId,
+		o.OfferId,
+		o.AppAccountToken,
+		o.Nonce,
+		strconv.FormatInt(o.Timestamp, 10),
+	}, offerSeparator)
+}
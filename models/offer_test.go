@@ -0,0 +1,64 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestOfferSigner(t *testing.T) *OfferSigner {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	signer, err := NewOfferSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "OFFERKEY01", "fake.bundle.id")
+	if err != nil {
+		t.Fatalf("NewOfferSigner() error = %v", err)
+	}
+	return signer
+}
+
+func TestOfferSigner_SignWith(t *testing.T) {
+	signer := newTestOfferSigner(t)
+	nonce := uuid.MustParse("D35D2C1B-6F4A-4C1F-9E0B-2C5D9B5B6F10")
+	at := time.UnixMilli(1700000000000)
+
+	offer, err := signer.SignWith("pro.monthly", "WINBACK1", "7E3FB20B-4CDB-47CC-936D-99D65F608138", nonce, at)
+	if err != nil {
+		t.Fatalf("SignWith() error = %v", err)
+	}
+	if offer.Nonce != "d35d2c1b-6f4a-4c1f-9e0b-2c5d9b5b6f10" || offer.AppAccountToken != "7e3fb20b-4cdb-47cc-936d-99d65f608138" {
+		t.Errorf("SignWith() nonce = %q, appAccountToken = %q, want lower case", offer.Nonce, offer.AppAccountToken)
+	}
+	if offer.Timestamp != 1700000000000 || offer.KeyIdentifier != "OFFERKEY01" {
+		t.Errorf("SignWith() timestamp = %d, keyIdentifier = %q", offer.Timestamp, offer.KeyIdentifier)
+	}
+	if !signer.VerifyOffer(offer) {
+		t.Fatalf("VerifyOffer() = false for a fresh signature")
+	}
+
+	tampered := *offer
+	tampered.OfferId = "OTHER"
+	if signer.VerifyOffer(&tampered) {
+		t.Errorf("VerifyOffer() = true after changing the offer id")
+	}
+
+	if _, err := signer.SignWith("", "WINBACK1", "", nonce, at); !errors.Is(err, ErrOfferMissingField) {
+		t.Errorf("SignWith() without productId error = %v, want %v", err, ErrOfferMissingField)
+	}
+}
+
+func TestNewOfferSigner_InvalidKey(t *testing.T) {
+	if _, err := NewOfferSigner([]byte("not a pem"), "OFFERKEY01", "fake.bundle.id"); !errors.Is(err, ErrAuthKeyInvalidPem) {
+		t.Errorf("NewOfferSigner() error = %v, want %v", err, ErrAuthKeyInvalidPem)
+	}
+}
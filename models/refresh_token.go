@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a stored refresh token, kept only as the SHA-256 hash of the token handed out.
+// Every rotation revokes the token and issues its replacement in the same family, so a revoked
+// token presented again reveals a leak and revokes the whole family.
+type RefreshToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	TokenHash  string             `bson:"tokenHash" json:"-"`
+	FamilyID   string             `bson:"familyId" json:"familyId"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	ReplacedBy string             `bson:"replacedBy,omitempty" json:"-"`
+}
+
+func (t *RefreshToken) CollectionName() string {
+	return "refreshTokens"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefundSource tells where we learned about a refund
+type RefundSource string
+
+const (
+	RefundSourceHistory      RefundSource = "refund_history"
+	RefundSourceNotification RefundSource = "notification"
+)
+
+// RefundRecord is a refunded or revoked transaction, keyed by transactionId
+type RefundRecord struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TransactionId         string             `bson:"transactionId" json:"transactionId"`
+	OriginalTransactionId string             `bson:"originalTransactionId" json:"originalTransactionId"`
+	ProductId             string             `bson:"productId,omitempty" json:"productId,omitempty"`
+	Type                  IAPType            `bson:"type,omitempty" json:"type,omitempty"`
+	Environment           Environment        `bson:"environment,omitempty" json:"environment,omitempty"`
+	RevocationDate        int64              `bson:"revocationDate" json:"revocationDate"`
+	RevocationReason      *int32             `bson:"revocationReason,omitempty" json:"revocationReason,omitempty"`
+	Reversed              bool               `bson:"reversed" json:"reversed"`
+	ReversedAt            *time.Time         `bson:"reversedAt,omitempty" json:"reversedAt,omitempty"`
+	Source                RefundSource       `bson:"source" json:"source"`
+	Transaction           *JWSTransaction    `bson:"transaction,omitempty" json:"transaction,omitempty"`
+	UpdatedAt             time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (r *RefundRecord) CollectionName() string {
+	return "refunds"
+}
+
+// RevocationReasonText describes why Apple refunded a transaction
+// https://developer.apple.com/documentation/appstoreserverapi/revocationreason
+func RevocationReasonText(reason *int32) string {
+	if reason == nil {
+		return "Unknown"
+	}
+	switch *reason {
+	case 0:
+		return "Refunded for another reason"
+	case 1:
+		return "Refunded for an actual or perceived issue within the app"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RenewalExtensionKind tells whether an extension targets one subscription or all active subscribers of a product
+type RenewalExtensionKind string
+
+const (
+	RenewalExtensionSingle RenewalExtensionKind = "single"
+	RenewalExtensionMass   RenewalExtensionKind = "mass"
+)
+
+// RenewalExtensionStatus tracks a renewal-date extension request
+type RenewalExtensionStatus string
+
+const (
+	RenewalExtensionPending   RenewalExtensionStatus = "pending"
+	RenewalExtensionSucceeded RenewalExtensionStatus = "succeeded"
+	RenewalExtensionFailed    RenewalExtensionStatus = "failed"
+	RenewalExtensionComplete  RenewalExtensionStatus = "complete"
+)
+
+// RenewalExtension is a subscription renewal-date extension we asked Apple for, keyed by requestIdentifier
+type RenewalExtension struct {
+	ID                     primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
+	RequestIdentifier      string                 `bson:"requestIdentifier" json:"requestIdentifier"`
+	Kind                   RenewalExtensionKind   `bson:"kind" json:"kind"`
+	Environment            Environment            `bson:"environment,omitempty" json:"environment,omitempty"`
+	OriginalTransactionId  string                 `bson:"originalTransactionId,omitempty" json:"originalTransactionId,omitempty"`
+	ProductId              string                 `bson:"productId,omitempty" json:"productId,omitempty"`
+	StorefrontCountryCodes []string               `bson:"storefrontCountryCodes,omitempty" json:"storefrontCountryCodes,omitempty"`
+	ExtendByDays           int32                  `bson:"extendByDays" json:"extendByDays"`
+	ExtendReasonCode       ExtendReasonCode       `bson:"extendReasonCode" json:"extendReasonCode"`
+	Status                 RenewalExtensionStatus `bson:"status" json:"status"`
+	EffectiveDate          int64                  `bson:"effectiveDate,omitempty" json:"effectiveDate,omitempty"`
+	WebOrderLineItemId     string                 `bson:"webOrderLineItemId,omitempty" json:"webOrderLineItemId,omitempty"`
+	CompleteDate           int64                  `bson:"completeDate,omitempty" json:"completeDate,omitempty"`
+	SucceededCount         int64                  `bson:"succeededCount" json:"succeededCount"`
+	FailedCount            int64                  `bson:"failedCount" json:"failedCount"`
+	LastError              string                 `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	LastPolledAt           *time.Time             `bson:"lastPolledAt,omitempty" json:"lastPolledAt,omitempty"`
+	CreatedAt              time.Time              `bson:"createdAt" json:"createdAt"`
+	UpdatedAt              time.Time              `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (e *RenewalExtension) CollectionName() string {
+	return "renewalExtensions"
+}
@@ -0,0 +1,51 @@
+package models
+
+// ActiveSubscriberCount is the number of subscriptions of a product with access at a point in time
+type ActiveSubscriberCount struct {
+	ProductId   string `bson:"_id" json:"productId"`
+	Subscribers int64  `bson:"subscribers" json:"subscribers"`
+}
+
+// DailyActivity counts the subscriptions that started, renewed and lapsed on a UTC day (YYYY-MM-DD)
+type DailyActivity struct {
+	Day      string `bson:"_id" json:"day"`
+	New      int64  `bson:"new" json:"new"`
+	Renewing int64  `bson:"renewing" json:"renewing"`
+	Churned  int64  `bson:"churned" json:"churned"`
+}
+
+// TrialConversion counts the free trials of a product that started in a period and how many went on to pay
+type TrialConversion struct {
+	ProductId string  `bson:"_id" json:"productId"`
+	Trials    int64   `bson:"trials" json:"trials"`
+	Converted int64   `bson:"converted" json:"converted"`
+	Rate      float64 `bson:"-" json:"rate"`
+}
+
+// RevenueBucket sums the transaction prices of one currency, storefront, UTC day and commission tier, in milliunits
+type RevenueBucket struct {
+	Day               string `bson:"day"`
+	Currency          string `bson:"currency"`
+	Storefront        string `bson:"storefront"`
+	ReducedCommission bool   `bson:"reducedCommission"`
+	Transactions      int64  `bson:"transactions"`
+	Revenue           int64  `bson:"revenue"`
+	Refunded          int64  `bson:"refunded"`
+}
+
+// RevenueTotal is the revenue of one currency and storefront, as charged and converted to the reporting currency.
+// Tax is the part of the converted revenue owed as the storefront's tax, and proceeds are what remains after
+// the tax and Apple's commission on the price before tax. Refunded amounts are gross, tax and commission
+// included, and are not deducted from revenue or proceeds.
+type RevenueTotal struct {
+	Currency          string `json:"currency"`
+	Storefront        string `json:"storefront"`
+	Transactions      int64  `json:"transactions"`
+	Revenue           Money  `json:"revenue"`
+	Refunded          Money  `json:"refunded"`
+	ReportingRevenue  Money  `json:"reportingRevenue"`
+	ReportingRefunded Money  `json:"reportingRefunded"`
+	Tax               Money  `json:"tax"`
+	Commission        Money  `json:"commission"`
+	Proceeds          Money  `json:"proceeds"`
+}
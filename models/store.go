@@ -177,43 +177,52 @@ func (c *StoreClient) LookupOrderID(ctx context.Context, orderId string) (*Order
 
 // GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
 func (c *StoreClient) GetTransactionHistory(ctx context.Context, originalTransactionId string, query *url.Values) (responses []*HistoryResponse, err error) {
-	URL := c.hostUrl + PathTransactionHistory
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
 	if query == nil {
 		query = &url.Values{}
 	}
 
 	for {
-		var client HTTPClient
-		client = c.httpCli
-		client = SetInitializer(client, c.initHttpClient)
-		apiErr := &Error{}
-		client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-		client = RequireResponseStatus(client, http.StatusOK)
-
-		rsp := HistoryResponse{}
-		client = SetResponseBodyHandler(client, json.Unmarshal, &rsp)
-		client = SetRequest(ctx, client, http.MethodGet, URL+"?"+query.Encode())
-		_, errDo := client.Do(nil)
-		if apiErr.errorCode != 0 {
-			return nil, apiErr
-		}
-		if errDo != nil {
-			return nil, errDo
+		rsp, err := c.GetTransactionHistoryPage(ctx, originalTransactionId, *query)
+		if err != nil {
+			return nil, err
 		}
 
-		responses = append(responses, &rsp)
+		responses = append(responses, rsp)
 		if rsp.HasMore && rsp.Revision != "" {
 			query.Set("revision", rsp.Revision)
 		} else {
-			return
+			return responses, nil
 		}
 
 		time.Sleep(10 * time.Millisecond)
 	}
 }
 
+// GetTransactionHistoryPage fetches the single page of history selected by the revision in query, the first page without one
+func (c *StoreClient) GetTransactionHistoryPage(ctx context.Context, originalTransactionId string, query url.Values) (*HistoryResponse, error) {
+	URL := c.hostUrl + PathTransactionHistory
+	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
+
+	var client HTTPClient
+	client = c.httpCli
+	client = SetInitializer(client, c.initHttpClient)
+	apiErr := &Error{}
+	client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
+	client = RequireResponseStatus(client, http.StatusOK)
+
+	rsp := HistoryResponse{}
+	client = SetResponseBodyHandler(client, json.Unmarshal, &rsp)
+	client = SetRequest(ctx, client, http.MethodGet, URL+"?"+query.Encode())
+	_, errDo := client.Do(nil)
+	if apiErr.errorCode != 0 {
+		return nil, apiErr
+	}
+	if errDo != nil {
+		return nil, errDo
+	}
+	return &rsp, nil
+}
+
 // GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
 func (c *StoreClient) GetRefundHistory(ctx context.Context, originalTransactionId string) (responses []*RefundLookupResponse, err error) {
 	baseURL := c.hostUrl + PathRefundHistory
@@ -275,20 +284,37 @@ func (c *StoreClient) SendConsumptionInfo(ctx context.Context, originalTransacti
 
 // ExtendSubscriptionRenewalDate https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
 func (c *StoreClient) ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, err error) {
+	statusCode, _, err = c.ExtendSubscriptionRenewalDateWithResponse(ctx, originalTransactionId, body)
+	return statusCode, err
+}
+
+// ExtendSubscriptionRenewalDateWithResponse extends a subscription renewal date and returns Apple's ExtendRenewalDateResponse
+// https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (c *StoreClient) ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, rsp *ExtendRenewalDateResponse, err error) {
 	URL := c.hostUrl + PathExtendSubscriptionRenewalDate
 	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
 
 	bodyBuf := new(bytes.Buffer)
 	err = json.NewEncoder(bodyBuf).Encode(body)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	statusCode, _, err = c.Do(ctx, http.MethodPut, URL, bodyBuf)
+	statusCode, respBody, err := c.Do(ctx, http.MethodPut, URL, bodyBuf)
 	if err != nil {
-		return statusCode, err
+		return statusCode, nil, err
 	}
-	return statusCode, nil
+
+	if statusCode != http.StatusOK {
+		return statusCode, nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
+	}
+
+	err = json.Unmarshal(respBody, &rsp)
+	if err != nil {
+		return statusCode, nil, err
+	}
+
+	return statusCode, rsp, nil
 }
 
 // ExtendSubscriptionRenewalDateForAll https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
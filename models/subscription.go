@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SubscriptionState is the entitlement state of an auto-renewable subscription
+type SubscriptionState string
+
+const (
+	SubscriptionStateNone         SubscriptionState = ""
+	SubscriptionStateActive       SubscriptionState = "active"
+	SubscriptionStateGracePeriod  SubscriptionState = "grace_period"
+	SubscriptionStateBillingRetry SubscriptionState = "billing_retry"
+	SubscriptionStateExpired      SubscriptionState = "expired"
+	SubscriptionStateRefunded     SubscriptionState = "refunded"
+	SubscriptionStateRevoked      SubscriptionState = "revoked"
+)
+
+// AppleStatus maps the state to the App Store status code
+// https://developer.apple.com/documentation/appstoreserverapi/status
+func (s SubscriptionState) AppleStatus() int32 {
+	switch s {
+	case SubscriptionStateActive:
+		return 1
+	case SubscriptionStateExpired:
+		return 2
+	case SubscriptionStateBillingRetry:
+		return 3
+	case SubscriptionStateGracePeriod:
+		return 4
+	case SubscriptionStateRefunded, SubscriptionStateRevoked:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// HasAccess reports whether the subscriber is entitled to the subscription content
+func (s SubscriptionState) HasAccess() bool {
+	return s == SubscriptionStateActive || s == SubscriptionStateGracePeriod
+}
+
+// SubscriptionTransition is one recorded change of a subscription state
+type SubscriptionTransition struct {
+	From             SubscriptionState  `bson:"from" json:"from"`
+	To               SubscriptionState  `bson:"to" json:"to"`
+	NotificationType NotificationTypeV2 `bson:"notificationType" json:"notificationType"`
+	Subtype          SubtypeV2          `bson:"subtype,omitempty" json:"subtype,omitempty"`
+	NotificationUUID string             `bson:"notificationUUID" json:"notificationUUID"`
+	EventDate        int64              `bson:"eventDate" json:"eventDate"`
+	TransitionedAt   time.Time          `bson:"transitionedAt" json:"transitionedAt"`
+}
+
+// SubscriptionEntitlement is the notification-driven state of one subscription, keyed by originalTransactionId
+type SubscriptionEntitlement struct {
+	ID                          primitive.ObjectID       `bson:"_id,omitempty" json:"id,omitempty"`
+	OriginalTransactionId       string                   `bson:"originalTransactionId" json:"originalTransactionId"`
+	ProductId                   string                   `bson:"productId,omitempty" json:"productId,omitempty"`
+	SubscriptionGroupIdentifier string                   `bson:"subscriptionGroupIdentifier,omitempty" json:"subscriptionGroupIdentifier,omitempty"`
+	BundleId                    string                   `bson:"bundleId,omitempty" json:"bundleId,omitempty"`
+	Environment                 Environment              `bson:"environment,omitempty" json:"environment,omitempty"`
+	State                       SubscriptionState        `bson:"state" json:"state"`
+	ExpiresDate                 int64                    `bson:"expiresDate,omitempty" json:"expiresDate,omitempty"`
+	LastNotificationUUID        string                   `bson:"lastNotificationUUID,omitempty" json:"lastNotificationUUID,omitempty"`
+	LastEventDate               int64                    `bson:"lastEventDate" json:"lastEventDate"`
+	History                     []SubscriptionTransition `bson:"history" json:"history"`
+	Version                     int64                    `bson:"version" json:"version"`
+	CreatedAt                   time.Time                `bson:"createdAt" json:"createdAt"`
+	UpdatedAt                   time.Time                `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (e *SubscriptionEntitlement) CollectionName() string {
+	return "subscriptionEntitlements"
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaxRate is the sales tax or VAT included in the prices of an App Store storefront, as a share of the
+// price before tax: 0.19 for a 19% VAT
+type TaxRate struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Storefront string             `bson:"storefront" json:"storefront"`
+	Rate       float64            `bson:"rate" json:"rate"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (r *TaxRate) CollectionName() string {
+	return "taxRates"
+}
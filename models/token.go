@@ -30,6 +30,7 @@ type Token struct {
 	BundleID      string       // Your app’s bundle ID
 	Issuer        string       // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
 	Audience      string       // Your audience (aud) for generating the token (some Apple APIs require a specific aud, such as Sign In with Apple ID).
+	Subject       string       // Your Sign in with Apple client ID (sub). When set, the token is a Sign in with Apple client secret without bid and nonce.
 	Sandbox       bool         // default is Production
 	IssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
 	ExpiredAtFunc func() int64 // The token’s expiration time func.
@@ -95,21 +96,26 @@ func (t *Token) Generate() error {
 	if audience == "" {
 		audience = DefaultAudience
 	}
+	claims := jwt.MapClaims{
+		"iss": t.Issuer,
+		"iat": issuedAt,
+		"exp": expiredAt,
+		"aud": audience,
+	}
+	// Doc: https://developer.apple.com/documentation/accountorganizationaldatasharing/creating-a-client-secret
+	if t.Subject != "" {
+		claims["sub"] = t.Subject
+	} else {
+		claims["nonce"] = uuid.New()
+		claims["bid"] = t.BundleID
+	}
 	jwtToken := &jwt.Token{
 		Header: map[string]interface{}{
 			"alg": "ES256",
 			"kid": t.KeyID,
 			"typ": "JWT",
 		},
-
-		Claims: jwt.MapClaims{
-			"iss":   t.Issuer,
-			"iat":   issuedAt,
-			"exp":   expiredAt,
-			"aud":   audience,
-			"nonce": uuid.New(),
-			"bid":   t.BundleID,
-		},
+		Claims: claims,
 		Method: jwt.SigningMethodES256,
 	}
 
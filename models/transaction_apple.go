@@ -14,9 +14,87 @@ type TransactionApple struct {
 	StatusText            string             `bson:"statusText" json:"statusText"`
 	BundleId              string             `bson:"bundleId,omitempty" json:"bundleId,omitempty"`
 	Environment           Environment        `bson:"environment,omitempty" json:"environment,omitempty"`
+	Renewal               *RenewalSummary    `bson:"renewal,omitempty" json:"renewal,omitempty"`
 	UpdatedAt             time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
 func (t *TransactionApple) CollectionName() string {
 	return "transactionApple"
 }
+
+// StatusText describes an App Store subscription status code
+func StatusText(status int32) string {
+	switch status {
+	case 1:
+		return "Active"
+	case 2:
+		return "Expired"
+	case 3:
+		return "Billing Retry"
+	case 4:
+		return "Billing Grace Period"
+	case 5:
+		return "Revoked"
+	default:
+		return "Unknown"
+	}
+}
+
+// RenewalSummary is the auto-renew state of a subscription taken from its signed renewal info
+type RenewalSummary struct {
+	WillAutoRenew           bool     `bson:"willAutoRenew" json:"willAutoRenew"`
+	AutoRenewStatus         int32    `bson:"autoRenewStatus" json:"autoRenewStatus"`
+	AutoRenewProductId      string   `bson:"autoRenewProductId,omitempty" json:"autoRenewProductId,omitempty"`
+	ExpirationIntent        int32    `bson:"expirationIntent,omitempty" json:"expirationIntent,omitempty"`
+	ExpirationIntentText    string   `bson:"expirationIntentText,omitempty" json:"expirationIntentText,omitempty"`
+	GracePeriodExpiresDate  int64    `bson:"gracePeriodExpiresDate,omitempty" json:"gracePeriodExpiresDate,omitempty"`
+	PriceIncreaseStatus     *int32   `bson:"priceIncreaseStatus,omitempty" json:"priceIncreaseStatus,omitempty"`
+	PriceIncreasePending    bool     `bson:"priceIncreasePending" json:"priceIncreasePending"`
+	RenewalPrice            int64    `bson:"renewalPrice,omitempty" json:"renewalPrice,omitempty"`
+	Currency                string   `bson:"currency,omitempty" json:"currency,omitempty"`
+	RenewalDate             int64    `bson:"renewalDate,omitempty" json:"renewalDate,omitempty"`
+	EligibleWinBackOfferIds []string `bson:"eligibleWinBackOfferIds,omitempty" json:"eligibleWinBackOfferIds,omitempty"`
+	SignedDate              int64    `bson:"signedDate" json:"signedDate"`
+}
+
+// NewRenewalSummary extracts the auto-renew state from decoded renewal info
+func NewRenewalSummary(info *JWSRenewalInfoDecodedPayload) *RenewalSummary {
+	if info == nil {
+		return nil
+	}
+	return &RenewalSummary{
+		WillAutoRenew:          info.AutoRenewStatus == 1,
+		AutoRenewStatus:        info.AutoRenewStatus,
+		AutoRenewProductId:     info.AutoRenewProductId,
+		ExpirationIntent:       info.ExpirationIntent,
+		ExpirationIntentText:   ExpirationIntentText(info.ExpirationIntent),
+		GracePeriodExpiresDate: info.GracePeriodExpiresDate,
+		PriceIncreaseStatus:    info.PriceIncreaseStatus,
+		// 0 means the customer has not yet responded to a price increase that needs consent
+		PriceIncreasePending:    info.PriceIncreaseStatus != nil && *info.PriceIncreaseStatus == 0,
+		RenewalPrice:            info.RenewalPrice,
+		Currency:                info.Currency,
+		RenewalDate:             info.RenewalDate,
+		EligibleWinBackOfferIds: info.EligibleWinBackOfferIds,
+		SignedDate:              info.SignedDate,
+	}
+}
+
+// ExpirationIntentText describes why a subscription expired
+// https://developer.apple.com/documentation/appstoreserverapi/expirationintent
+func ExpirationIntentText(intent int32) string {
+	switch intent {
+	case 0:
+		return ""
+	case 1:
+		return "Customer canceled"
+	case 2:
+		return "Billing error"
+	case 3:
+		return "Customer did not consent to a price increase"
+	case 4:
+		return "Product not available at renewal"
+	default:
+		return "Other"
+	}
+}
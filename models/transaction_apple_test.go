@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestNewRenewalSummary(t *testing.T) {
+	pending, consented := int32(0), int32(1)
+
+	tests := []struct {
+		name            string
+		info            *JWSRenewalInfoDecodedPayload
+		wantAutoRenew   bool
+		wantPriceChange bool
+		wantIntent      string
+	}{
+		{name: "renewing", info: &JWSRenewalInfoDecodedPayload{AutoRenewStatus: 1}, wantAutoRenew: true},
+		{name: "canceled", info: &JWSRenewalInfoDecodedPayload{AutoRenewStatus: 0, ExpirationIntent: 1}, wantIntent: "Customer canceled"},
+		{name: "price increase awaiting consent", info: &JWSRenewalInfoDecodedPayload{AutoRenewStatus: 1, PriceIncreaseStatus: &pending}, wantAutoRenew: true, wantPriceChange: true},
+		{name: "price increase consented", info: &JWSRenewalInfoDecodedPayload{AutoRenewStatus: 1, PriceIncreaseStatus: &consented}, wantAutoRenew: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewRenewalSummary(tt.info)
+			if got.WillAutoRenew != tt.wantAutoRenew {
+				t.Errorf("WillAutoRenew = %v, want %v", got.WillAutoRenew, tt.wantAutoRenew)
+			}
+			if got.PriceIncreasePending != tt.wantPriceChange {
+				t.Errorf("PriceIncreasePending = %v, want %v", got.PriceIncreasePending, tt.wantPriceChange)
+			}
+			if got.ExpirationIntentText != tt.wantIntent {
+				t.Errorf("ExpirationIntentText = %q, want %q", got.ExpirationIntentText, tt.wantIntent)
+			}
+		})
+	}
+
+	if NewRenewalSummary(nil) != nil {
+		t.Errorf("NewRenewalSummary(nil) != nil")
+	}
+}
@@ -1,7 +1,69 @@
 package models
 
+import "slices"
+
+// Roles a user can hold. Accounts created by registration or Sign in with Apple are end-users.
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleService = "service"
+	RoleUser    = "user"
+)
+
+// Permission names an operation on other users' data or on the backend itself.
+// End-users hold none; what they can do with their own record is not a permission.
+type Permission string
+
+const (
+	PermReadUsers           Permission = "users:read"           // read any user's record and timeline
+	PermWriteUsers          Permission = "users:write"          // create users and update any user's record
+	PermManageRoles         Permission = "users:roles"          // change a user's role
+	PermReadSubscriptions   Permission = "subscriptions:read"   // read refund history and renewal extensions
+	PermManageSubscriptions Permission = "subscriptions:manage" // extend subscription renewal dates
+	PermReadReports         Permission = "reports:read"         // read subscription and revenue reports
+	PermRunJobs             Permission = "jobs:run"             // run backfills, notification health checks and exchange rate refreshes
+)
+
+// rolePermissions lists what each staff role may do; admins may do everything
+var rolePermissions = map[string][]Permission{
+	RoleSupport: {PermReadUsers, PermWriteUsers, PermReadSubscriptions, PermManageSubscriptions},
+	RoleService: {PermReadUsers, PermReadSubscriptions, PermReadReports, PermRunJobs},
+	RoleUser:    {},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok || role == RoleAdmin
+}
+
+// HasPermission reports whether role grants permission. Unknown and empty roles grant nothing.
+func HasPermission(role string, permission Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// IsStaff reports whether role grants any permission
+func IsStaff(role string) bool {
+	return role == RoleAdmin || len(rolePermissions[role]) > 0
+}
+
 type CreateUserRequest struct {
 	AppleAppId            int64  `json:"appleAppIid" binding:"required"`
-	TransactionId         string `json:"transactionId""`
+	TransactionId         string `json:"transactionId"`
 	OriginalTransactionId string `json:"originalTransactionId" binding:"required"`
 }
+
+// UpdateUserRequest changes the profile fields of a user; omitted fields are left as they are
+type UpdateUserRequest struct {
+	Username               *string `json:"username"`
+	ConsumptionDataConsent *bool   `json:"consumption_data_consent"`
+	PlayTimeMinutes        *int64  `json:"play_time_minutes" binding:"omitempty,min=0"`
+}
+
+// UpdateRoleRequest sets the role of a user
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{role: RoleAdmin, permission: PermManageRoles, want: true},
+		{role: RoleSupport, permission: PermManageSubscriptions, want: true},
+		{role: RoleSupport, permission: PermManageRoles},
+		{role: RoleSupport, permission: PermRunJobs},
+		{role: RoleService, permission: PermRunJobs, want: true},
+		{role: RoleService, permission: PermWriteUsers},
+		{role: RoleUser, permission: PermReadUsers},
+		{role: "", permission: PermReadUsers},
+		{role: "superuser", permission: PermReadUsers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+string(tt.permission), func(t *testing.T) {
+			if got := HasPermission(tt.role, tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q, %s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoles(t *testing.T) {
+	for _, role := range []string{RoleAdmin, RoleSupport, RoleService, RoleUser} {
+		if !ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false", role)
+		}
+	}
+	if ValidRole("") || ValidRole("superuser") {
+		t.Error("ValidRole() accepted an unknown role")
+	}
+	if IsStaff(RoleUser) || IsStaff("") || !IsStaff(RoleService) {
+		t.Error("IsStaff() must hold for roles with permissions only")
+	}
+}
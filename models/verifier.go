@@ -0,0 +1,140 @@
+package models
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signed data verification errors
+var (
+	ErrVerificationMalformed          = errors.New("appstore: malformed signed data")
+	ErrVerificationInvalidChain       = errors.New("appstore: invalid certificate chain")
+	ErrVerificationInvalidSignature   = errors.New("appstore: invalid signature")
+	ErrVerificationInvalidBundleID    = errors.New("appstore: unexpected bundle id")
+	ErrVerificationInvalidEnvironment = errors.New("appstore: unexpected environment")
+)
+
+// SignedDataVerifier verifies JWS data signed by the App Store: the x5c chain must lead to a
+// trusted root as of the payload's signedDate, the signature must match the leaf certificate,
+// and the payload must belong to the expected bundle and environments.
+// Verification is offline and does not check revocation through OCSP.
+type SignedDataVerifier struct {
+	cert         *Cert
+	bundleID     string
+	environments []Environment
+}
+
+// NewSignedDataVerifier creates a verifier. A nil rootCertPool trusts only Apple Root CA - G3,
+// an empty bundleID skips the bundle check and no environments accepts any environment.
+func NewSignedDataVerifier(rootCertPool *x509.CertPool, bundleID string, environments ...Environment) *SignedDataVerifier {
+	return &SignedDataVerifier{
+		cert:         newCert(rootCertPool),
+		bundleID:     bundleID,
+		environments: environments,
+	}
+}
+
+// VerifyTransaction verifies and decodes a signedTransactionInfo
+func (v *SignedDataVerifier) VerifyTransaction(signedTransaction string) (*JWSTransaction, error) {
+	var transaction JWSTransaction
+	if err := v.verify(signedTransaction, &transaction); err != nil {
+		return nil, err
+	}
+	if err := v.checkBundle(transaction.BundleID); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(transaction.Environment); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// VerifyRenewalInfo verifies and decodes a signedRenewalInfo
+func (v *SignedDataVerifier) VerifyRenewalInfo(signedRenewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	var renewalInfo JWSRenewalInfoDecodedPayload
+	if err := v.verify(signedRenewalInfo, &renewalInfo); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(renewalInfo.Environment); err != nil {
+		return nil, err
+	}
+	return &renewalInfo, nil
+}
+
+// VerifyNotification verifies and decodes the signedPayload of an App Store Server Notification
+func (v *SignedDataVerifier) VerifyNotification(signedPayload string) (*NotificationPayload, error) {
+	var payload NotificationPayload
+	if err := v.verify(signedPayload, &payload); err != nil {
+		return nil, err
+	}
+	if err := v.checkBundle(payload.Data.BundleID); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(Environment(payload.Data.Environment)); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func (v *SignedDataVerifier) verify(signed string, claims jwt.Claims) error {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: expected 3 parts, got %d", ErrVerificationMalformed, len(parts))
+	}
+
+	// signedDate is read before the signature is checked so the chain is validated as of signing time
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationMalformed, err)
+	}
+	var signing struct {
+		SignedDate int64 `json:"signedDate"`
+	}
+	if err := json.Unmarshal(payload, &signing); err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationMalformed, err)
+	}
+	var at time.Time
+	if signing.SignedDate > 0 {
+		at = time.UnixMilli(signing.SignedDate)
+	}
+
+	pk, err := v.cert.extractPublicKeyFromTokenAt(signed, at)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationInvalidChain, err)
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	_, err = parser.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) {
+		return pk, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationInvalidSignature, err)
+	}
+	return nil
+}
+
+func (v *SignedDataVerifier) checkBundle(bundleID string) error {
+	if v.bundleID != "" && bundleID != v.bundleID {
+		return fmt.Errorf("%w: %q", ErrVerificationInvalidBundleID, bundleID)
+	}
+	return nil
+}
+
+func (v *SignedDataVerifier) checkEnvironment(environment Environment) error {
+	if len(v.environments) == 0 {
+		return nil
+	}
+	for _, allowed := range v.environments {
+		if environment == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrVerificationInvalidEnvironment, environment)
+}
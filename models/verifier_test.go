@@ -0,0 +1,141 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testChain struct {
+	root    *x509.Certificate
+	x5c     []string
+	leafKey *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, template, parent *x509.Certificate, pub *ecdsa.PublicKey, signer *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+func newTestChain(t *testing.T, notBefore, notAfter time.Time) *testChain {
+	t.Helper()
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	intermediateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	ca := func(serial int64, name string, ext ...pkix.Extension) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			ExtraExtensions:       ext,
+		}
+	}
+	root := newTestCert(t, ca(1, "Test Root"), ca(1, "Test Root"), &rootKey.PublicKey, rootKey)
+	intermediate := newTestCert(t, ca(2, "Test WWDR", pkix.Extension{Id: oidAppleWWDRIntermediate, Value: []byte{5, 0}}), root, &intermediateKey.PublicKey, rootKey)
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:       notBefore,
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleReceiptSigning, Value: []byte{5, 0}}},
+	}, intermediate, &leafKey.PublicKey, intermediateKey)
+
+	return &testChain{
+		root: root,
+		x5c: []string{
+			base64.StdEncoding.EncodeToString(leaf.Raw),
+			base64.StdEncoding.EncodeToString(intermediate.Raw),
+			base64.StdEncoding.EncodeToString(root.Raw),
+		},
+		leafKey: leafKey,
+	}
+}
+
+func (c *testChain) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.root)
+	return pool
+}
+
+func (c *testChain) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = c.x5c
+	signed, err := token.SignedString(c.leafKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+func TestSignedDataVerifier_VerifyTransaction(t *testing.T) {
+	now := time.Now()
+	chain := newTestChain(t, now.Add(-time.Hour), now.Add(time.Hour))
+	expiredChain := newTestChain(t, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	untrusted := newTestChain(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	claims := func(bundleID string, env Environment, signedDate time.Time) jwt.MapClaims {
+		return jwt.MapClaims{
+			"transactionId": "2000",
+			"bundleId":      bundleID,
+			"environment":   env,
+			"signedDate":    signedDate.UnixMilli(),
+		}
+	}
+	tampered := chain.sign(t, claims("fake.bundle.id", Production, now))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name    string
+		signed  string
+		wantErr error
+	}{
+		{name: "valid", signed: chain.sign(t, claims("fake.bundle.id", Production, now))},
+		{name: "signed while the chain was valid", signed: expiredChain.sign(t, claims("fake.bundle.id", Production, now.Add(-36*time.Hour)))},
+		{name: "signed after the chain expired", signed: expiredChain.sign(t, claims("fake.bundle.id", Production, now)), wantErr: ErrVerificationInvalidChain},
+		{name: "untrusted root", signed: untrusted.sign(t, claims("fake.bundle.id", Production, now)), wantErr: ErrVerificationInvalidChain},
+		{name: "bad signature", signed: tampered, wantErr: ErrVerificationInvalidSignature},
+		{name: "wrong bundle id", signed: chain.sign(t, claims("other.bundle.id", Production, now)), wantErr: ErrVerificationInvalidBundleID},
+		{name: "wrong environment", signed: chain.sign(t, claims("fake.bundle.id", Sandbox, now)), wantErr: ErrVerificationInvalidEnvironment},
+		{name: "not a jws", signed: "not-a-jws", wantErr: ErrVerificationMalformed},
+	}
+
+	pool := chain.pool()
+	pool.AddCert(expiredChain.root)
+	verifier := NewSignedDataVerifier(pool, "fake.bundle.id", Production)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.VerifyTransaction(tt.signed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.TransactionID != "2000" {
+				t.Errorf("VerifyTransaction() TransactionID = %q, want %q", got.TransactionID, "2000")
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const appleNonceCollection = "appleNonces"
+
+// SaveAppleNonce stores a newly issued Sign in with Apple nonce
+func SaveAppleNonce(nonce *models.AppleNonce) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(appleNonceCollection, durableWrites())
+	_, err := coll.InsertOne(ctx, nonce)
+	return err
+}
+
+// ConsumeAppleNonce atomically deletes an unexpired nonce, so it can be used once. It fails with
+// mongo.ErrNoDocuments when the nonce is unknown, expired or already used.
+func ConsumeAppleNonce(nonceHash string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(appleNonceCollection, durableWrites())
+	filter := bson.M{"nonceHash": nonceHash, "expiresAt": bson.M{"$gt": now}}
+	return coll.FindOneAndDelete(ctx, filter).Err()
+}
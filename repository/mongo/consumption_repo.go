@@ -0,0 +1,58 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const consumptionCollection = "consumptionResponses"
+
+// SaveConsumptionAudit upserts the audit of a CONSUMPTION_REQUEST by notificationUUID.
+// attempts is added to the attempts already recorded.
+func SaveConsumptionAudit(audit *models.ConsumptionAudit, attempts int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{
+		"originalTransactionId": audit.OriginalTransactionId,
+		"transactionId":         audit.TransactionId,
+		"userId":                audit.UserID,
+		"environment":           audit.Environment,
+		"status":                audit.Status,
+		"request":               audit.Request,
+		"responseStatus":        audit.ResponseStatus,
+		"lastError":             audit.LastError,
+		"deadline":              audit.Deadline,
+		"updatedAt":             now,
+	}
+	if audit.SentAt != nil {
+		set["sentAt"] = audit.SentAt
+	}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(consumptionCollection, durableWrites())
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"notificationUUID": audit.NotificationUUID},
+		bson.M{
+			"$set":         set,
+			"$inc":         bson.M{"attempts": attempts},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// GetConsumptionAudit retrieves the audit of a CONSUMPTION_REQUEST
+func GetConsumptionAudit(notificationUUID string) (*models.ConsumptionAudit, error) {
+	var audit models.ConsumptionAudit
+	if err := GetOne(consumptionCollection, bson.M{"notificationUUID": notificationUUID}, &audit); err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
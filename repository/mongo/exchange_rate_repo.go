@@ -0,0 +1,59 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const exchangeRateCollection = "exchangeRates"
+
+// SaveExchangeRates upserts rates by day, base and currency, returning how many were inserted or changed
+func SaveExchangeRates(rates []*models.ExchangeRate) (int64, error) {
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(rates))
+	for _, rate := range rates {
+		filter := bson.M{"date": rate.Date, "base": rate.Base, "currency": rate.Currency}
+		update := bson.M{"$set": bson.M{"rate": rate.Rate, "updatedAt": now}}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(exchangeRateCollection)
+	result, err := coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return result.UpsertedCount + result.ModifiedCount, nil
+}
+
+// GetExchangeRates retrieves every stored exchange rate, oldest day first
+func GetExchangeRates() ([]*models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(exchangeRateCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	cursor, err := coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []*models.ExchangeRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
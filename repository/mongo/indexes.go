@@ -0,0 +1,91 @@
+package mongoRepo
+
+import (
+	"context"
+	"fmt"
+	"simvizlab-backend/infra/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexes lists the indexes each collection needs, created at startup
+var indexes = map[string][]mongo.IndexModel{
+	notificationCollection: {
+		{Keys: bson.D{{Key: "notificationUUID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}, {Key: "signedDate", Value: 1}}},
+	},
+	notificationProcessingCollection: {
+		{Keys: bson.D{{Key: "notificationUUID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "updatedAt", Value: 1}}},
+	},
+	subscriptionEntitlementCollection: {
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	transactionCollection: {
+		{Keys: bson.D{{Key: transactionIdField, Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{transactionIdField: bson.M{"$gt": ""}})},
+		{Keys: bson.D{{Key: transactionOriginalTransactionField, Value: 1}, {Key: transactionPurchaseDateField, Value: 1}}},
+		{Keys: bson.D{{Key: transactionAppAccountTokenField, Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: transactionEnvironmentField, Value: 1}, {Key: transactionPurchaseDateField, Value: 1}}},
+	},
+	renewalExtensionCollection: {
+		{Keys: bson.D{{Key: "requestIdentifier", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	transactionAppleCollection: {
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}}},
+	},
+	jobStateCollection: {
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	notificationHealthCollection: {
+		{Keys: bson.D{{Key: "testNotificationToken", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "requestedAt", Value: -1}}},
+	},
+	userCollection: {
+		{Keys: bson.D{{Key: "appAccountToken", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"appAccountToken": bson.M{"$gt": ""}})},
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}})},
+		{Keys: bson.D{{Key: "appleSub", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"appleSub": bson.M{"$gt": ""}})},
+	},
+	refreshTokenCollection: {
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "familyId", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		// expired tokens are dropped by Mongo; a revoked token stays until then to detect its reuse
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	appleNonceCollection: {
+		{Keys: bson.D{{Key: "nonceHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	exchangeRateCollection: {
+		{Keys: bson.D{{Key: "date", Value: 1}, {Key: "base", Value: 1}, {Key: "currency", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	taxRateCollection: {
+		{Keys: bson.D{{Key: "storefront", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	consumptionCollection: {
+		{Keys: bson.D{{Key: "notificationUUID", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	refundCollection: {
+		{Keys: bson.D{{Key: "transactionId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "originalTransactionId", Value: 1}}},
+	},
+}
+
+// EnsureIndexes creates the indexes declared for every collection
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	db := database.MongoClient.Database(defaultDatabaseName)
+	for collection, indexModels := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, indexModels); err != nil {
+			return fmt.Errorf("failed to create indexes for %s: %w", collection, err)
+		}
+	}
+	return nil
+}
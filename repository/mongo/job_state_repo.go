@@ -0,0 +1,100 @@
+package mongoRepo
+
+import (
+	"context"
+	"errors"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const jobStateCollection = "jobState"
+
+// ErrJobNotClaimed is returned when releasing a job the caller does not hold
+var ErrJobNotClaimed = errors.New("job is not claimed by this owner")
+
+// ClaimJob atomically takes the lease of a background job for owner.
+// It reports false without error when another owner holds an unexpired lease.
+func ClaimJob(name, owner string, lease time.Duration) (*models.JobState, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"name": name,
+		"$or": bson.A{
+			bson.M{"leaseExpiresAt": bson.M{"$exists": false}},
+			bson.M{"leaseExpiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set":         bson.M{"claimedBy": owner, "leaseExpiresAt": now.Add(lease), "updatedAt": now},
+		"$setOnInsert": bson.M{"highWaterMark": int64(0), "createdAt": now},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(jobStateCollection, durableWrites())
+	var state models.JobState
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&state)
+	if mongo.IsDuplicateKeyError(err) {
+		// The job exists and its lease is held by someone else
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &state, true, nil
+}
+
+// ReleaseJob records the outcome of a run and gives up the lease held by owner.
+// The high-water mark only moves forward.
+func ReleaseJob(name, owner string, highWaterMark int64, cause error) error {
+	return finishJob(name, owner, highWaterMark, cause, true)
+}
+
+// RecordJobRun records the outcome of a run and keeps the lease held by owner until it expires,
+// so other replicas skip the job until then
+func RecordJobRun(name, owner string, highWaterMark int64, cause error) error {
+	return finishJob(name, owner, highWaterMark, cause, false)
+}
+
+func finishJob(name, owner string, highWaterMark int64, cause error, release bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+	update := bson.M{
+		"$set": bson.M{"lastRunAt": now, "lastError": lastError, "updatedAt": now},
+		"$max": bson.M{"highWaterMark": highWaterMark},
+	}
+	if release {
+		update["$unset"] = bson.M{"claimedBy": "", "leaseExpiresAt": ""}
+	}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(jobStateCollection, durableWrites())
+	result, err := coll.UpdateOne(ctx, bson.M{"name": name, "claimedBy": owner}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrJobNotClaimed
+	}
+	return nil
+}
+
+// GetJobState retrieves the state of a background job
+func GetJobState(name string) (*models.JobState, error) {
+	var state models.JobState
+	if err := GetOne(jobStateCollection, bson.M{"name": name}, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,51 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const notificationHealthCollection = "notificationHealthChecks"
+
+// SaveNotificationHealthCheck upserts a health check by its testNotificationToken
+func SaveNotificationHealthCheck(check *models.NotificationHealthCheck) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationHealthCollection)
+	_, err := coll.ReplaceOne(ctx, bson.M{"testNotificationToken": check.TestNotificationToken}, check, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetNotificationHealthCheck retrieves a health check by its testNotificationToken
+func GetNotificationHealthCheck(token string) (*models.NotificationHealthCheck, error) {
+	var check models.NotificationHealthCheck
+	if err := GetOne(notificationHealthCollection, bson.M{"testNotificationToken": token}, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// GetNotificationHealthChecks retrieves the most recent health checks, newest first
+func GetNotificationHealthChecks(limit int64) ([]*models.NotificationHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationHealthCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "requestedAt", Value: -1}}).SetLimit(limit)
+	cursor, err := coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	checks := []*models.NotificationHealthCheck{}
+	if err := cursor.All(ctx, &checks); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
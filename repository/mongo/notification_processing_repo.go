@@ -0,0 +1,166 @@
+package mongoRepo
+
+import (
+	"context"
+	"errors"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const notificationProcessingCollection = "notificationProcessing"
+
+// ErrNotificationNotClaimed is returned when completing a notification the caller does not hold
+var ErrNotificationNotClaimed = errors.New("notification is not claimed by this owner")
+
+// MarkNotificationReceived records a notification in the received state unless it is already tracked
+func MarkNotificationReceived(notificationUUID string, notificationType models.NotificationTypeV2) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationProcessingCollection)
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"notificationUUID": notificationUUID},
+		bson.M{"$setOnInsert": bson.M{
+			"notificationUUID": notificationUUID,
+			"notificationType": notificationType,
+			"state":            models.NotificationReceived,
+			"attempts":         0,
+			"createdAt":        now,
+			"updatedAt":        now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimNotification atomically moves a notification into the processing state for owner.
+// A notification can be claimed when it is new, received, failed, or its previous lease has expired.
+// It reports false without error when another owner holds the claim or it was already applied.
+func ClaimNotification(notificationUUID string, notificationType models.NotificationTypeV2, owner string, lease time.Duration) (*models.NotificationProcessingRecord, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	leaseExpiresAt := now.Add(lease)
+	filter := bson.M{
+		"notificationUUID": notificationUUID,
+		"$or": bson.A{
+			bson.M{"state": bson.M{"$in": bson.A{models.NotificationReceived, models.NotificationFailed}}},
+			bson.M{"state": models.NotificationProcessing, "leaseExpiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"state":          models.NotificationProcessing,
+			"claimedBy":      owner,
+			"leaseExpiresAt": leaseExpiresAt,
+			"updatedAt":      now,
+		},
+		"$inc":         bson.M{"attempts": 1},
+		"$setOnInsert": bson.M{"notificationType": notificationType, "createdAt": now},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationProcessingCollection, durableWrites())
+	var record models.NotificationProcessingRecord
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&record)
+	if mongo.IsDuplicateKeyError(err) {
+		// The record exists but is applied or leased by someone else
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &record, true, nil
+}
+
+// CompleteNotification marks a notification claimed by owner as applied
+func CompleteNotification(notificationUUID, owner string) error {
+	now := time.Now()
+	return finishNotification(notificationUUID, owner, bson.M{
+		"state":     models.NotificationApplied,
+		"appliedAt": now,
+		"lastError": "",
+		"updatedAt": now,
+	})
+}
+
+// FailNotification releases a notification claimed by owner in the failed state so it can be retried
+func FailNotification(notificationUUID, owner string, cause error) error {
+	return finishNotification(notificationUUID, owner, bson.M{
+		"state":     models.NotificationFailed,
+		"lastError": cause.Error(),
+		"updatedAt": time.Now(),
+	})
+}
+
+func finishNotification(notificationUUID, owner string, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationProcessingCollection, durableWrites())
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"notificationUUID": notificationUUID, "state": models.NotificationProcessing, "claimedBy": owner},
+		bson.M{"$set": set, "$unset": bson.M{"leaseExpiresAt": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotificationNotClaimed
+	}
+	return nil
+}
+
+// GetNotificationProcessing retrieves the processing record of a notification
+func GetNotificationProcessing(notificationUUID string) (*models.NotificationProcessingRecord, error) {
+	var record models.NotificationProcessingRecord
+	if err := GetOne(notificationProcessingCollection, bson.M{"notificationUUID": notificationUUID}, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetUnappliedNotificationUUIDs lists notifications that were received or failed, or whose lease expired,
+// have not been touched since before and were attempted fewer than maxAttempts times
+func GetUnappliedNotificationUUIDs(before time.Time, maxAttempts int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"updatedAt": bson.M{"$lt": before},
+		"attempts":  bson.M{"$lt": maxAttempts},
+		"$or": bson.A{
+			bson.M{"state": bson.M{"$in": bson.A{models.NotificationReceived, models.NotificationFailed}}},
+			bson.M{"state": models.NotificationProcessing, "leaseExpiresAt": bson.M{"$lt": now}},
+		},
+	}
+	opts := options.Find().SetProjection(bson.M{"notificationUUID": 1}).SetSort(bson.D{{Key: "updatedAt", Value: 1}})
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationProcessingCollection)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.NotificationProcessingRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	uuids := make([]string, 0, len(records))
+	for _, record := range records {
+		uuids = append(uuids, record.NotificationUUID)
+	}
+	return uuids, nil
+}
@@ -0,0 +1,71 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const notificationCollection = "appStoreNotifications"
+
+// durableWrites acknowledges a write only once it is journaled on a majority of members
+func durableWrites() *options.CollectionOptions {
+	journal := true
+	return options.Collection().SetWriteConcern(&writeconcern.WriteConcern{W: "majority", Journal: &journal})
+}
+
+// SaveNotification durably stores a received notification keyed by its notificationUUID.
+// It reports false without error when the notification was already stored.
+func SaveNotification(notification *models.AppStoreNotification) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationCollection, durableWrites())
+	_, err := coll.InsertOne(ctx, notification)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		logger.Errorf("error saving notification %s to MongoDB: %v", notification.NotificationUUID, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetNotification retrieves a stored notification by its notificationUUID
+func GetNotification(notificationUUID string) (*models.AppStoreNotification, error) {
+	var notification models.AppStoreNotification
+	if err := GetOne(notificationCollection, bson.M{"notificationUUID": notificationUUID}, &notification); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// GetNotificationsByOriginalTransactions retrieves the stored notifications of any of originalTransactionIds,
+// oldest first. The raw signed payload is left out.
+func GetNotificationsByOriginalTransactions(originalTransactionIds []string) ([]*models.AppStoreNotification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(notificationCollection)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "signedDate", Value: 1}}).
+		SetProjection(bson.M{"signedPayload": 0})
+	cursor, err := coll.Find(ctx, bson.M{"originalTransactionId": bson.M{"$in": originalTransactionIds}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []*models.AppStoreNotification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
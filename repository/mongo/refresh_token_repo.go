@@ -0,0 +1,75 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const refreshTokenCollection = "refreshTokens"
+
+// SaveRefreshToken stores a newly issued refresh token
+func SaveRefreshToken(token *models.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refreshTokenCollection, durableWrites())
+	_, err := coll.InsertOne(ctx, token)
+	return err
+}
+
+// GetRefreshToken retrieves a refresh token by the hash of its value, whatever its state
+func GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := GetOne(refreshTokenCollection, bson.M{"tokenHash": tokenHash}, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeRefreshToken atomically revokes an unexpired, unrevoked refresh token in favour of its replacement
+// and returns it, so a token can be exchanged once. It fails with mongo.ErrNoDocuments when the token
+// is unknown, expired or already used.
+func ConsumeRefreshToken(tokenHash, replacedBy string, now time.Time) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"revokedAt": now, "replacedBy": replacedBy}}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refreshTokenCollection, durableWrites())
+	var token models.RefreshToken
+	if err := coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate()).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeRefreshTokenFamily revokes every token descending from the same login
+func RevokeRefreshTokenFamily(familyID string, now time.Time) error {
+	return revokeRefreshTokens(bson.M{"familyId": familyID}, now)
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user, signing them out everywhere
+func RevokeUserRefreshTokens(userID primitive.ObjectID, now time.Time) error {
+	return revokeRefreshTokens(bson.M{"userId": userID}, now)
+}
+
+func revokeRefreshTokens(filter bson.M, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	filter["revokedAt"] = bson.M{"$exists": false}
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refreshTokenCollection, durableWrites())
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": now}})
+	return err
+}
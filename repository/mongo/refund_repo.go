@@ -0,0 +1,89 @@
+package mongoRepo
+
+import (
+	"context"
+	"errors"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const refundCollection = "refunds"
+
+// SaveRefund upserts a refund record by transactionId
+func SaveRefund(refund *models.RefundRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	refund.UpdatedAt = time.Now()
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refundCollection, durableWrites())
+	_, err := coll.ReplaceOne(ctx, bson.M{"transactionId": refund.TransactionId}, refund, options.Replace().SetUpsert(true))
+	return err
+}
+
+// MarkRefundReversed flags the refund of a transaction as reversed.
+// It reports false when no refund was recorded for the transaction.
+func MarkRefundReversed(transactionId string, reversedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refundCollection, durableWrites())
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"transactionId": transactionId},
+		bson.M{"$set": bson.M{"reversed": true, "reversedAt": reversedAt, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// GetRefund retrieves the refund record of a transaction
+func GetRefund(transactionId string) (*models.RefundRecord, error) {
+	var refund models.RefundRecord
+	if err := GetOne(refundCollection, bson.M{"transactionId": transactionId}, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// HasActiveRefund reports whether a transaction has a refund that was not reversed
+func HasActiveRefund(transactionId string) (bool, error) {
+	refund, err := GetRefund(transactionId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !refund.Reversed, nil
+}
+
+// GetRefundsByOriginalTransaction retrieves every refund recorded for an original transaction
+func GetRefundsByOriginalTransaction(originalTransactionId string) ([]*models.RefundRecord, error) {
+	return GetRefundsByOriginalTransactions([]string{originalTransactionId})
+}
+
+// GetRefundsByOriginalTransactions retrieves every refund recorded for any of originalTransactionIds, oldest first
+func GetRefundsByOriginalTransactions(originalTransactionIds []string) ([]*models.RefundRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(refundCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "revocationDate", Value: 1}})
+	cursor, err := coll.Find(ctx, bson.M{"originalTransactionId": bson.M{"$in": originalTransactionIds}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	refunds := []*models.RefundRecord{}
+	if err := cursor.All(ctx, &refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
@@ -0,0 +1,66 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const renewalExtensionCollection = "renewalExtensions"
+
+// SaveRenewalExtension upserts a renewal-date extension by requestIdentifier
+func SaveRenewalExtension(extension *models.RenewalExtension) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	if extension.CreatedAt.IsZero() {
+		extension.CreatedAt = now
+	}
+	extension.UpdatedAt = now
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(renewalExtensionCollection, durableWrites())
+	_, err := coll.ReplaceOne(ctx, bson.M{"requestIdentifier": extension.RequestIdentifier}, extension, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetRenewalExtension retrieves a renewal-date extension by requestIdentifier
+func GetRenewalExtension(requestIdentifier string) (*models.RenewalExtension, error) {
+	var extension models.RenewalExtension
+	if err := GetOne(renewalExtensionCollection, bson.M{"requestIdentifier": requestIdentifier}, &extension); err != nil {
+		return nil, err
+	}
+	return &extension, nil
+}
+
+// GetRenewalExtensions retrieves renewal-date extensions matching filter, newest first
+func GetRenewalExtensions(filter bson.M, limit int64) ([]*models.RenewalExtension, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(renewalExtensionCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	extensions := []*models.RenewalExtension{}
+	if err := cursor.All(ctx, &extensions); err != nil {
+		return nil, err
+	}
+	return extensions, nil
+}
+
+// GetPendingMassExtensions retrieves the mass extensions Apple has not completed yet
+func GetPendingMassExtensions() ([]*models.RenewalExtension, error) {
+	return GetRenewalExtensions(bson.M{"kind": models.RenewalExtensionMass, "status": models.RenewalExtensionPending}, 0)
+}
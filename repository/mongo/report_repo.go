@@ -0,0 +1,226 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Remaining lowercased JWSTransaction fields the reports group and filter on
+const (
+	transactionProductIdField            = "productid"
+	transactionExpiresDateField          = "expiresdate"
+	transactionOriginalPurchaseDateField = "originalpurchasedate"
+	transactionTypeField                 = "type"
+	transactionEnvironmentField          = "environment"
+	transactionReasonField               = "transactionreason"
+	transactionOfferDiscountTypeField    = "offerdiscounttype"
+	transactionRevocationDateField       = "revocationdate"
+	transactionIsUpgradedField           = "isupgraded"
+	transactionPriceField                = "price"
+	transactionCurrencyField             = "currency"
+	transactionStorefrontField           = "storefront"
+)
+
+// reportTimeout is longer than defaultTimeout as the report pipelines scan a whole date range
+const reportTimeout = defaultTimeout * 3
+
+// subscriptionMatch selects the auto-renewable subscription transactions of an environment
+func subscriptionMatch(environment models.Environment) bson.M {
+	return bson.M{transactionTypeField: models.AutoRenewable, transactionEnvironmentField: environment}
+}
+
+// field references a document field inside an aggregation expression
+func field(name string) string {
+	return "$" + name
+}
+
+// purchaseDay formats the purchase date of a transaction as its UTC day
+var purchaseDay = bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": bson.M{"$toDate": field(transactionPurchaseDateField)}}}
+
+// revoked is true for a transaction Apple refunded or revoked
+var revoked = bson.M{"$gt": bson.A{field(transactionRevocationDateField), 0}}
+
+func aggregateTransactions(pipeline bson.A, results interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(transactionCollection)
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
+
+// GetActiveSubscriberCounts counts, per product, the subscriptions whose latest period covers at (epoch ms).
+// Refunded and upgraded-away periods do not count.
+func GetActiveSubscriberCounts(environment models.Environment, at int64) ([]*models.ActiveSubscriberCount, error) {
+	match := subscriptionMatch(environment)
+	match[transactionPurchaseDateField] = bson.M{"$lte": at}
+	match[transactionExpiresDateField] = bson.M{"$gt": at}
+	match[transactionRevocationDateField] = bson.M{"$not": bson.M{"$gt": 0}}
+	match[transactionIsUpgradedField] = bson.M{"$ne": true}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": bson.M{"product": field(transactionProductIdField), "subscription": field(transactionOriginalTransactionField)}}},
+		bson.M{"$group": bson.M{"_id": "$_id.product", "subscribers": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	counts := []*models.ActiveSubscriberCount{}
+	if err := aggregateTransactions(pipeline, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetDailyPurchaseCounts counts, per UTC day in [start, end), the subscription purchases and renewals.
+// Transactions stored without a transactionReason are renewals when they are not the original transaction.
+func GetDailyPurchaseCounts(environment models.Environment, start, end int64) ([]*models.DailyActivity, error) {
+	match := subscriptionMatch(environment)
+	match[transactionPurchaseDateField] = bson.M{"$gte": start, "$lt": end}
+
+	isRenewal := bson.M{"$or": bson.A{
+		bson.M{"$eq": bson.A{field(transactionReasonField), models.TransactionReasonRenewal}},
+		bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{field(transactionReasonField), ""}}, ""}},
+			bson.M{"$ne": bson.A{field(transactionIdField), field(transactionOriginalTransactionField)}},
+		}},
+	}}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":      purchaseDay,
+			"new":      bson.M{"$sum": bson.M{"$cond": bson.A{isRenewal, 0, 1}}},
+			"renewing": bson.M{"$sum": bson.M{"$cond": bson.A{isRenewal, 1, 0}}},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	days := []*models.DailyActivity{}
+	if err := aggregateTransactions(pipeline, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// GetDailyChurnCounts counts, per UTC day in [start, end), the subscriptions whose last known period expired that day.
+// A subscription that renewed later is not churned, so end should not be after now.
+func GetDailyChurnCounts(environment models.Environment, start, end int64) ([]*models.DailyActivity, error) {
+	pipeline := bson.A{
+		bson.M{"$match": subscriptionMatch(environment)},
+		bson.M{"$group": bson.M{"_id": field(transactionOriginalTransactionField), "lastExpiresDate": bson.M{"$max": field(transactionExpiresDateField)}}},
+		bson.M{"$match": bson.M{"lastExpiresDate": bson.M{"$gte": start, "$lt": end}}},
+		bson.M{"$group": bson.M{
+			"_id":     bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": bson.M{"$toDate": "$lastExpiresDate"}}},
+			"churned": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	days := []*models.DailyActivity{}
+	if err := aggregateTransactions(pipeline, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// GetTrialConversions counts, per product, the subscriptions that started a free trial in [start, end)
+// and how many of them have since paid for a period that was not refunded.
+func GetTrialConversions(environment models.Environment, start, end int64) ([]*models.TrialConversion, error) {
+	match := subscriptionMatch(environment)
+	match[transactionPurchaseDateField] = bson.M{"$gte": start}
+
+	isTrial := bson.M{"$eq": bson.A{field(transactionOfferDiscountTypeField), models.OfferDiscountTypeFreeTrial}}
+	isPaid := bson.M{"$and": bson.A{
+		bson.M{"$not": bson.A{isTrial}},
+		bson.M{"$gt": bson.A{field(transactionPriceField), 0}},
+		bson.M{"$not": bson.A{revoked}},
+	}}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":          field(transactionOriginalTransactionField),
+			"trialStart":   bson.M{"$min": bson.M{"$cond": bson.A{isTrial, field(transactionPurchaseDateField), nil}}},
+			"trialProduct": bson.M{"$max": bson.M{"$cond": bson.A{isTrial, field(transactionProductIdField), nil}}},
+			"paid":         bson.M{"$sum": bson.M{"$cond": bson.A{isPaid, 1, 0}}},
+		}},
+		bson.M{"$match": bson.M{"trialStart": bson.M{"$gte": start, "$lt": end}}},
+		bson.M{"$group": bson.M{
+			"_id":       "$trialProduct",
+			"trials":    bson.M{"$sum": 1},
+			"converted": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$paid", 0}}, 1, 0}}},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	conversions := []*models.TrialConversion{}
+	if err := aggregateTransactions(pipeline, &conversions); err != nil {
+		return nil, err
+	}
+	return conversions, nil
+}
+
+// reducedCommissionAge is the time since the original purchase after which Apple takes its reduced
+// commission on a subscription renewal
+const reducedCommissionAge = int64(365 * 24 * 60 * 60 * 1000)
+
+// GetRevenueBuckets sums the prices of the transactions purchased in [start, end) by UTC day, currency,
+// storefront and commission tier. Refunded transactions are summed apart from revenue.
+// Subscriptions qualify for the reduced commission a year after their original purchase; trials and
+// lapses of service within that year are not discounted.
+func GetRevenueBuckets(environment models.Environment, start, end int64) ([]*models.RevenueBucket, error) {
+	match := bson.M{
+		transactionEnvironmentField:  environment,
+		transactionPurchaseDateField: bson.M{"$gte": start, "$lt": end},
+		transactionPriceField:        bson.M{"$gt": 0},
+	}
+
+	reducedCommission := bson.M{"$and": bson.A{
+		bson.M{"$eq": bson.A{field(transactionTypeField), models.AutoRenewable}},
+		bson.M{"$gte": bson.A{
+			bson.M{"$subtract": bson.A{field(transactionPurchaseDateField), field(transactionOriginalPurchaseDateField)}},
+			reducedCommissionAge,
+		}},
+	}}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{
+				"day":               purchaseDay,
+				"currency":          field(transactionCurrencyField),
+				"storefront":        field(transactionStorefrontField),
+				"reducedCommission": reducedCommission,
+			},
+			"transactions": bson.M{"$sum": 1},
+			"revenue":      bson.M{"$sum": bson.M{"$cond": bson.A{revoked, 0, field(transactionPriceField)}}},
+			"refunded":     bson.M{"$sum": bson.M{"$cond": bson.A{revoked, field(transactionPriceField), 0}}},
+		}},
+		bson.M{"$project": bson.M{
+			"_id":               0,
+			"day":               "$_id.day",
+			"currency":          "$_id.currency",
+			"storefront":        "$_id.storefront",
+			"reducedCommission": "$_id.reducedCommission",
+			"transactions":      1,
+			"revenue":           1,
+			"refunded":          1,
+		}},
+		bson.M{"$sort": bson.D{{Key: "currency", Value: 1}, {Key: "storefront", Value: 1}, {Key: "day", Value: 1}}},
+	}
+
+	buckets := []*models.RevenueBucket{}
+	if err := aggregateTransactions(pipeline, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
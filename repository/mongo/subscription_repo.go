@@ -0,0 +1,76 @@
+package mongoRepo
+
+import (
+	"context"
+	"errors"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const subscriptionEntitlementCollection = "subscriptionEntitlements"
+
+// ErrEntitlementConflict is returned when an entitlement was changed concurrently since it was read
+var ErrEntitlementConflict = errors.New("subscription entitlement was modified concurrently")
+
+// GetSubscriptionEntitlement retrieves the entitlement of a subscription by originalTransactionId
+func GetSubscriptionEntitlement(originalTransactionId string) (*models.SubscriptionEntitlement, error) {
+	var entitlement models.SubscriptionEntitlement
+	if err := GetOne(subscriptionEntitlementCollection, bson.M{"originalTransactionId": originalTransactionId}, &entitlement); err != nil {
+		return nil, err
+	}
+	return &entitlement, nil
+}
+
+// GetSubscriptionEntitlements retrieves the entitlements of any of originalTransactionIds
+func GetSubscriptionEntitlements(originalTransactionIds []string) ([]*models.SubscriptionEntitlement, error) {
+	entitlements := []*models.SubscriptionEntitlement{}
+	if len(originalTransactionIds) == 0 {
+		return entitlements, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(subscriptionEntitlementCollection)
+	cursor, err := coll.Find(ctx, bson.M{"originalTransactionId": bson.M{"$in": originalTransactionIds}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &entitlements); err != nil {
+		return nil, err
+	}
+	return entitlements, nil
+}
+
+// SaveSubscriptionEntitlement writes an entitlement if it is unchanged since it was read at version.
+// A version of 0 means the entitlement is new.
+func SaveSubscriptionEntitlement(entitlement *models.SubscriptionEntitlement) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	expected := entitlement.Version
+	entitlement.Version++
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(subscriptionEntitlementCollection, durableWrites())
+	filter := bson.M{"originalTransactionId": entitlement.OriginalTransactionId, "version": expected}
+	result, err := coll.ReplaceOne(ctx, filter, entitlement, options.Replace().SetUpsert(expected == 0))
+	if mongo.IsDuplicateKeyError(err) {
+		entitlement.Version = expected
+		return ErrEntitlementConflict
+	}
+	if err != nil {
+		entitlement.Version = expected
+		return err
+	}
+	if result.MatchedCount == 0 && result.UpsertedCount == 0 {
+		entitlement.Version = expected
+		return ErrEntitlementConflict
+	}
+	return nil
+}
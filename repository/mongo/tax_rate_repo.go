@@ -0,0 +1,58 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const taxRateCollection = "taxRates"
+
+// SaveTaxRates upserts rates by storefront, returning how many were inserted or changed
+func SaveTaxRates(rates []*models.TaxRate) (int64, error) {
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(rates))
+	for _, rate := range rates {
+		filter := bson.M{"storefront": rate.Storefront}
+		update := bson.M{"$set": bson.M{"rate": rate.Rate, "updatedAt": now}}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(taxRateCollection)
+	result, err := coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return result.UpsertedCount + result.ModifiedCount, nil
+}
+
+// GetTaxRates retrieves the tax rate of every storefront
+func GetTaxRates() ([]*models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(taxRateCollection)
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []*models.TaxRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
@@ -0,0 +1,50 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const transactionAppleCollection = "transactionApple"
+
+// UpdateTransactionAppleRenewal stores the renewal state of an original transaction unless a newer one is stored
+func UpdateTransactionAppleRenewal(originalTransactionId string, renewal *models.RenewalSummary) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(transactionAppleCollection)
+	_, err := coll.UpdateMany(ctx,
+		bson.M{
+			"originalTransactionId": originalTransactionId,
+			"$or": bson.A{
+				bson.M{"renewal": bson.M{"$exists": false}},
+				bson.M{"renewal.signedDate": bson.M{"$lte": renewal.SignedDate}},
+			},
+		},
+		bson.M{"$set": bson.M{"renewal": renewal, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// GetTransactionApples retrieves the stored subscription status of any of originalTransactionIds
+func GetTransactionApples(originalTransactionIds []string) ([]*models.TransactionApple, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(transactionAppleCollection)
+	cursor, err := coll.Find(ctx, bson.M{"originalTransactionId": bson.M{"$in": originalTransactionIds}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []*models.TransactionApple{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,75 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JWSTransaction has no bson tags, so the driver stores its fields under their lowercased names
+const (
+	transactionCollection               = "transactions"
+	transactionIdField                  = "transactionid"
+	transactionOriginalTransactionField = "originaltransactionid"
+	transactionAppAccountTokenField     = "appaccounttoken"
+	transactionPurchaseDateField        = "purchasedate"
+	transactionSignedDateField          = "signeddate"
+)
+
+// SaveTransaction upserts a verified transaction by its transactionId.
+// A stored copy signed later than transaction is kept.
+func SaveTransaction(transaction *models.JWSTransaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(transactionCollection)
+	filter := bson.M{transactionIdField: transaction.TransactionID, transactionSignedDateField: bson.M{"$lte": transaction.SignedDate}}
+	_, err := coll.ReplaceOne(ctx, filter, transaction, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// GetCustomerTransactions retrieves the stored transactions of a customer, oldest purchase first.
+// A transaction belongs to the customer when its original transaction is one of originalTransactionIds
+// or when it carries their appAccountToken. Empty ids are ignored, and a customer with neither has none.
+func GetCustomerTransactions(originalTransactionIds []string, appAccountToken string) ([]*models.JWSTransaction, error) {
+	ids := []string{}
+	for _, id := range originalTransactionIds {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	or := bson.A{}
+	if len(ids) > 0 {
+		or = append(or, bson.M{transactionOriginalTransactionField: bson.M{"$in": ids}})
+	}
+	if appAccountToken != "" {
+		or = append(or, bson.M{transactionAppAccountTokenField: appAccountToken})
+	}
+	if len(or) == 0 {
+		return []*models.JWSTransaction{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(transactionCollection)
+	opts := options.Find().SetSort(bson.D{{Key: transactionPurchaseDateField, Value: 1}})
+	cursor, err := coll.Find(ctx, bson.M{"$or": or}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	transactions := []*models.JWSTransaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -0,0 +1,194 @@
+package mongoRepo
+
+import (
+	"context"
+	"simvizlab-backend/infra/database"
+	"simvizlab-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const userCollection = "users"
+
+// GetUserByID retrieves a user by id
+func GetUserByID(id primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := GetOne(userCollection, bson.M{"_id": id}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByAppAccountToken retrieves the user an appAccountToken was issued to
+func GetUserByAppAccountToken(appAccountToken string) (*models.User, error) {
+	var user models.User
+	if err := GetOne(userCollection, bson.M{"appAccountToken": appAccountToken}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserAppAccountToken stores appAccountToken on a user that has none yet and returns the user's token,
+// which is the existing one when another request set it first
+func SetUserAppAccountToken(id primitive.ObjectID, appAccountToken string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "appAccountToken": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{"$set": bson.M{"appAccountToken": appAccountToken}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := GetUserByID(id)
+	if err != nil {
+		return "", err
+	}
+	return user.AppAccountToken, nil
+}
+
+// LinkUserPurchase records the original transaction of a purchase on a user that has none yet.
+// It reports false when the user already had a purchase linked.
+func LinkUserPurchase(id primitive.ObjectID, originalTransactionId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection)
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "originalTransactionId": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{"$set": bson.M{"originalTransactionId": originalTransactionId, "isAppleConnected": true}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// GetUsersPendingAppAccountTokenSync retrieves users with a linked purchase whose appAccountToken
+// has not been set on it at Apple
+func GetUsersPendingAppAccountTokenSync(limit int64) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"originalTransactionId": bson.M{"$gt": ""},
+		"appAccountTokenSynced": bson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// MarkAppAccountTokenSynced records that a user's appAccountToken was set on their purchase at Apple
+func MarkAppAccountTokenSynced(id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection)
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"appAccountTokenSynced": at}})
+	return err
+}
+
+// GetUserByEmail retrieves the user registered with a normalized email address
+func GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := GetOne(userCollection, bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// InsertUser stores a new user, assigning its id. A taken email fails with a duplicate key error.
+func InsertUser(user *models.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	user.ID = primitive.NewObjectID()
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	_, err := coll.InsertOne(ctx, user)
+	return err
+}
+
+// GetUserByAppleSub retrieves the user signed in with an Apple ID, identified by its stable sub
+func GetUserByAppleSub(sub string) (*models.User, error) {
+	var user models.User
+	if err := GetOne(userCollection, bson.M{"appleSub": sub}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LinkUserAppleID records an Apple ID on a user without one, or with the same one, and stores Apple's
+// refresh token when given. It reports false when the user is linked to another Apple ID; an Apple ID
+// already on another user fails with a duplicate key error.
+func LinkUserAppleID(id primitive.ObjectID, sub, appleRefreshToken string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	set := bson.M{"appleSub": sub}
+	if appleRefreshToken != "" {
+		set["appleRefreshToken"] = appleRefreshToken
+	}
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	res, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "appleSub": bson.M{"$in": bson.A{nil, "", sub}}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// UnlinkUserAppleID removes the Apple ID and Apple's refresh token from a user
+func UnlinkUserAppleID(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"appleSub": "", "appleRefreshToken": ""}})
+	return err
+}
+
+// DeleteUser removes a user
+func DeleteUser(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	_, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// UpdateUserFields sets fields on a user and reports whether the user exists
+func UpdateUserFields(id primitive.ObjectID, fields bson.M) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	coll := database.MongoClient.Database(defaultDatabaseName).Collection(userCollection, durableWrites())
+	res, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
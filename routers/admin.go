@@ -0,0 +1,39 @@
+package routers
+
+import (
+	"simvizlab-backend/controllers/admin"
+	"simvizlab-backend/models"
+	"simvizlab-backend/routers/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRoutes registers the support and operations endpoints. Staff authenticate with AdminAuth
+// and each route requires the permission it declares.
+func AdminRoutes(rg *gin.RouterGroup) {
+	rg.Use(middleware.AdminAuth())
+
+	rg.GET("/refunds/:originalTransactionId", middleware.RequirePermission(models.PermReadSubscriptions), admin.GetRefundHistory)
+	rg.POST("/refunds/:originalTransactionId/sync", middleware.RequirePermission(models.PermManageSubscriptions), admin.SyncRefundHistory)
+
+	rg.POST("/subscriptions/:originalTransactionId/extend", middleware.RequirePermission(models.PermManageSubscriptions), admin.ExtendSubscription)
+	rg.POST("/subscriptions/extend", middleware.RequirePermission(models.PermManageSubscriptions), admin.MassExtendSubscriptions)
+	rg.GET("/extensions", middleware.RequirePermission(models.PermReadSubscriptions), admin.ListRenewalExtensions)
+	rg.GET("/extensions/:requestIdentifier", middleware.RequirePermission(models.PermReadSubscriptions), admin.GetRenewalExtension)
+
+	rg.POST("/notifications/backfill", middleware.RequirePermission(models.PermRunJobs), admin.BackfillNotifications)
+	rg.GET("/notifications/backfill", middleware.RequirePermission(models.PermRunJobs), admin.GetBackfillState)
+	rg.POST("/notifications/test", middleware.RequirePermission(models.PermRunJobs), admin.RunNotificationHealthCheck)
+	rg.GET("/notifications/health", middleware.RequirePermission(models.PermRunJobs), admin.GetNotificationHealth)
+
+	rg.POST("/app-account-tokens/backfill", middleware.RequirePermission(models.PermRunJobs), admin.BackfillAppAccountTokens)
+
+	rg.GET("/users/:id/timeline", middleware.RequirePermission(models.PermReadUsers), admin.GetUserTimeline)
+	rg.PUT("/users/:id/role", middleware.RequirePermission(models.PermManageRoles), admin.SetUserRole)
+
+	rg.GET("/reports/active-subscribers", middleware.RequirePermission(models.PermReadReports), admin.GetActiveSubscribersReport)
+	rg.GET("/reports/daily-activity", middleware.RequirePermission(models.PermReadReports), admin.GetDailyActivityReport)
+	rg.GET("/reports/trial-conversion", middleware.RequirePermission(models.PermReadReports), admin.GetTrialConversionReport)
+	rg.GET("/reports/revenue", middleware.RequirePermission(models.PermReadReports), admin.GetRevenueReport)
+	rg.POST("/exchange-rates/refresh", middleware.RequirePermission(models.PermRunJobs), admin.RefreshExchangeRates)
+}
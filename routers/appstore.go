@@ -2,11 +2,18 @@ package routers
 
 import (
 	controller "simvizlab-backend/controllers/appstore"
+	"simvizlab-backend/models"
+	"simvizlab-backend/routers/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AppStoreRoutes(route *gin.RouterGroup) {
-	route.POST("/transaction", controller.GetTransactionInfo)
-	route.POST("/history", controller.GetHistoryInfo)
+	route.POST("/transaction", middleware.UserAuth(), controller.GetTransactionInfo)
+	route.POST("/history", middleware.UserAuth(), controller.GetHistoryInfo)
+	route.GET("/history/:originalTransactionId", middleware.UserAuth(), controller.GetTransactionHistory)
+	route.POST("/notifications", controller.ReceiveNotification)
+
+	// Support lookups expose customer accounts, so they need staff who may read users
+	route.GET("/orders/:orderId", middleware.AdminAuth(), middleware.RequirePermission(models.PermReadUsers), controller.LookupOrder)
 }
@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"simvizlab-backend/controllers/auth"
+	"simvizlab-backend/routers/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthRoutes registers registration, login and session endpoints
+func AuthRoutes(rg *gin.RouterGroup) {
+	rg.POST("/register", auth.Register)
+	rg.POST("/login", auth.Login)
+	rg.POST("/refresh", auth.Refresh)
+	rg.POST("/logout", auth.Logout)
+	rg.POST("/logout-all", middleware.UserAuth(), auth.LogoutAll)
+
+	rg.POST("/apple/nonce", auth.IssueAppleNonce)
+	rg.POST("/apple", auth.SignInWithApple)
+	rg.POST("/apple/link", middleware.UserAuth(), auth.LinkAppleID)
+	rg.DELETE("/apple", middleware.UserAuth(), auth.UnlinkAppleID)
+	rg.DELETE("/account", middleware.UserAuth(), auth.DeleteAccount)
+}
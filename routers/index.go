@@ -21,7 +21,9 @@ func RegisterRoutes(route *gin.Engine) {
 		})
 
 		// Add all other routes within the api group
+		AuthRoutes(api.Group("/auth"))
 		UserRoutes(api.Group("/user"))
 		AppStoreRoutes(api.Group("/appstore"))
+		AdminRoutes(api.Group("/admin"))
 	}
 }
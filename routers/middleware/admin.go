@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"simvizlab-backend/authctx"
+	"simvizlab-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader carries the shared secret that authenticates admin requests
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminAuth admits staff to the admin endpoints. A request either carries an X-Admin-Key matching
+// ADMIN_API_KEY, which acts with the admin role, or the access token of a user whose record still has
+// the staff role the token carries.
+// Each route then requires its own permission with RequirePermission.
+// Keys are rejected when ADMIN_API_KEY is not configured.
+func AdminAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if provided := ctx.GetHeader(AdminKeyHeader); provided != "" {
+			expected := os.Getenv("ADMIN_API_KEY")
+			if expected == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+			authctx.SetRole(ctx, models.RoleAdmin)
+			ctx.Next()
+			return
+		}
+
+		ok, err := authenticateBearer(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if !models.IsStaff(authctx.Role(ctx)) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		ctx.Next()
+	}
+}
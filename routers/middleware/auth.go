@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"simvizlab-backend/authctx"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserAuth rejects requests without a valid access token in the Authorization header
+// and records the token's user id and role on the context
+func UserAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ok, err := authenticateBearer(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// authenticateBearer verifies the access token in the Authorization header and records its user on the context.
+// Access tokens are short-lived (config.AccessTokenTTL) but the role they carry is still checked against the
+// user's record, so a deleted account or a changed role stops a token at once.
+func authenticateBearer(ctx *gin.Context) (bool, error) {
+	scheme, token, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return false, nil
+	}
+
+	claims, err := services.ParseAccessToken(strings.TrimSpace(token))
+	if err != nil {
+		return false, nil
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return false, nil
+	}
+
+	user, err := mongoRepo.GetUserByID(userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.Role != claims.Role {
+		return false, nil
+	}
+
+	authctx.SetUser(ctx, userID, claims.Role)
+	return true, nil
+}
+
+// RequirePermission rejects requests whose role does not grant permission. It runs after UserAuth or AdminAuth.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authctx.HasPermission(ctx, permission) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireSelfOrPermission lets users act on their own record, named by the param path parameter,
+// and requires permission to act on anyone else's. It runs after UserAuth.
+func RequireSelfOrPermission(param string, permission models.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if userID, ok := authctx.UserID(ctx); ok && ctx.Param(param) == userID.Hex() {
+			ctx.Next()
+			return
+		}
+		if !authctx.HasPermission(ctx, permission) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -2,17 +2,48 @@ package middleware
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// redactedFields are top-level JSON body fields never written to the log
+var redactedFields = []string{"password", "refreshToken", "identityToken", "authorizationCode"}
+
 func LogRequestBody() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bodyBytes, _ := io.ReadAll(c.Request.Body)
-		log.Println("Request body:", string(bodyBytes))
+		log.Println("Request body:", string(redactBody(bodyBytes)))
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		c.Next()
 	}
 }
+
+// redactBody masks credentials in a JSON object body, leaving other bodies as they are
+func redactBody(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	redacted := false
+	// binding matches keys case-insensitively, so redaction does too
+	for key := range fields {
+		for _, name := range redactedFields {
+			if strings.EqualFold(key, name) {
+				fields[key] = json.RawMessage(`"[REDACTED]"`)
+				redacted = true
+			}
+		}
+	}
+	if !redacted {
+		return body
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return out
+}
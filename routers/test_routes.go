@@ -2,18 +2,28 @@ package routers
 
 import (
 	"simvizlab-backend/controllers/user"
+	"simvizlab-backend/models"
+	"simvizlab-backend/routers/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-// UserRoutes registers all user routes with JWT authentication
+// UserRoutes registers all user routes with JWT authentication. End-users reach their own record;
+// other records need the permission each route declares.
 func UserRoutes(rg *gin.RouterGroup) {
+	rg.Use(middleware.UserAuth())
+
 	// Remove the additional /user group since it's already grouped in index.go
-	rg.GET("/", user.GetAllUsers)
-	// rg.GET("/:id", user.GetUserByID)
-	rg.POST("/", user.CreateUser)
-	rg.PUT("/:id", user.UpdateUser)
+	rg.GET("/", middleware.RequirePermission(models.PermReadUsers), user.GetAllUsers)
+	rg.GET("/:id", middleware.RequireSelfOrPermission("id", models.PermReadUsers), user.GetUserByID)
+	rg.POST("/", middleware.RequirePermission(models.PermWriteUsers), user.CreateUser)
+	rg.PUT("/:id", middleware.RequireSelfOrPermission("id", models.PermWriteUsers), user.UpdateUser)
+	rg.POST("/:id/app-account-token", middleware.RequireSelfOrPermission("id", models.PermWriteUsers), user.GetAppAccountToken)
+	rg.GET("/:id/entitlements", middleware.RequireSelfOrPermission("id", models.PermReadUsers), user.GetUserEntitlements)
+	rg.GET("/:id/offers", middleware.RequireSelfOrPermission("id", models.PermReadUsers), user.GetEligibleOffers)
+	rg.POST("/:id/offers/signature", middleware.RequireSelfOrPermission("id", models.PermWriteUsers), user.SignOffer)
 	// rg.DELETE("/:id", user.DeleteUser)
+	// status and login-status are scoped to the caller's own record and purchases in the handlers
 	rg.GET("/status", user.CheckUserSubscriptionStatus)
 	rg.POST("/login-status", user.LoginAndCheckStatus)
 
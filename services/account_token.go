@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrPurchaseOwnedByAnotherUser is returned when a transaction carries an appAccountToken issued to someone else
+var ErrPurchaseOwnedByAnotherUser = errors.New("purchase belongs to another account")
+
+// IssueAppAccountToken returns the appAccountToken the app passes to StoreKit when this user purchases,
+// generating it on first use
+func IssueAppAccountToken(userID primitive.ObjectID) (string, error) {
+	user, err := mongoRepo.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.AppAccountToken != "" {
+		return user.AppAccountToken, nil
+	}
+	return mongoRepo.SetUserAppAccountToken(userID, uuid.NewString())
+}
+
+// FindUserForTransaction finds the user a purchase belongs to, or nil when none is linked.
+// The appAccountToken signed by Apple is trusted first; legacy purchases without one fall back
+// to the original transaction the user registered.
+func FindUserForTransaction(transaction *models.JWSTransaction) (*models.User, error) {
+	if transaction.AppAccountToken != "" {
+		user, err := mongoRepo.GetUserByAppAccountToken(strings.ToLower(transaction.AppAccountToken))
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+	}
+
+	var user models.User
+	err := mongoRepo.GetOne("users", bson.M{"originalTransactionId": transaction.OriginalTransactionId}, &user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserOwnsPurchase reports whether a purchase belongs to user: the one linked to their record, or one
+// whose appAccountToken or original transaction FindUserForTransaction ties to them.
+// transactionId may name any transaction of the purchase.
+func UserOwnsPurchase(ctx context.Context, user *models.User, transactionId string) (bool, error) {
+	if user.OriginalTransactionId != "" && user.OriginalTransactionId == transactionId {
+		return true, nil
+	}
+
+	transaction, _, err := Gateway().GetTransaction(ctx, transactionId)
+	if err != nil {
+		return false, err
+	}
+	owner, err := FindUserForTransaction(transaction)
+	if err != nil {
+		return false, err
+	}
+	return owner != nil && owner.ID == user.ID, nil
+}
+
+// CheckPurchaseOwner rejects a transaction whose appAccountToken was not issued to user
+func CheckPurchaseOwner(user *models.User, transaction *models.JWSTransaction) error {
+	if transaction.AppAccountToken == "" {
+		return nil
+	}
+	if !strings.EqualFold(transaction.AppAccountToken, user.AppAccountToken) {
+		return ErrPurchaseOwnedByAnotherUser
+	}
+	return nil
+}
+
+// linkNotificationPurchase records the purchase of a notification on the user its appAccountToken
+// was issued to, when that user has no purchase linked yet
+func linkNotificationPurchase(notification *models.AppStoreNotification) error {
+	transaction := notification.Transaction
+	if transaction == nil || transaction.AppAccountToken == "" {
+		return nil
+	}
+
+	user, err := mongoRepo.GetUserByAppAccountToken(strings.ToLower(transaction.AppAccountToken))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Warnf("transaction %s carries unknown appAccountToken %s", transaction.TransactionID, transaction.AppAccountToken)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	linked, err := mongoRepo.LinkUserPurchase(user.ID, transaction.OriginalTransactionId)
+	if err != nil {
+		return err
+	}
+	if linked {
+		logger.Infof("linked original transaction %s to user %s by appAccountToken", transaction.OriginalTransactionId, user.ID.Hex())
+	}
+	return nil
+}
+
+// AppAccountTokenBackfillResult summarises a run of the appAccountToken backfill
+type AppAccountTokenBackfillResult struct {
+	Users    int               `json:"users"`
+	Synced   int               `json:"synced"`
+	Failed   int               `json:"failed"`
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+// BackfillAppAccountTokens issues tokens to users whose purchase predates them and sets each token on
+// the purchase at Apple, so later transactions and notifications carry it. Synced users are skipped
+// on the next run.
+func BackfillAppAccountTokens(ctx context.Context, limit int64) (*AppAccountTokenBackfillResult, error) {
+	users, err := mongoRepo.GetUsersPendingAppAccountTokenSync(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AppAccountTokenBackfillResult{Users: len(users), Failures: map[string]string{}}
+	for _, user := range users {
+		if err := syncAppAccountToken(ctx, user); err != nil {
+			logger.Warnf("failed to set appAccountToken for user %s: %v", user.ID.Hex(), err)
+			result.Failed++
+			result.Failures[user.ID.Hex()] = AppStoreErrorMessage(err)
+			continue
+		}
+		result.Synced++
+	}
+	return result, nil
+}
+
+func syncAppAccountToken(ctx context.Context, user *models.User) error {
+	token, err := IssueAppAccountToken(user.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := Gateway().SetAppAccountToken(ctx, user.OriginalTransactionId, token); err != nil {
+		return err
+	}
+	return mongoRepo.MarkAppAccountTokenSynced(user.ID, time.Now())
+}
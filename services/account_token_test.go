@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"simvizlab-backend/models"
+)
+
+func TestCheckPurchaseOwner(t *testing.T) {
+	user := &models.User{AppAccountToken: "7e3fb20b-4cdb-47cc-936d-99d65f608138"}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "legacy purchase without token"},
+		{name: "issued to the user", token: "7e3fb20b-4cdb-47cc-936d-99d65f608138"},
+		{name: "issued to the user in upper case", token: "7E3FB20B-4CDB-47CC-936D-99D65F608138"},
+		{name: "issued to someone else", token: "0b7c1f5e-2d7e-4c4a-9e0f-3a6f0d1c2b3a", wantErr: ErrPurchaseOwnedByAnotherUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPurchaseOwner(user, &models.JWSTransaction{AppAccountToken: tt.token})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckPurchaseOwner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Sign in with Apple errors
+var (
+	ErrAppleSignInMissingToken = errors.New("an identity token or authorization code is required")
+	ErrAppleSubjectMismatch    = errors.New("identity token and authorization code belong to different Apple IDs")
+	ErrAppleIDInUse            = errors.New("apple id is linked to another account")
+	ErrAppleIDLinkedElsewhere  = errors.New("account is linked to another apple id")
+	ErrAppleIDNotLinked        = errors.New("account is not linked to an apple id")
+	ErrLastSignInMethod        = errors.New("account has no password to sign in with once apple id is unlinked")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrAppleNonceRequired      = errors.New("an identity token needs a nonce issued by this server")
+	ErrAppleNonceInvalid       = errors.New("nonce is unknown, expired or already used")
+)
+
+const (
+	// appleNonceBytes is the entropy of a Sign in with Apple nonce
+	appleNonceBytes = 32
+	// appleNonceTTL is how long an issued nonce can be used to sign in
+	appleNonceTTL = 10 * time.Minute
+)
+
+// AppleSignIn is what an app sends after the Sign in with Apple sheet: the identity token, the
+// authorization code, or both, and the nonce from IssueAppleNonce it put in the authorization request
+type AppleSignIn struct {
+	IdentityToken     string `json:"identityToken"`
+	AuthorizationCode string `json:"authorizationCode"`
+	Nonce             string `json:"nonce"`
+	Username          string `json:"username"`
+}
+
+var (
+	appleIDClient     *models.AppleIDClient
+	appleIDClientOnce sync.Once
+)
+
+// AppleID returns the Sign in with Apple client configured from the environment
+func AppleID() *models.AppleIDClient {
+	appleIDClientOnce.Do(func() {
+		appleIDClient = models.NewAppleIDClient(config.AppleIDConfig())
+	})
+	return appleIDClient
+}
+
+// IssueAppleNonce returns a single-use nonce for the app to put in its Sign in with Apple request, and when it expires
+func IssueAppleNonce() (string, time.Time, error) {
+	b := make([]byte, appleNonceBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	record := &models.AppleNonce{
+		// random like refresh tokens, so it is stored hashed the same way
+		NonceHash: hashRefreshToken(nonce),
+		CreatedAt: now,
+		ExpiresAt: now.Add(appleNonceTTL),
+	}
+	if err := mongoRepo.SaveAppleNonce(record); err != nil {
+		return "", time.Time{}, err
+	}
+	return nonce, record.ExpiresAt, nil
+}
+
+// consumeAppleNonce uses up a nonce issued by IssueAppleNonce
+func consumeAppleNonce(nonce string) error {
+	err := mongoRepo.ConsumeAppleNonce(hashRefreshToken(nonce), time.Now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrAppleNonceInvalid
+	}
+	return err
+}
+
+// verifyAppleSignIn verifies a sign in and returns the Apple ID's claims. An identity token alone could be
+// replayed, so it must carry a nonce this server issued, which consumeNonce uses up. An authorization code
+// is single-use and only redeemable with our client secret; it is exchanged for the refresh token returned
+// alongside, which is needed to revoke the sign in later. A nonce sent with it is checked and used up too.
+func verifyAppleSignIn(ctx context.Context, client *models.AppleIDClient, in AppleSignIn, consumeNonce func(string) error) (*models.AppleIDClaims, string, error) {
+	if in.AuthorizationCode == "" {
+		if in.IdentityToken == "" {
+			return nil, "", ErrAppleSignInMissingToken
+		}
+		if in.Nonce == "" {
+			return nil, "", ErrAppleNonceRequired
+		}
+		claims, err := client.VerifyIdentityToken(ctx, in.IdentityToken, in.Nonce)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := consumeNonce(in.Nonce); err != nil {
+			return nil, "", err
+		}
+		return claims, "", nil
+	}
+
+	rsp, err := client.ExchangeCode(ctx, in.AuthorizationCode)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := client.VerifyIdentityToken(ctx, rsp.IDToken, in.Nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	if in.IdentityToken != "" {
+		appClaims, err := client.VerifyIdentityToken(ctx, in.IdentityToken, in.Nonce)
+		if err != nil {
+			return nil, "", err
+		}
+		if appClaims.Subject != claims.Subject {
+			return nil, "", ErrAppleSubjectMismatch
+		}
+	}
+	if in.Nonce != "" {
+		if err := consumeNonce(in.Nonce); err != nil {
+			return nil, "", err
+		}
+	}
+	return claims, rsp.RefreshToken, nil
+}
+
+// appleEmail is the verified email of an Apple ID, or empty when Apple did not share a verified one
+func appleEmail(claims *models.AppleIDClaims) string {
+	if !claims.EmailVerified {
+		return ""
+	}
+	email, err := normalizeEmail(claims.Email)
+	if err != nil {
+		return ""
+	}
+	return email
+}
+
+// SignInWithApple signs in the user of an Apple ID, creating one on first sign in. Accounts are found by
+// the Apple ID's stable sub only: an existing account with the same email has to sign in and link the
+// Apple ID itself, since nothing proves the Apple ID's owner also owns that account.
+func SignInWithApple(ctx context.Context, in AppleSignIn) (*models.User, *AuthTokens, error) {
+	claims, appleRefreshToken, err := verifyAppleSignIn(ctx, AppleID(), in, consumeAppleNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := mongoRepo.GetUserByAppleSub(claims.Subject)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		user, err = createAppleUser(claims, appleRefreshToken, in.Username)
+	} else if err == nil && appleRefreshToken != "" {
+		_, err = mongoRepo.LinkUserAppleID(user.ID, claims.Subject, appleRefreshToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, _, err := issueTokens(user, "", time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+func createAppleUser(claims *models.AppleIDClaims, appleRefreshToken, username string) (*models.User, error) {
+	email := appleEmail(claims)
+	if email != "" {
+		_, err := mongoRepo.GetUserByEmail(email)
+		if err == nil {
+			return nil, ErrEmailTaken
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:          strings.TrimSpace(username),
+		Email:             email,
+		Role:              models.RoleUser,
+		AppleSub:          claims.Subject,
+		AppleRefreshToken: appleRefreshToken,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+	}
+	err := mongoRepo.InsertUser(user)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent first sign in created the account, or the email was registered meanwhile
+		existing, getErr := mongoRepo.GetUserByAppleSub(claims.Subject)
+		if getErr == nil {
+			return existing, nil
+		}
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkAppleID adds Sign in with Apple to a signed-in user's account
+func LinkAppleID(ctx context.Context, userID primitive.ObjectID, in AppleSignIn) (*models.User, error) {
+	claims, appleRefreshToken, err := verifyAppleSignIn(ctx, AppleID(), in, consumeAppleNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := mongoRepo.LinkUserAppleID(userID, claims.Subject, appleRefreshToken)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrAppleIDInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		if _, err := mongoRepo.GetUserByID(userID); errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, ErrAppleIDLinkedElsewhere
+	}
+	return mongoRepo.GetUserByID(userID)
+}
+
+// UnlinkAppleID revokes a user's Sign in with Apple authorization and removes the Apple ID from their account
+func UnlinkAppleID(ctx context.Context, userID primitive.ObjectID) error {
+	user, err := getAuthUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.AppleSub == "" {
+		return ErrAppleIDNotLinked
+	}
+	if user.Password == "" {
+		return ErrLastSignInMethod
+	}
+	if err := revokeAppleAuthorization(ctx, AppleID(), user); err != nil {
+		return err
+	}
+	return mongoRepo.UnlinkUserAppleID(userID)
+}
+
+// DeleteAccount deletes a user, first revoking their Sign in with Apple authorization as Apple requires
+// and ending all their sessions
+func DeleteAccount(ctx context.Context, userID primitive.ObjectID) error {
+	user, err := getAuthUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := revokeAppleAuthorization(ctx, AppleID(), user); err != nil {
+		return err
+	}
+	if err := mongoRepo.RevokeUserRefreshTokens(userID, time.Now()); err != nil {
+		return err
+	}
+	return mongoRepo.DeleteUser(userID)
+}
+
+func getAuthUser(userID primitive.ObjectID) (*models.User, error) {
+	user, err := mongoRepo.GetUserByID(userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// revokeAppleAuthorization revokes the Apple refresh token stored for a user. A token Apple no longer
+// accepts is already revoked, for example when the user removed the app from their Apple ID settings.
+func revokeAppleAuthorization(ctx context.Context, client *models.AppleIDClient, user *models.User) error {
+	if user.AppleRefreshToken == "" {
+		return nil
+	}
+	err := client.RevokeToken(ctx, user.AppleRefreshToken, "refresh_token")
+	var appleErr *models.AppleIDError
+	if errors.As(err, &appleErr) && appleErr.Code == "invalid_grant" {
+		logger.Infof("apple refresh token of user %s was already revoked", user.ID.Hex())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke sign in with apple: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyAppleSignIn(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	jwks, _ := json.Marshal(models.JWKSet{Keys: []models.JWK{{
+		Kty: "RSA",
+		Kid: "apple-1",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	keySet, err := models.NewStaticKeySet(jwks)
+	if err != nil {
+		t.Fatalf("NewStaticKeySet() error = %v", err)
+	}
+	identityToken := func(sub, nonce string) string {
+		claims := jwt.MapClaims{
+			"iss": models.HostAppleID,
+			"aud": "com.example.app",
+			"sub": sub,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "apple-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return signed
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(clientKey)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the authorization request of "nonce-code" carried nonce-1
+		nonce := ""
+		if r.FormValue("code") == "nonce-code" {
+			nonce = "nonce-1"
+		}
+		json.NewEncoder(w).Encode(models.AppleIDTokenResponse{RefreshToken: "apple-refresh", IDToken: identityToken("sub-1", nonce)})
+	}))
+	defer srv.Close()
+	client := models.NewAppleIDClientWithHTTPClient(&models.AppleIDConfig{
+		ClientID:   "com.example.app",
+		KeyContent: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		KeySource:  keySet,
+	}, srv.Client(), srv.URL)
+
+	// the nonces the server issued, each usable once
+	var issued map[string]bool
+	consumeNonce := func(nonce string) error {
+		if !issued[nonce] {
+			return ErrAppleNonceInvalid
+		}
+		delete(issued, nonce)
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		in          AppleSignIn
+		wantSub     string
+		wantRefresh string
+		wantErr     error
+	}{
+		{name: "nothing", wantErr: ErrAppleSignInMissingToken},
+		{name: "identity token", in: AppleSignIn{IdentityToken: identityToken("sub-1", "nonce-1"), Nonce: "nonce-1"}, wantSub: "sub-1"},
+		{name: "identity token without a nonce", in: AppleSignIn{IdentityToken: identityToken("sub-1", "")}, wantErr: ErrAppleNonceRequired},
+		{name: "identity token with a nonce not issued", in: AppleSignIn{IdentityToken: identityToken("sub-1", "forged"), Nonce: "forged"}, wantErr: ErrAppleNonceInvalid},
+		{name: "identity token with another nonce", in: AppleSignIn{IdentityToken: identityToken("sub-1", "nonce-1"), Nonce: "nonce-2"}, wantErr: models.ErrAppleIDNonce},
+		{name: "authorization code", in: AppleSignIn{AuthorizationCode: "code"}, wantSub: "sub-1", wantRefresh: "apple-refresh"},
+		{name: "authorization code with its nonce", in: AppleSignIn{AuthorizationCode: "nonce-code", Nonce: "nonce-1"}, wantSub: "sub-1", wantRefresh: "apple-refresh"},
+		{name: "authorization code without its nonce", in: AppleSignIn{AuthorizationCode: "nonce-code"}, wantErr: models.ErrAppleIDNonce},
+		{name: "both", in: AppleSignIn{AuthorizationCode: "nonce-code", IdentityToken: identityToken("sub-1", "nonce-1"), Nonce: "nonce-1"}, wantSub: "sub-1", wantRefresh: "apple-refresh"},
+		{name: "both for different apple ids", in: AppleSignIn{AuthorizationCode: "nonce-code", IdentityToken: identityToken("sub-2", "nonce-1"), Nonce: "nonce-1"}, wantErr: ErrAppleSubjectMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issued = map[string]bool{"nonce-1": true, "nonce-2": true}
+			claims, refresh, err := verifyAppleSignIn(context.Background(), client, tt.in, consumeNonce)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyAppleSignIn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if claims.Subject != tt.wantSub || refresh != tt.wantRefresh {
+				t.Errorf("verifyAppleSignIn() = %s, %q, want %s, %q", claims.Subject, refresh, tt.wantSub, tt.wantRefresh)
+			}
+		})
+	}
+
+	// a nonce signs in once, so a captured identity token cannot be replayed
+	issued = map[string]bool{"nonce-1": true}
+	in := AppleSignIn{IdentityToken: identityToken("sub-1", "nonce-1"), Nonce: "nonce-1"}
+	if _, _, err := verifyAppleSignIn(context.Background(), client, in, consumeNonce); err != nil {
+		t.Fatalf("verifyAppleSignIn() error = %v", err)
+	}
+	if _, _, err := verifyAppleSignIn(context.Background(), client, in, consumeNonce); !errors.Is(err, ErrAppleNonceInvalid) {
+		t.Errorf("verifyAppleSignIn() of a replayed token error = %v, want %v", err, ErrAppleNonceInvalid)
+	}
+}
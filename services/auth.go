@@ -0,0 +1,373 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authentication errors
+var (
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrInvalidPassword    = errors.New("password must be 8 to 72 bytes long")
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+)
+
+const (
+	// minPasswordLength is the fewest characters a password may have
+	minPasswordLength = 8
+	// maxPasswordBytes is the longest password bcrypt hashes without truncating
+	maxPasswordBytes = 72
+	// passwordHashCost is the bcrypt work factor
+	passwordHashCost = 12
+
+	// accessTokenIssuer and accessTokenAudience scope access tokens to this API
+	accessTokenIssuer   = "simvizlab-backend"
+	accessTokenAudience = "simvizlab-api"
+	// refreshTokenBytes is the entropy of a refresh token
+	refreshTokenBytes = 32
+)
+
+// AccessClaims are the claims of an access token. The subject is the user id.
+type AccessClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// AuthTokens is the result of a login or refresh: a short-lived access token for the Authorization
+// header and the refresh token that exchanges for the next pair
+type AuthTokens struct {
+	AccessToken           string    `json:"accessToken"`
+	TokenType             string    `json:"tokenType"`
+	ExpiresIn             int64     `json:"expiresIn"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+}
+
+// normalizeEmail trims and lowercases a bare email address, rejecting anything else such as a display name
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// validatePassword bounds a password between 8 characters and the 72 bytes bcrypt reads
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength || len(password) > maxPasswordBytes {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func hashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// checkPassword compares a password to a stored hash. A user without a hash is compared against
+// a throwaway one, so unknown emails take as long to reject as wrong passwords.
+func checkPassword(hash, password string) bool {
+	if hash == "" {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = hashPassword(uuid.NewString(), passwordHashCost)
+		})
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// issueAccessToken signs an access token for user valid from now for ttl
+func issueAccessToken(user *models.User, secret []byte, now time.Time, ttl time.Duration) (string, error) {
+	claims := AccessClaims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    accessTokenIssuer,
+			Subject:   user.ID.Hex(),
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        uuid.NewString(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// parseAccessToken verifies an access token's signature, issuer, audience and expiry as of now
+func parseAccessToken(token string, secret []byte, now time.Time) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(accessTokenIssuer),
+		jwt.WithAudience(accessTokenAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if _, err := primitive.ObjectIDFromHex(claims.Subject); err != nil {
+		return nil, fmt.Errorf("%w: invalid subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// ParseAccessToken verifies an access token from the Authorization header and returns its claims
+func ParseAccessToken(token string) (*AccessClaims, error) {
+	secret, err := config.AuthTokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	return parseAccessToken(token, secret, time.Now())
+}
+
+// newRefreshToken returns a random refresh token and the hash it is stored under
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+// hashRefreshToken hashes a refresh token for storage. Tokens are random, so an unsalted hash suffices.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens signs an access token for user and stores a refresh token in familyID, a new family when empty
+func issueTokens(user *models.User, familyID string, now time.Time) (*AuthTokens, string, error) {
+	secret, err := config.AuthTokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	accessTTL, refreshTTL := config.AccessTokenTTL(), config.RefreshTokenTTL()
+	accessToken, err := issueAccessToken(user, secret, now, accessTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTTL),
+	}
+	if err := mongoRepo.SaveRefreshToken(stored); err != nil {
+		return nil, "", err
+	}
+
+	return &AuthTokens{
+		AccessToken:           accessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(accessTTL / time.Second),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: stored.ExpiresAt,
+	}, tokenHash, nil
+}
+
+// Register creates a user with a hashed password and signs them in
+func Register(email, password, username string) (*models.User, *AuthTokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, nil, err
+	}
+
+	_, err = mongoRepo.GetUserByEmail(email)
+	if err == nil {
+		return nil, nil, ErrEmailTaken
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, err
+	}
+
+	hash, err := hashPassword(password, passwordHashCost)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	user := &models.User{
+		Username:  strings.TrimSpace(username),
+		Email:     email,
+		Password:  hash,
+		Role:      models.RoleUser,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if err := mongoRepo.InsertUser(user); err != nil {
+		// another registration for the same email won the race
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, nil, ErrEmailTaken
+		}
+		return nil, nil, err
+	}
+
+	tokens, _, err := issueTokens(user, "", now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Login checks an email and password and starts a new session
+func Login(email, password string) (*models.User, *AuthTokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		checkPassword("", password)
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	user, err := mongoRepo.GetUserByEmail(email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, err
+	}
+	hash := ""
+	if user != nil {
+		hash = user.Password
+	}
+	if !checkPassword(hash, password) {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	tokens, _, err := issueTokens(user, "", time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// RefreshSession exchanges a refresh token for a new access and refresh token. The presented token is
+// used up; presenting a used token again revokes its whole family, signing out whoever holds a copy.
+func RefreshSession(refreshToken string) (*AuthTokens, error) {
+	now := time.Now()
+	tokenHash := hashRefreshToken(refreshToken)
+
+	stored, err := mongoRepo.GetRefreshToken(tokenHash)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		if stored.ReplacedBy != "" {
+			logger.Warnf("refresh token reused for user %s, revoking family %s", stored.UserID.Hex(), stored.FamilyID)
+			if err := mongoRepo.RevokeRefreshTokenFamily(stored.FamilyID, now); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidToken
+	}
+	if !stored.ExpiresAt.After(now) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := mongoRepo.GetUserByID(stored.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the replacement is issued first so the used token records what replaced it
+	tokens, replacementHash, err := issueTokens(user, stored.FamilyID, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mongoRepo.ConsumeRefreshToken(tokenHash, replacementHash, now); err != nil {
+		// a concurrent refresh used the token first; drop the replacement issued here
+		if revokeErr := mongoRepo.RevokeRefreshTokenFamily(stored.FamilyID, now); revokeErr != nil {
+			return nil, revokeErr
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout ends the session a refresh token belongs to. Access tokens already issued stay valid until they expire.
+func Logout(refreshToken string) error {
+	stored, err := mongoRepo.GetRefreshToken(hashRefreshToken(refreshToken))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	return mongoRepo.RevokeRefreshTokenFamily(stored.FamilyID, time.Now())
+}
+
+// LogoutAll ends every session of a user
+func LogoutAll(userID primitive.ObjectID) error {
+	return mongoRepo.RevokeUserRefreshTokens(userID, time.Now())
+}
+
+// ErrInvalidRole is returned for a role that is not one of the known roles
+var ErrInvalidRole = errors.New("invalid role")
+
+// SetUserRole changes a user's role and ends their sessions, so tokens carrying the old role are not refreshed.
+// Access tokens already issued keep the old role until they expire.
+func SetUserRole(userID primitive.ObjectID, role string) error {
+	if !models.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	found, err := mongoRepo.UpdateUserFields(userID, bson.M{"role": role, "updated_at": time.Now()})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrUserNotFound
+	}
+	return mongoRepo.RevokeUserRefreshTokens(userID, time.Now())
+}
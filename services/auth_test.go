@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		want    string
+		wantErr error
+	}{
+		{email: "user@example.com", want: "user@example.com"},
+		{email: "  User@Example.COM ", want: "user@example.com"},
+		{email: "", wantErr: ErrInvalidEmail},
+		{email: "not-an-email", wantErr: ErrInvalidEmail},
+		{email: "Someone <user@example.com>", wantErr: ErrInvalidEmail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			got, err := normalizeEmail(tt.email)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("normalizeEmail(%q) = %q, %v, want %q, %v", tt.email, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "long enough", password: "correct horse"},
+		{name: "too short", password: "short", wantErr: ErrInvalidPassword},
+		{name: "eight multibyte characters", password: "éééééééé"},
+		{name: "longer than bcrypt reads", password: strings.Repeat("a", 73), wantErr: ErrInvalidPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePassword(tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validatePassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse", bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashPassword() error = %v", err)
+	}
+	if hash == "correct horse" {
+		t.Fatal("hashPassword() returned the password")
+	}
+
+	if !checkPassword(hash, "correct horse") {
+		t.Error("checkPassword() rejected the right password")
+	}
+	if checkPassword(hash, "battery staple") {
+		t.Error("checkPassword() accepted a wrong password")
+	}
+	if checkPassword("", "") {
+		t.Error("checkPassword() accepted a user without a password")
+	}
+}
+
+func TestAccessToken(t *testing.T) {
+	secret := []byte(strings.Repeat("s", 32))
+	user := &models.User{ID: primitive.NewObjectID(), Role: models.RoleUser}
+	issued := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	token, err := issueAccessToken(user, secret, issued, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("issueAccessToken() error = %v", err)
+	}
+
+	claims, err := parseAccessToken(token, secret, issued.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("parseAccessToken() error = %v", err)
+	}
+	if claims.Subject != user.ID.Hex() || claims.Role != models.RoleUser {
+		t.Errorf("parseAccessToken() = subject %s role %s, want %s %s", claims.Subject, claims.Role, user.ID.Hex(), models.RoleUser)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("signing with none error = %v", err)
+	}
+	otherAudience := *claims
+	otherAudience.Audience = jwt.ClaimStrings{"another-api"}
+	foreign, err := jwt.NewWithClaims(jwt.SigningMethodHS256, otherAudience).SignedString(secret)
+	if err != nil {
+		t.Fatalf("signing for another audience error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		secret []byte
+		at     time.Time
+	}{
+		{name: "expired", token: token, secret: secret, at: issued.Add(16 * time.Minute)},
+		{name: "other secret", token: token, secret: []byte(strings.Repeat("x", 32)), at: issued},
+		{name: "tampered", token: token[:len(token)-2] + "xx", secret: secret, at: issued},
+		{name: "unsigned", token: unsigned, secret: secret, at: issued},
+		{name: "other audience", token: foreign, secret: secret, at: issued},
+		{name: "garbage", token: "not.a.token", secret: secret, at: issued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAccessToken(tt.token, tt.secret, tt.at); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("parseAccessToken() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		t.Fatalf("newRefreshToken() error = %v", err)
+	}
+	if hash != hashRefreshToken(token) || hash == token {
+		t.Errorf("newRefreshToken() hash = %s, want the SHA-256 of the token", hash)
+	}
+	other, _, err := newRefreshToken()
+	if err != nil {
+		t.Fatalf("newRefreshToken() error = %v", err)
+	}
+	if other == token {
+		t.Error("newRefreshToken() returned the same token twice")
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// ErrBackfillRunning is returned when another replica holds the backfill job
+var ErrBackfillRunning = errors.New("notification backfill is already running")
+
+// NotificationBackfillJob names the backfill job in the job state collection
+const NotificationBackfillJob = "notificationBackfill"
+
+const (
+	// notificationBackfillLease bounds how long a replica holds the job before another may take over
+	notificationBackfillLease = 30 * time.Minute
+	// notificationBackfillOverlap re-fetches part of the last window to catch notifications signed late
+	notificationBackfillOverlap = time.Hour
+	// maxNotificationHistoryDays is how far back Apple keeps notification history
+	maxNotificationHistoryDays = 180
+)
+
+// BackfillOptions controls a backfill run. Days of zero resumes from the high-water mark, falling back
+// to the configured number of days; a positive Days rescans that many days regardless.
+type BackfillOptions struct {
+	Days         int  `json:"days"`
+	OnlyFailures bool `json:"onlyFailures"`
+}
+
+// BackfillResult summarises a backfill run
+type BackfillResult struct {
+	StartDate int64 `json:"startDate"`
+	EndDate   int64 `json:"endDate"`
+	Fetched   int   `json:"fetched"`
+	Ingested  int   `json:"ingested"`
+	Processed int   `json:"processed"`
+	Invalid   int   `json:"invalid"`
+	Failed    int   `json:"failed"`
+	Retried   int   `json:"retried"`
+}
+
+// BackfillNotifications fetches the notification history from Apple and feeds every notification we have
+// not recorded through the webhook ingestion pipeline, then retries notifications stuck unapplied.
+// The end of each fully ingested window is kept as a high-water mark so the next run resumes from it.
+func BackfillNotifications(ctx context.Context, opts BackfillOptions) (*BackfillResult, error) {
+	state, claimed, err := mongoRepo.ClaimJob(NotificationBackfillJob, instanceID, notificationBackfillLease)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrBackfillRunning
+	}
+
+	now := time.Now()
+	result := &BackfillResult{EndDate: now.UnixMilli()}
+	result.StartDate = backfillStartDate(now, opts.Days, state.HighWaterMark)
+
+	runErr := backfillHistory(ctx, result, opts.OnlyFailures)
+	retryUnappliedNotifications(now, result)
+
+	highWaterMark := state.HighWaterMark
+	if runErr == nil {
+		highWaterMark = result.EndDate
+	}
+	if err := mongoRepo.ReleaseJob(NotificationBackfillJob, instanceID, highWaterMark, runErr); err != nil {
+		return result, err
+	}
+	return result, runErr
+}
+
+// backfillStartDate picks where a run starts: the explicit window, or the high-water mark less an
+// overlap but no earlier than the configured window. Apple keeps 180 days of history.
+func backfillStartDate(now time.Time, days int, highWaterMark int64) int64 {
+	explicit := days > 0
+	if !explicit {
+		days = config.NotificationBackfillDays()
+	}
+	if days > maxNotificationHistoryDays {
+		days = maxNotificationHistoryDays
+	}
+
+	start := now.AddDate(0, 0, -days).UnixMilli()
+	if !explicit && highWaterMark > 0 {
+		if resume := highWaterMark - notificationBackfillOverlap.Milliseconds(); resume > start {
+			start = resume
+		}
+	}
+	return start
+}
+
+func backfillHistory(ctx context.Context, result *BackfillResult, onlyFailures bool) error {
+	items, err := Gateway().GetNotificationHistory(ctx, models.NotificationHistoryRequest{
+		StartDate:    result.StartDate,
+		EndDate:      result.EndDate,
+		OnlyFailures: onlyFailures,
+	})
+	if err != nil {
+		return err
+	}
+	result.Fetched = len(items)
+
+	var runErr error
+	for _, item := range items {
+		notification, created, err := IngestNotification(item.SignedPayload)
+		if errors.Is(err, ErrInvalidNotification) {
+			// It will never verify, so it must not hold back the high-water mark
+			logger.Warnf("skipping unverifiable notification from history: %v", err)
+			result.Invalid++
+			continue
+		}
+		if err != nil {
+			logger.Errorf("failed to store notification from history: %v", err)
+			runErr = err
+			continue
+		}
+		if created {
+			logger.Infof("recovered notification %s (%s) from history", notification.NotificationUUID, notification.NotificationType)
+			result.Ingested++
+		}
+
+		processed, err := ProcessNotification(notification)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if processed {
+			result.Processed++
+		}
+	}
+	return runErr
+}
+
+// retryUnappliedNotifications re-runs stored notifications that were never applied or whose
+// processing failed, leaving alone those claimed recently enough to still be in flight and
+// those that already failed config.NotificationMaxAttempts times
+func retryUnappliedNotifications(now time.Time, result *BackfillResult) {
+	uuids, err := mongoRepo.GetUnappliedNotificationUUIDs(now.Add(-notificationLease), config.NotificationMaxAttempts())
+	if err != nil {
+		logger.Errorf("failed to list unapplied notifications: %v", err)
+		return
+	}
+
+	for _, notificationUUID := range uuids {
+		notification, err := mongoRepo.GetNotification(notificationUUID)
+		if err != nil {
+			logger.Errorf("failed to load unapplied notification %s: %v", notificationUUID, err)
+			continue
+		}
+		processed, err := ProcessNotification(notification)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if processed {
+			result.Retried++
+		}
+	}
+}
+
+// StartNotificationBackfill runs the backfill job now and then at the configured interval until ctx is cancelled
+func StartNotificationBackfill(ctx context.Context) {
+	interval := config.NotificationBackfillInterval()
+	opts := BackfillOptions{OnlyFailures: config.NotificationBackfillOnlyFailures()}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runNotificationBackfill(ctx, opts)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func runNotificationBackfill(ctx context.Context, opts BackfillOptions) {
+	result, err := BackfillNotifications(ctx, opts)
+	if errors.Is(err, ErrBackfillRunning) {
+		return
+	}
+	if err != nil {
+		logger.Errorf("notification backfill failed: %v", err)
+	}
+	if result != nil {
+		logger.Infof("notification backfill: fetched %d, recovered %d, applied %d, retried %d, invalid %d, failed %d",
+			result.Fetched, result.Ingested, result.Processed, result.Retried, result.Invalid, result.Failed)
+	}
+}
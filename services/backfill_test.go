@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfillStartDate(t *testing.T) {
+	t.Setenv("APPSTORE_BACKFILL_DAYS", "7")
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	week := now.AddDate(0, 0, -7).UnixMilli()
+
+	tests := []struct {
+		name          string
+		days          int
+		highWaterMark int64
+		want          int64
+	}{
+		{name: "first run", want: week},
+		{name: "resume from high-water mark", highWaterMark: now.Add(-2 * time.Hour).UnixMilli(), want: now.Add(-3 * time.Hour).UnixMilli()},
+		{name: "stale high-water mark", highWaterMark: now.AddDate(0, 0, -30).UnixMilli(), want: week},
+		{name: "explicit window ignores high-water mark", days: 3, highWaterMark: now.Add(-time.Hour).UnixMilli(), want: now.AddDate(0, 0, -3).UnixMilli()},
+		{name: "window capped to history retention", days: 365, want: now.AddDate(0, 0, -maxNotificationHistoryDays).UnixMilli()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backfillStartDate(now, tt.days, tt.highWaterMark); got != tt.want {
+				t.Errorf("backfillStartDate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// consumptionResponseWindow is how long Apple waits for consumption information after a CONSUMPTION_REQUEST
+const consumptionResponseWindow = 12 * time.Hour
+
+// Consumption request values we send as constants
+// https://developer.apple.com/documentation/appstoreserverapi/consumptionrequest
+const (
+	consumptionDelivered     int32 = 0
+	consumptionNotDelivered  int32 = 5
+	consumptionPlatformApple int32 = 1
+	consumptionUserActive    int32 = 1
+)
+
+// respondToConsumptionRequest sends Apple the consumption information of the transaction a customer
+// asked to refund and records what was sent. Customers who did not consent are skipped.
+// An error leaves the notification failed so it is retried while the response window is open.
+func respondToConsumptionRequest(notification *models.AppStoreNotification) error {
+	transaction := notification.Transaction
+	if transaction == nil {
+		return nil
+	}
+
+	deadline := time.UnixMilli(notification.SignedDate).Add(consumptionResponseWindow)
+	audit := &models.ConsumptionAudit{
+		NotificationUUID:      notification.NotificationUUID,
+		OriginalTransactionId: transaction.OriginalTransactionId,
+		TransactionId:         transaction.TransactionID,
+		Environment:           notification.Environment,
+		Deadline:              deadline,
+	}
+
+	if time.Now().After(deadline) {
+		logger.Warnf("consumption request %s for transaction %s expired before it was answered", notification.NotificationUUID, transaction.TransactionID)
+		audit.Status = models.ConsumptionExpired
+		return mongoRepo.SaveConsumptionAudit(audit, 0)
+	}
+
+	user, err := FindUserForTransaction(transaction)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		audit.Status = models.ConsumptionSkippedNoUser
+		return mongoRepo.SaveConsumptionAudit(audit, 0)
+	}
+	audit.UserID = user.ID.Hex()
+	if !user.ConsumptionDataConsent {
+		audit.Status = models.ConsumptionSkippedNoConsent
+		return mongoRepo.SaveConsumptionAudit(audit, 0)
+	}
+
+	request, err := buildConsumptionRequest(user, transaction, time.Now())
+	if err != nil {
+		return err
+	}
+	audit.Request = request
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	attempts, status, sendErr := sendConsumptionInfo(ctx, Gateway().ClientFor(notification.Environment), transaction.TransactionID, *request)
+	audit.ResponseStatus = status
+	if sendErr != nil {
+		audit.Status = models.ConsumptionFailed
+		audit.LastError = sendErr.Error()
+	} else {
+		sentAt := time.Now()
+		audit.Status = models.ConsumptionSent
+		audit.SentAt = &sentAt
+	}
+	if err := mongoRepo.SaveConsumptionAudit(audit, attempts); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// sendConsumptionInfo submits consumption information, retrying throttling, server and network
+// errors with jittered backoff until the backoff gives up or ctx ends
+func sendConsumptionInfo(ctx context.Context, client *models.StoreClient, transactionId string, body models.ConsumptionRequestBody) (attempts int, status int, err error) {
+	backoff := &models.JitterBackoff{Initial: time.Second, Max: time.Minute}
+	for {
+		attempts++
+		status, err = client.SendConsumptionInfo(ctx, transactionId, body)
+		if err == nil && status != http.StatusOK && status != http.StatusAccepted {
+			err = fmt.Errorf("unexpected status %d", status)
+		}
+		if err == nil {
+			return attempts, status, nil
+		}
+		if status != 0 && !models.ShouldRetryDefault(status, nil) {
+			return attempts, status, err
+		}
+
+		pause := backoff.Pause()
+		if pause < 0 {
+			return attempts, status, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, status, err
+		case <-time.After(pause):
+		}
+	}
+}
+
+// buildConsumptionRequest assembles the consumption information of a user from our own records
+func buildConsumptionRequest(user *models.User, transaction *models.JWSTransaction, now time.Time) (*models.ConsumptionRequestBody, error) {
+	transactions, err := mongoRepo.GetCustomerTransactions(
+		[]string{user.OriginalTransactionId, transaction.OriginalTransactionId},
+		transaction.AppAccountToken,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := consumptionNotDelivered
+	var refunded []*models.JWSTransaction
+	for _, stored := range transactions {
+		if stored.TransactionID == transaction.TransactionID {
+			delivery = consumptionDelivered
+		}
+		revoked, err := IsTransactionRevoked(stored)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			refunded = append(refunded, stored)
+		}
+	}
+	rates := CurrentExchangeRates()
+
+	var tenure time.Duration
+	if user.CreatedAt != nil {
+		tenure = now.Sub(*user.CreatedAt)
+	}
+
+	return &models.ConsumptionRequestBody{
+		AccountTenure:            accountTenureBucket(user.CreatedAt != nil, tenure),
+		AppAccountToken:          transaction.AppAccountToken,
+		CustomerConsented:        true,
+		DeliveryStatus:           delivery,
+		LifetimeDollarsPurchased: lifetimeDollarsValue(transactions, rates),
+		LifetimeDollarsRefunded:  lifetimeDollarsValue(refunded, rates),
+		Platform:                 consumptionPlatformApple,
+		PlayTime:                 playTimeBucket(user.PlayTimeMinutes),
+		UserStatus:               consumptionUserActive,
+	}, nil
+}
+
+// accountTenureBucket maps the age of an account to Apple's accountTenure value
+// https://developer.apple.com/documentation/appstoreserverapi/accounttenure
+func accountTenureBucket(known bool, tenure time.Duration) int32 {
+	if !known {
+		return 0
+	}
+	day := 24 * time.Hour
+	switch {
+	case tenure < 3*day:
+		return 1
+	case tenure < 10*day:
+		return 2
+	case tenure < 30*day:
+		return 3
+	case tenure < 90*day:
+		return 4
+	case tenure < 180*day:
+		return 5
+	case tenure < 365*day:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// lifetimeDollarsValue sums the prices of transactions in US dollars at the rate of each purchase date
+// and maps the total to Apple's bucket. Without a rate for every priced transaction the amount is
+// undeclared (0) rather than guessed.
+func lifetimeDollarsValue(transactions []*models.JWSTransaction, rates *ExchangeRates) int32 {
+	total := models.NewMoney(0, "USD")
+	for _, transaction := range transactions {
+		if transaction.Price == 0 {
+			continue
+		}
+		usd, err := rates.Convert(models.TransactionPrice(transaction), "USD", time.UnixMilli(transaction.PurchaseDate).UTC())
+		if err != nil {
+			return 0
+		}
+		if total, err = total.Add(usd); err != nil {
+			return 0
+		}
+	}
+	return lifetimeDollarsBucket(total.Milliunits)
+}
+
+// lifetimeDollarsBucket maps an amount in US dollar milliunits to Apple's lifetimeDollarsPurchased and
+// lifetimeDollarsRefunded values
+// https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarspurchased
+func lifetimeDollarsBucket(milliunits int64) int32 {
+	cents := milliunits / 10
+	switch {
+	case cents <= 0:
+		return 1
+	case cents < 50_00:
+		return 2
+	case cents < 100_00:
+		return 3
+	case cents < 500_00:
+		return 4
+	case cents < 1000_00:
+		return 5
+	case cents < 2000_00:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// playTimeBucket maps minutes of use to Apple's playTime value
+// https://developer.apple.com/documentation/appstoreserverapi/playtime
+func playTimeBucket(minutes int64) int32 {
+	const hour, day = 60, 24 * 60
+	switch {
+	case minutes <= 0:
+		return 0
+	case minutes < 5:
+		return 1
+	case minutes < hour:
+		return 2
+	case minutes < 6*hour:
+		return 3
+	case minutes < day:
+		return 4
+	case minutes < 4*day:
+		return 5
+	case minutes < 16*day:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestConsumptionBuckets(t *testing.T) {
+	day := 24 * time.Hour
+	tenures := []struct {
+		known  bool
+		tenure time.Duration
+		want   int32
+	}{
+		{known: false, want: 0},
+		{known: true, tenure: time.Hour, want: 1},
+		{known: true, tenure: 5 * day, want: 2},
+		{known: true, tenure: 60 * day, want: 4},
+		{known: true, tenure: 400 * day, want: 7},
+	}
+	for _, tt := range tenures {
+		if got := accountTenureBucket(tt.known, tt.tenure); got != tt.want {
+			t.Errorf("accountTenureBucket(%v, %v) = %d, want %d", tt.known, tt.tenure, got, tt.want)
+		}
+	}
+
+	dollars := []struct {
+		milliunits int64
+		want       int32
+	}{
+		{milliunits: 0, want: 1},
+		{milliunits: 990, want: 2},
+		{milliunits: 49990, want: 2},
+		{milliunits: 50000, want: 3},
+		{milliunits: 2_500_000, want: 7},
+	}
+	for _, tt := range dollars {
+		if got := lifetimeDollarsBucket(tt.milliunits); got != tt.want {
+			t.Errorf("lifetimeDollarsBucket(%d) = %d, want %d", tt.milliunits, got, tt.want)
+		}
+	}
+
+	playTimes := []struct {
+		minutes int64
+		want    int32
+	}{
+		{minutes: 0, want: 0},
+		{minutes: 3, want: 1},
+		{minutes: 30, want: 2},
+		{minutes: 120, want: 3},
+		{minutes: 30 * 24 * 60, want: 7},
+	}
+	for _, tt := range playTimes {
+		if got := playTimeBucket(tt.minutes); got != tt.want {
+			t.Errorf("playTimeBucket(%d) = %d, want %d", tt.minutes, got, tt.want)
+		}
+	}
+}
+
+func TestLifetimeDollarsValue(t *testing.T) {
+	rates := NewExchangeRates([]*models.ExchangeRate{
+		{Date: "2024-03-01", Base: "USD", Currency: "JPY", Rate: 150},
+		{Date: "2024-03-01", Base: "USD", Currency: "EUR", Rate: 0.9},
+	})
+	purchased := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC).UnixMilli()
+	tx := func(price int64, currency string) *models.JWSTransaction {
+		return &models.JWSTransaction{Price: price, Currency: currency, PurchaseDate: purchased}
+	}
+
+	tests := []struct {
+		name         string
+		transactions []*models.JWSTransaction
+		want         int32
+	}{
+		{name: "none", want: 1},
+		{name: "dollars", transactions: []*models.JWSTransaction{tx(49_990, "USD")}, want: 2},
+		// ¥500000 is $3333, not $500
+		{name: "yen", transactions: []*models.JWSTransaction{tx(500_000_000, "JPY")}, want: 7},
+		{name: "yen below the first bucket", transactions: []*models.JWSTransaction{tx(500_000, "JPY")}, want: 2},
+		{name: "mixed currencies", transactions: []*models.JWSTransaction{tx(45_000, "EUR"), tx(7_500_000, "JPY")}, want: 4},
+		{name: "free trial without currency", transactions: []*models.JWSTransaction{tx(0, ""), tx(9_990, "USD")}, want: 2},
+		{name: "no rate", transactions: []*models.JWSTransaction{tx(9_990, "USD"), tx(9_990, "GBP")}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lifetimeDollarsValue(tt.transactions, rates); got != tt.want {
+				t.Errorf("lifetimeDollarsValue() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
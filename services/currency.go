@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// ErrExchangeRateMissing is returned when no recent enough rate converts between two currencies
+var ErrExchangeRateMissing = errors.New("exchange rate missing")
+
+// maxExchangeRateAge is how long a rate stands in for days without one, such as weekends and holidays
+const maxExchangeRateAge = 7 * 24 * time.Hour
+
+type currencyPair struct {
+	base     string
+	currency string
+}
+
+type datedRate struct {
+	day  time.Time
+	rate float64
+}
+
+// ExchangeRates converts amounts between currencies at the rates of a given day
+type ExchangeRates struct {
+	pairs map[currencyPair][]datedRate
+	bases map[string]bool
+}
+
+// NewExchangeRates indexes rates by currency pair. Rows with a malformed date or a non-positive rate are skipped.
+func NewExchangeRates(rates []*models.ExchangeRate) *ExchangeRates {
+	x := &ExchangeRates{pairs: map[currencyPair][]datedRate{}, bases: map[string]bool{}}
+	for _, rate := range rates {
+		day, err := time.Parse(reportDayLayout, rate.Date)
+		if err != nil || rate.Rate <= 0 {
+			continue
+		}
+		pair := currencyPair{base: rate.Base, currency: rate.Currency}
+		x.pairs[pair] = append(x.pairs[pair], datedRate{day: day, rate: rate.Rate})
+		x.bases[rate.Base] = true
+	}
+	for _, rates := range x.pairs {
+		sort.Slice(rates, func(i, j int) bool { return rates[i].day.Before(rates[j].day) })
+	}
+	return x
+}
+
+// rateOn returns how many units of currency one unit of base was worth on day, using the latest earlier
+// rate no older than maxExchangeRateAge when day has none
+func (x *ExchangeRates) rateOn(base, currency string, day time.Time) (float64, bool) {
+	if base == currency {
+		return 1, true
+	}
+	rates := x.pairs[currencyPair{base: base, currency: currency}]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].day.After(day) })
+	if i == 0 || day.Sub(rates[i-1].day) > maxExchangeRateAge {
+		return 0, false
+	}
+	return rates[i-1].rate, true
+}
+
+// Rate returns how many units of to one unit of from was worth on day. Without a direct or inverse
+// rate, it crosses through a base both currencies are quoted against.
+func (x *ExchangeRates) Rate(from, to string, day time.Time) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if rate, ok := x.rateOn(from, to, day); ok {
+		return rate, true
+	}
+	if rate, ok := x.rateOn(to, from, day); ok {
+		return 1 / rate, true
+	}
+	for base := range x.bases {
+		fromRate, ok := x.rateOn(base, from, day)
+		if !ok {
+			continue
+		}
+		if toRate, ok := x.rateOn(base, to, day); ok {
+			return toRate / fromRate, true
+		}
+	}
+	return 0, false
+}
+
+// Convert expresses amount in currency at the rate of day
+func (x *ExchangeRates) Convert(amount models.Money, currency string, day time.Time) (models.Money, error) {
+	rate, ok := x.Rate(amount.Currency, currency, day)
+	if !ok {
+		return models.Money{}, fmt.Errorf("%w: %s to %s on %s", ErrExchangeRateMissing, amount.Currency, currency, day.Format(reportDayLayout))
+	}
+	return amount.Convert(currency, rate), nil
+}
+
+// exchangeRates holds the rates loaded last, swapped whole on every reload
+var exchangeRates atomic.Pointer[ExchangeRates]
+
+// CurrentExchangeRates returns the rates loaded last, empty before the first load
+func CurrentExchangeRates() *ExchangeRates {
+	if x := exchangeRates.Load(); x != nil {
+		return x
+	}
+	return NewExchangeRates(nil)
+}
+
+// ReloadExchangeRates replaces the loaded rates with those of the exchangeRates collection
+func ReloadExchangeRates() error {
+	rates, err := mongoRepo.GetExchangeRates()
+	if err != nil {
+		return err
+	}
+	exchangeRates.Store(NewExchangeRates(rates))
+	return nil
+}
+
+// ParseExchangeRatesCSV reads rates from CSV with a date,base,currency,rate header, such as
+//
+//	date,base,currency,rate
+//	2024-03-01,USD,EUR,0.9231
+func ParseExchangeRatesCSV(r io.Reader) ([]*models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates header: %w", err)
+	}
+	if strings.ToLower(strings.Join(header, ",")) != "date,base,currency,rate" {
+		return nil, fmt.Errorf("exchange rates header must be date,base,currency,rate, got %s", strings.Join(header, ","))
+	}
+
+	rates := []*models.ExchangeRate{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if _, err := time.Parse(reportDayLayout, record[0]); err != nil {
+			return nil, fmt.Errorf("line %d: date must be YYYY-MM-DD", line)
+		}
+		rate, err := strconv.ParseFloat(record[3], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("line %d: rate must be a positive number", line)
+		}
+		rates = append(rates, &models.ExchangeRate{
+			Date:     record[0],
+			Base:     strings.ToUpper(record[1]),
+			Currency: strings.ToUpper(record[2]),
+			Rate:     rate,
+		})
+	}
+}
+
+// ImportExchangeRatesFile upserts the rates of a CSV file into the exchangeRates collection
+func ImportExchangeRatesFile(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	rates, err := ParseExchangeRatesCSV(file)
+	if err != nil {
+		return 0, fmt.Errorf("invalid exchange rates file %s: %w", path, err)
+	}
+	return mongoRepo.SaveExchangeRates(rates)
+}
+
+// RefreshExchangeRates imports the configured exchange rates and tax rates files, if any, and reloads
+// both. The rates stored before are still loaded when an import fails.
+func RefreshExchangeRates() error {
+	var importErrs []error
+	if path := config.ExchangeRatesFile(); path != "" {
+		changed, err := ImportExchangeRatesFile(path)
+		if err != nil {
+			importErrs = append(importErrs, err)
+		} else if changed > 0 {
+			logger.Infof("imported %d exchange rates from %s", changed, path)
+		}
+	}
+	if path := config.TaxRatesFile(); path != "" {
+		changed, err := ImportTaxRatesFile(path)
+		if err != nil {
+			importErrs = append(importErrs, err)
+		} else if changed > 0 {
+			logger.Infof("imported %d tax rates from %s", changed, path)
+		}
+	}
+
+	if err := ReloadExchangeRates(); err != nil {
+		return err
+	}
+	if err := ReloadTaxRates(); err != nil {
+		return err
+	}
+	return errors.Join(importErrs...)
+}
+
+// StartExchangeRateRefresh loads the exchange rates now and refreshes them periodically until ctx is cancelled.
+// Every replica keeps its own copy; the import is idempotent, so no lease is taken.
+func StartExchangeRateRefresh(ctx context.Context) {
+	interval := config.ExchangeRatesRefreshInterval()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := RefreshExchangeRates(); err != nil {
+				logger.Errorf("exchange rate refresh failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
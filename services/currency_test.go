@@ -0,0 +1,73 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestExchangeRatesRate(t *testing.T) {
+	rates := NewExchangeRates([]*models.ExchangeRate{
+		{Date: "2024-03-01", Base: "USD", Currency: "EUR", Rate: 0.92},
+		{Date: "2024-03-04", Base: "USD", Currency: "EUR", Rate: 0.90},
+		{Date: "2024-03-01", Base: "USD", Currency: "JPY", Rate: 150},
+		{Date: "2024-03-01", Base: "EUR", Currency: "GBP", Rate: 0.85},
+		{Date: "not a date", Base: "USD", Currency: "CHF", Rate: 0.88},
+	})
+	day := func(s string) time.Time {
+		d, _ := time.Parse(reportDayLayout, s)
+		return d
+	}
+
+	tests := []struct {
+		name   string
+		from   string
+		to     string
+		day    string
+		want   float64
+		wantOK bool
+	}{
+		{name: "same currency", from: "CHF", to: "CHF", day: "2024-03-01", want: 1, wantOK: true},
+		{name: "direct", from: "USD", to: "EUR", day: "2024-03-01", want: 0.92, wantOK: true},
+		{name: "weekend uses the last rate", from: "USD", to: "EUR", day: "2024-03-03", want: 0.92, wantOK: true},
+		{name: "newer rate", from: "USD", to: "EUR", day: "2024-03-05", want: 0.90, wantOK: true},
+		{name: "inverse", from: "EUR", to: "USD", day: "2024-03-01", want: 1 / 0.92, wantOK: true},
+		{name: "cross through base", from: "EUR", to: "JPY", day: "2024-03-01", want: 150 / 0.92, wantOK: true},
+		{name: "before first rate", from: "USD", to: "EUR", day: "2024-02-29"},
+		{name: "stale rate", from: "USD", to: "JPY", day: "2024-03-20"},
+		{name: "unknown currency", from: "USD", to: "CHF", day: "2024-03-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rates.Rate(tt.from, tt.to, day(tt.day))
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Rate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseExchangeRatesCSV(t *testing.T) {
+	rates, err := ParseExchangeRatesCSV(strings.NewReader("date,base,currency,rate\n2024-03-01,usd,eur,0.92\n2024-03-01, USD, JPY, 150.5\n"))
+	if err != nil {
+		t.Fatalf("ParseExchangeRatesCSV() error = %v", err)
+	}
+	if len(rates) != 2 || rates[0].Base != "USD" || rates[0].Currency != "EUR" || rates[1].Rate != 150.5 {
+		t.Errorf("ParseExchangeRatesCSV() = %+v", rates)
+	}
+
+	invalid := []string{
+		"day,from,to,rate\n",
+		"date,base,currency,rate\n03/01/2024,USD,EUR,0.92\n",
+		"date,base,currency,rate\n2024-03-01,USD,EUR,0\n",
+		"date,base,currency,rate\n2024-03-01,USD,EUR\n",
+	}
+	for _, input := range invalid {
+		if _, err := ParseExchangeRatesCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseExchangeRatesCSV(%q) succeeded, want error", input)
+		}
+	}
+}
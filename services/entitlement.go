@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/utils"
+)
+
+// EntitlementSource tells which kind of purchase grants a product
+type EntitlementSource string
+
+const (
+	EntitlementSourceSubscription  EntitlementSource = "subscription"
+	EntitlementSourceNonConsumable EntitlementSource = "non_consumable"
+)
+
+// ProductEntitlement is the evaluated access granted by one purchased product
+type ProductEntitlement struct {
+	ProductId                   string                   `json:"productId"`
+	Source                      EntitlementSource        `json:"source"`
+	Features                    []string                 `json:"features"`
+	Active                      bool                     `json:"active"`
+	State                       models.SubscriptionState `json:"state,omitempty"`
+	Status                      int32                    `json:"status,omitempty"`
+	StatusText                  string                   `json:"statusText,omitempty"`
+	SubscriptionGroupIdentifier string                   `json:"subscriptionGroupIdentifier,omitempty"`
+	OriginalTransactionId       string                   `json:"originalTransactionId"`
+	TransactionId               string                   `json:"transactionId"`
+	PurchaseDate                int64                    `json:"purchaseDate"`
+	ExpiresDate                 int64                    `json:"expiresDate,omitempty"`
+	GracePeriodExpiresDate      int64                    `json:"gracePeriodExpiresDate,omitempty"`
+	Revoked                     bool                     `json:"revoked"`
+	Renewal                     *models.RenewalSummary   `json:"renewal,omitempty"`
+}
+
+// UserEntitlements answers what a user can access: the features unlocked by every active product
+type UserEntitlements struct {
+	UserID      string               `json:"userId"`
+	Environment models.Environment   `json:"environment,omitempty"`
+	Features    []string             `json:"features"`
+	Products    []ProductEntitlement `json:"products"`
+	EvaluatedAt time.Time            `json:"evaluatedAt"`
+}
+
+// storedEntitlementMaxAge is how long the notification-driven state of a subscription is trusted without a
+// notification before it is ignored
+const storedEntitlementMaxAge = 24 * time.Hour
+
+// GetUserEntitlements evaluates every subscription group and non-consumable purchase of a user.
+// Subscriptions grant access while active or in the billing grace period; billing retry, expiry,
+// refunds and revocations do not. Every group Apple reports is evaluated, and the stored entitlement
+// App Store notifications maintain for a group revokes access Apple still grants when it recorded a
+// refund or revocation. Non-consumables are read from the transaction history.
+// Purchases carrying another user's appAccountToken are ignored.
+func GetUserEntitlements(ctx context.Context, user *models.User) (*UserEntitlements, error) {
+	productFeatures, err := config.ProductFeatures()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &UserEntitlements{
+		UserID:      user.ID.Hex(),
+		Features:    []string{},
+		Products:    []ProductEntitlement{},
+		EvaluatedAt: now,
+	}
+	if user.OriginalTransactionId == "" {
+		return result, nil
+	}
+
+	products, environment, err := liveSubscriptionEntitlements(ctx, user, now)
+	if err != nil {
+		return nil, err
+	}
+	originalTransactionIds := make([]string, 0, len(products))
+	for _, product := range products {
+		originalTransactionIds = append(originalTransactionIds, product.OriginalTransactionId)
+	}
+	stored, err := mongoRepo.GetSubscriptionEntitlements(originalTransactionIds)
+	if err != nil {
+		return nil, err
+	}
+	result.Environment = environment
+	result.Products = append(result.Products, applyStoredEntitlements(products, stored, now)...)
+
+	query := url.Values{}
+	query.Set("productType", "NON_CONSUMABLE")
+	history, err := Gateway().GetTransactionHistory(ctx, user.OriginalTransactionId, &query)
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range history.Transactions {
+		if CheckPurchaseOwner(user, transaction) != nil {
+			continue
+		}
+		revoked, err := IsTransactionRevoked(transaction)
+		if err != nil {
+			return nil, err
+		}
+		result.Products = append(result.Products, ProductEntitlement{
+			ProductId:             transaction.ProductID,
+			Source:                EntitlementSourceNonConsumable,
+			Active:                !revoked,
+			OriginalTransactionId: transaction.OriginalTransactionId,
+			TransactionId:         transaction.TransactionID,
+			PurchaseDate:          transaction.PurchaseDate,
+			Revoked:               revoked,
+		})
+	}
+
+	result.Features = applyProductFeatures(result.Products, productFeatures)
+	return result, nil
+}
+
+// storedEntitlementFresh reports whether a stored entitlement can answer for its subscription. It is stale
+// once no notification updated it for storedEntitlementMaxAge, or when it grants access past its expiry
+// because the renewal or expiry notification has not arrived yet.
+func storedEntitlementFresh(entitlement *models.SubscriptionEntitlement, now time.Time) bool {
+	if entitlement == nil || entitlement.State == models.SubscriptionStateNone || entitlement.ProductId == "" {
+		return false
+	}
+	if now.Sub(entitlement.UpdatedAt) > storedEntitlementMaxAge {
+		return false
+	}
+	if entitlement.State == models.SubscriptionStateActive && entitlement.ExpiresDate > 0 && entitlement.ExpiresDate <= now.UnixMilli() {
+		return false
+	}
+	return true
+}
+
+// applyStoredEntitlements overlays the fresh stored entitlement of each subscription on the products Apple
+// reported: a refund or revocation a notification recorded revokes access before Apple's status shows it.
+// Products without a fresh stored entitlement keep Apple's state.
+func applyStoredEntitlements(products []ProductEntitlement, stored []*models.SubscriptionEntitlement, now time.Time) []ProductEntitlement {
+	byOriginalTransaction := make(map[string]*models.SubscriptionEntitlement, len(stored))
+	for _, entitlement := range stored {
+		byOriginalTransaction[entitlement.OriginalTransactionId] = entitlement
+	}
+
+	for i := range products {
+		entitlement := byOriginalTransaction[products[i].OriginalTransactionId]
+		if !products[i].Active || !storedEntitlementFresh(entitlement, now) {
+			continue
+		}
+		if state := entitlement.State; state == models.SubscriptionStateRefunded || state == models.SubscriptionStateRevoked {
+			products[i].State = state
+			products[i].Active = false
+			products[i].Status = state.AppleStatus()
+			products[i].StatusText = models.StatusText(state.AppleStatus())
+			products[i].Revoked = true
+		}
+	}
+	return products
+}
+
+// liveSubscriptionEntitlements evaluates the subscription statuses Apple reports for every group
+func liveSubscriptionEntitlements(ctx context.Context, user *models.User, now time.Time) ([]ProductEntitlement, models.Environment, error) {
+	statuses, err := Gateway().GetSubscriptionStatuses(ctx, user.OriginalTransactionId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	products := []ProductEntitlement{}
+	for _, group := range statuses.Data {
+		for _, item := range group.LastTransactions {
+			product, ok, err := evaluateSubscription(user, group.SubscriptionGroupIdentifier, item, now)
+			if err != nil {
+				return nil, "", err
+			}
+			if ok {
+				products = append(products, product)
+			}
+		}
+	}
+	return products, statuses.Environment, nil
+}
+
+func evaluateSubscription(user *models.User, groupIdentifier string, item models.LastTransactionsItem, now time.Time) (ProductEntitlement, bool, error) {
+	transaction, err := utils.DecodeSignedTransactionInfo(item.SignedTransactionInfo)
+	if err != nil {
+		return ProductEntitlement{}, false, err
+	}
+	if CheckPurchaseOwner(user, transaction) != nil {
+		logger.Warnf("ignoring subscription %s of user %s: purchased by another account", item.OriginalTransactionId, user.ID.Hex())
+		return ProductEntitlement{}, false, nil
+	}
+
+	var renewalInfo *models.JWSRenewalInfoDecodedPayload
+	if item.SignedRenewalInfo != "" {
+		renewalInfo, err = utils.DecodeSignedRenewalInfo(item.SignedRenewalInfo)
+		if err != nil {
+			return ProductEntitlement{}, false, err
+		}
+	}
+
+	revoked, err := IsTransactionRevoked(transaction)
+	if err != nil {
+		return ProductEntitlement{}, false, err
+	}
+
+	state := subscriptionEntitlementState(item.Status, renewalInfo, revoked, now)
+	product := ProductEntitlement{
+		ProductId:                   transaction.ProductID,
+		Source:                      EntitlementSourceSubscription,
+		Active:                      state.HasAccess(),
+		State:                       state,
+		Status:                      state.AppleStatus(),
+		StatusText:                  models.StatusText(state.AppleStatus()),
+		SubscriptionGroupIdentifier: groupIdentifier,
+		OriginalTransactionId:       transaction.OriginalTransactionId,
+		TransactionId:               transaction.TransactionID,
+		PurchaseDate:                transaction.PurchaseDate,
+		ExpiresDate:                 transaction.ExpiresDate,
+		Revoked:                     revoked,
+	}
+	if renewalInfo != nil {
+		product.GracePeriodExpiresDate = renewalInfo.GracePeriodExpiresDate
+		product.Renewal = models.NewRenewalSummary(renewalInfo)
+	}
+	return product, true, nil
+}
+
+// subscriptionEntitlementState turns an App Store status into a subscription state. A recorded refund
+// revokes access Apple still reports, and a grace period that already ended counts as billing retry.
+func subscriptionEntitlementState(status int32, renewalInfo *models.JWSRenewalInfoDecodedPayload, revoked bool, now time.Time) models.SubscriptionState {
+	if revoked {
+		return models.SubscriptionStateRevoked
+	}
+	switch status {
+	case 1:
+		return models.SubscriptionStateActive
+	case 2:
+		return models.SubscriptionStateExpired
+	case 3:
+		return models.SubscriptionStateBillingRetry
+	case 4:
+		if renewalInfo != nil && renewalInfo.GracePeriodExpiresDate > 0 && renewalInfo.GracePeriodExpiresDate <= now.UnixMilli() {
+			return models.SubscriptionStateBillingRetry
+		}
+		return models.SubscriptionStateGracePeriod
+	case 5:
+		return models.SubscriptionStateRevoked
+	default:
+		return models.SubscriptionStateNone
+	}
+}
+
+// applyProductFeatures fills in the features of each product and returns the sorted features of the active ones
+func applyProductFeatures(products []ProductEntitlement, productFeatures map[string][]string) []string {
+	unlocked := map[string]bool{}
+	for i := range products {
+		products[i].Features = productFeatures[products[i].ProductId]
+		if products[i].Features == nil {
+			products[i].Features = []string{}
+		}
+		if !products[i].Active {
+			continue
+		}
+		for _, feature := range products[i].Features {
+			unlocked[feature] = true
+		}
+	}
+
+	features := make([]string, 0, len(unlocked))
+	for feature := range unlocked {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestSubscriptionEntitlementState(t *testing.T) {
+	now := time.Now()
+	ended := &models.JWSRenewalInfoDecodedPayload{GracePeriodExpiresDate: now.Add(-time.Hour).UnixMilli()}
+	running := &models.JWSRenewalInfoDecodedPayload{GracePeriodExpiresDate: now.Add(time.Hour).UnixMilli()}
+
+	tests := []struct {
+		name        string
+		status      int32
+		renewalInfo *models.JWSRenewalInfoDecodedPayload
+		revoked     bool
+		want        models.SubscriptionState
+		wantAccess  bool
+	}{
+		{name: "active", status: 1, want: models.SubscriptionStateActive, wantAccess: true},
+		{name: "expired", status: 2, want: models.SubscriptionStateExpired},
+		{name: "billing retry", status: 3, want: models.SubscriptionStateBillingRetry},
+		{name: "grace period", status: 4, renewalInfo: running, want: models.SubscriptionStateGracePeriod, wantAccess: true},
+		{name: "grace period ended", status: 4, renewalInfo: ended, want: models.SubscriptionStateBillingRetry},
+		{name: "revoked by apple", status: 5, want: models.SubscriptionStateRevoked},
+		{name: "refund recorded before apple status", status: 1, revoked: true, want: models.SubscriptionStateRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subscriptionEntitlementState(tt.status, tt.renewalInfo, tt.revoked, now)
+			if got != tt.want {
+				t.Errorf("subscriptionEntitlementState() = %q, want %q", got, tt.want)
+			}
+			if got.HasAccess() != tt.wantAccess {
+				t.Errorf("HasAccess() = %v, want %v", got.HasAccess(), tt.wantAccess)
+			}
+		})
+	}
+}
+
+func TestStoredEntitlementFresh(t *testing.T) {
+	now := time.Now()
+	stored := func(state models.SubscriptionState, expires, updated time.Time) *models.SubscriptionEntitlement {
+		return &models.SubscriptionEntitlement{
+			OriginalTransactionId: "2000000000000001",
+			ProductId:             "pro.monthly",
+			State:                 state,
+			ExpiresDate:           expires.UnixMilli(),
+			UpdatedAt:             updated,
+		}
+	}
+	noProduct := stored(models.SubscriptionStateActive, now.Add(time.Hour), now)
+	noProduct.ProductId = ""
+
+	tests := []struct {
+		name        string
+		entitlement *models.SubscriptionEntitlement
+		want        bool
+	}{
+		{name: "missing", entitlement: nil},
+		{name: "no state yet", entitlement: stored(models.SubscriptionStateNone, now.Add(time.Hour), now)},
+		{name: "no product", entitlement: noProduct},
+		{name: "active", entitlement: stored(models.SubscriptionStateActive, now.Add(time.Hour), now.Add(-time.Hour)), want: true},
+		{name: "active past expiry", entitlement: stored(models.SubscriptionStateActive, now.Add(-time.Minute), now.Add(-time.Hour))},
+		{name: "grace period past expiry", entitlement: stored(models.SubscriptionStateGracePeriod, now.Add(-time.Hour), now.Add(-time.Hour)), want: true},
+		{name: "expired", entitlement: stored(models.SubscriptionStateExpired, now.Add(-48*time.Hour), now.Add(-time.Hour)), want: true},
+		{name: "not updated for too long", entitlement: stored(models.SubscriptionStateActive, now.Add(time.Hour), now.Add(-storedEntitlementMaxAge-time.Minute))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storedEntitlementFresh(tt.entitlement, now); got != tt.want {
+				t.Errorf("storedEntitlementFresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyStoredEntitlements(t *testing.T) {
+	now := time.Now()
+	products := []ProductEntitlement{
+		{ProductId: "pro.monthly", SubscriptionGroupIdentifier: "pro", OriginalTransactionId: "2000000000000001", State: models.SubscriptionStateActive, Active: true, Status: 1},
+		{ProductId: "team.yearly", SubscriptionGroupIdentifier: "team", OriginalTransactionId: "2000000000000002", State: models.SubscriptionStateActive, Active: true, Status: 1},
+	}
+	stored := []*models.SubscriptionEntitlement{{
+		OriginalTransactionId: "2000000000000002",
+		ProductId:             "team.yearly",
+		State:                 models.SubscriptionStateRefunded,
+		ExpiresDate:           now.Add(time.Hour).UnixMilli(),
+		UpdatedAt:             now.Add(-time.Hour),
+	}}
+
+	got := applyStoredEntitlements(products, stored, now)
+	if len(got) != 2 {
+		t.Fatalf("applyStoredEntitlements() returned %d products, want both groups", len(got))
+	}
+	if !got[0].Active || got[0].State != models.SubscriptionStateActive {
+		t.Errorf("group without a stored entitlement = %q active %v, want active", got[0].State, got[0].Active)
+	}
+	if got[1].Active || got[1].State != models.SubscriptionStateRefunded || !got[1].Revoked {
+		t.Errorf("refunded group = %q active %v revoked %v, want refunded without access", got[1].State, got[1].Active, got[1].Revoked)
+	}
+}
+
+func TestApplyProductFeatures(t *testing.T) {
+	productFeatures := map[string][]string{
+		"pro.monthly": {"pro", "export"},
+		"themes":      {"themes"},
+		"team.yearly": {"team", "pro"},
+	}
+	products := []ProductEntitlement{
+		{ProductId: "pro.monthly", Active: true},
+		{ProductId: "themes", Active: true},
+		{ProductId: "team.yearly", Active: false},
+		{ProductId: "unmapped", Active: true},
+	}
+
+	got := applyProductFeatures(products, productFeatures)
+	if want := []string{"export", "pro", "themes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applyProductFeatures() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(products[2].Features, []string{"team", "pro"}) {
+		t.Errorf("inactive product features = %v, want them listed", products[2].Features)
+	}
+	if products[3].Features == nil {
+		t.Errorf("unmapped product features = nil, want empty")
+	}
+}
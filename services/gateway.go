@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	"simvizlab-backend/utils"
+)
+
+// AppStoreGateway is the application-wide entry point to the App Store Server API.
+// It wraps a StoreClient, which caches the signed bearer token between calls, and verifies
+// every signed payload it returns.
+//
+// In sandbox fallback mode lookups go to production first and are retried on the sandbox
+// host when production does not know the transaction.
+type AppStoreGateway struct {
+	client      *models.StoreClient
+	environment models.Environment
+	sandbox     *models.StoreClient
+}
+
+// TransactionHistory is the verified transaction history of an original transaction across all pages
+type TransactionHistory struct {
+	AppAppleId   int64                    `json:"appAppleId"`
+	BundleId     string                   `json:"bundleId"`
+	Environment  models.Environment       `json:"environment"`
+	Transactions []*models.JWSTransaction `json:"transactions"`
+}
+
+var (
+	gateway     *AppStoreGateway
+	gatewayOnce sync.Once
+)
+
+// Gateway returns the App Store gateway configured from the environment
+func Gateway() *AppStoreGateway {
+	gatewayOnce.Do(func() {
+		gateway = NewAppStoreGateway(config.AppStoreConfig(), config.AppStoreSandboxFallback())
+	})
+	return gateway
+}
+
+// NewAppStoreGateway creates a gateway for the production or sandbox host selected by cfg.Sandbox.
+// sandboxFallback enables retrying production lookups on the sandbox host.
+func NewAppStoreGateway(cfg *models.StoreConfig, sandboxFallback bool) *AppStoreGateway {
+	return newAppStoreGateway(cfg, sandboxFallback, models.NewStoreClient)
+}
+
+// NewAppStoreGatewayWithHTTPClient creates a gateway whose clients send requests through httpClient
+func NewAppStoreGatewayWithHTTPClient(cfg *models.StoreConfig, sandboxFallback bool, httpClient models.HTTPClient) *AppStoreGateway {
+	return newAppStoreGateway(cfg, sandboxFallback, func(c *models.StoreConfig) *models.StoreClient {
+		return models.NewStoreClientWithHTTPClient(c, httpClient)
+	})
+}
+
+func newAppStoreGateway(cfg *models.StoreConfig, sandboxFallback bool, newClient func(*models.StoreConfig) *models.StoreClient) *AppStoreGateway {
+	g := &AppStoreGateway{
+		client:      newClient(cfg),
+		environment: models.Production,
+	}
+	if cfg.Sandbox {
+		g.environment = models.Sandbox
+	} else if sandboxFallback {
+		sandboxCfg := *cfg
+		sandboxCfg.Sandbox = true
+		g.sandbox = newClient(&sandboxCfg)
+	}
+	return g
+}
+
+// Client exposes the underlying StoreClient for endpoints the gateway does not wrap
+func (g *AppStoreGateway) Client() *models.StoreClient {
+	return g.client
+}
+
+// ClientFor returns the StoreClient of the given environment, falling back to the primary client
+func (g *AppStoreGateway) ClientFor(environment models.Environment) *models.StoreClient {
+	if environment == models.Sandbox && g.sandbox != nil {
+		return g.sandbox
+	}
+	return g.client
+}
+
+// Environment reports the App Store environment the gateway talks to first
+func (g *AppStoreGateway) Environment() models.Environment {
+	return g.environment
+}
+
+// lookup runs call against the primary host and, in fallback mode, against the sandbox host when
+// production reports the transaction as unknown. It returns the environment that answered.
+func (g *AppStoreGateway) lookup(call func(*models.StoreClient) error) (models.Environment, error) {
+	err := call(g.client)
+	if err == nil || g.sandbox == nil || !isTransactionNotFound(err) {
+		return g.environment, err
+	}
+
+	logger.Infof("transaction not found in %s, retrying in %s", g.environment, models.Sandbox)
+	if err := call(g.sandbox); err != nil {
+		return models.Sandbox, err
+	}
+	return models.Sandbox, nil
+}
+
+func isTransactionNotFound(err error) bool {
+	return errors.Is(err, models.TransactionIdNotFoundError) || errors.Is(err, models.OriginalTransactionIdNotFoundError)
+}
+
+// GetTransaction fetches and verifies a single transaction
+func (g *AppStoreGateway) GetTransaction(ctx context.Context, transactionId string) (*models.JWSTransaction, models.Environment, error) {
+	var rsp *models.TransactionInfoResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		rsp, err = client.GetTransactionInfo(ctx, transactionId)
+		return err
+	})
+	if err != nil {
+		return nil, environment, err
+	}
+	transaction, err := utils.DecodeSignedTransactionInfo(rsp.SignedTransactionInfo)
+	return transaction, environment, err
+}
+
+// GetTransactionHistory fetches every page of an original transaction's history and verifies each transaction
+func (g *AppStoreGateway) GetTransactionHistory(ctx context.Context, originalTransactionId string, query *url.Values) (*TransactionHistory, error) {
+	var pages []*models.HistoryResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		// Pagination writes the revision into the query, so every attempt starts from a copy
+		attempt := url.Values{}
+		if query != nil {
+			for key, values := range *query {
+				attempt[key] = append([]string(nil), values...)
+			}
+		}
+		pages, err = client.GetTransactionHistory(ctx, originalTransactionId, &attempt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := &TransactionHistory{Environment: environment, Transactions: []*models.JWSTransaction{}}
+	for _, page := range pages {
+		history.AppAppleId = page.AppAppleId
+		history.BundleId = page.BundleId
+		for _, signed := range page.SignedTransactions {
+			transaction, err := utils.DecodeSignedTransactionInfo(signed)
+			if err != nil {
+				return nil, err
+			}
+			history.Transactions = append(history.Transactions, transaction)
+		}
+	}
+	return history, nil
+}
+
+// TransactionHistoryPage is one page of an original transaction's verified history, paged the way Apple pages it.
+// Revision continues the history after this page when HasMore is set.
+type TransactionHistoryPage struct {
+	TransactionHistory
+	Revision string `json:"revision,omitempty"`
+	HasMore  bool   `json:"hasMore"`
+}
+
+// GetTransactionHistoryPage fetches and verifies one page of an original transaction's history. An empty
+// revision starts the history, looked up like GetTransactionHistory; a revision continues it in environment,
+// the environment of the page that returned the revision.
+func (g *AppStoreGateway) GetTransactionHistoryPage(ctx context.Context, originalTransactionId string, query url.Values, revision string, environment models.Environment) (*TransactionHistoryPage, error) {
+	attempt := url.Values{}
+	for key, values := range query {
+		attempt[key] = append([]string(nil), values...)
+	}
+
+	var rsp *models.HistoryResponse
+	var err error
+	if revision == "" {
+		environment, err = g.lookup(func(client *models.StoreClient) (err error) {
+			rsp, err = client.GetTransactionHistoryPage(ctx, originalTransactionId, attempt)
+			return err
+		})
+	} else {
+		attempt.Set("revision", revision)
+		rsp, err = g.ClientFor(environment).GetTransactionHistoryPage(ctx, originalTransactionId, attempt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionHistoryPage{
+		TransactionHistory: TransactionHistory{
+			AppAppleId:   rsp.AppAppleId,
+			BundleId:     rsp.BundleId,
+			Environment:  environment,
+			Transactions: make([]*models.JWSTransaction, 0, len(rsp.SignedTransactions)),
+		},
+		HasMore: rsp.HasMore && rsp.Revision != "",
+	}
+	if page.HasMore {
+		page.Revision = rsp.Revision
+	}
+	for _, signed := range rsp.SignedTransactions {
+		transaction, err := utils.DecodeSignedTransactionInfo(signed)
+		if err != nil {
+			return nil, err
+		}
+		page.Transactions = append(page.Transactions, transaction)
+	}
+	return page, nil
+}
+
+// GetSubscriptionStatuses fetches the statuses of every subscription in the original transaction's groups
+func (g *AppStoreGateway) GetSubscriptionStatuses(ctx context.Context, originalTransactionId string) (*models.StatusResponse, error) {
+	var rsp *models.StatusResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		rsp, err = client.GetALLSubscriptionStatuses(ctx, originalTransactionId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Environment == "" {
+		rsp.Environment = environment
+	}
+	return rsp, nil
+}
+
+// GetRefundHistory fetches every page of a customer's refunded transactions and verifies each one
+func (g *AppStoreGateway) GetRefundHistory(ctx context.Context, originalTransactionId string) ([]*models.JWSTransaction, models.Environment, error) {
+	var pages []*models.RefundLookupResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		pages, err = client.GetRefundHistory(ctx, originalTransactionId)
+		return err
+	})
+	if err != nil {
+		return nil, environment, err
+	}
+
+	transactions := []*models.JWSTransaction{}
+	for _, page := range pages {
+		for _, signed := range page.SignedTransactions {
+			transaction, err := utils.DecodeSignedTransactionInfo(signed)
+			if err != nil {
+				return nil, environment, err
+			}
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, environment, nil
+}
+
+// LookupOrder fetches the transactions of a customer's invoice order ID and verifies each one.
+// The returned status is 0 for a valid order ID and 1 for an invalid one.
+func (g *AppStoreGateway) LookupOrder(ctx context.Context, orderId string) (int, []*models.JWSTransaction, models.Environment, error) {
+	var rsp *models.OrderLookupResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		rsp, err = client.LookupOrderID(ctx, orderId)
+		return err
+	})
+	if err != nil {
+		return 0, nil, environment, err
+	}
+
+	transactions := make([]*models.JWSTransaction, 0, len(rsp.SignedTransactions))
+	for _, signed := range rsp.SignedTransactions {
+		transaction, err := utils.DecodeSignedTransactionInfo(signed)
+		if err != nil {
+			return 0, nil, environment, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return rsp.Status, transactions, environment, nil
+}
+
+// ExtendRenewalDate extends the renewal date of one subscription
+func (g *AppStoreGateway) ExtendRenewalDate(ctx context.Context, originalTransactionId string, body models.ExtendRenewalDateRequest) (*models.ExtendRenewalDateResponse, models.Environment, error) {
+	var rsp *models.ExtendRenewalDateResponse
+	environment, err := g.lookup(func(client *models.StoreClient) (err error) {
+		_, rsp, err = client.ExtendSubscriptionRenewalDateWithResponse(ctx, originalTransactionId, body)
+		return err
+	})
+	return rsp, environment, err
+}
+
+// MassExtendRenewalDate asks Apple to extend the renewal date of every active subscriber of a product
+func (g *AppStoreGateway) MassExtendRenewalDate(ctx context.Context, body models.MassExtendRenewalDateRequest) error {
+	_, err := g.client.ExtendSubscriptionRenewalDateForAll(ctx, body)
+	return err
+}
+
+// GetMassExtensionStatus fetches the progress of a mass renewal-date extension
+func (g *AppStoreGateway) GetMassExtensionStatus(ctx context.Context, environment models.Environment, productId, requestIdentifier string) (*models.MassExtendRenewalDateStatusResponse, error) {
+	_, rsp, err := g.ClientFor(environment).GetSubscriptionRenewalDataStatus(ctx, productId, requestIdentifier)
+	return rsp, err
+}
+
+// GetNotificationHistory fetches every page of notifications Apple sent, or tried to send, in a date range
+func (g *AppStoreGateway) GetNotificationHistory(ctx context.Context, request models.NotificationHistoryRequest) ([]models.NotificationHistoryResponseItem, error) {
+	return g.client.GetNotificationHistory(ctx, request)
+}
+
+// RequestTestNotification asks Apple to send a TEST notification to our notification URL and returns its token
+func (g *AppStoreGateway) RequestTestNotification(ctx context.Context) (string, error) {
+	status, body, err := g.client.SendRequestTestNotification(ctx)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("appstore api: request test notification returned status code %d", status)
+	}
+
+	var rsp models.SendTestNotificationResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.TestNotificationToken, nil
+}
+
+// GetTestNotificationStatus fetches the delivery status of a TEST notification
+func (g *AppStoreGateway) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (*models.CheckTestNotificationResponse, error) {
+	status, body, err := g.client.GetTestNotificationStatus(ctx, testNotificationToken)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("appstore api: test notification status returned status code %d", status)
+	}
+
+	var rsp models.CheckTestNotificationResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return nil, err
+	}
+	return &rsp, nil
+}
+
+// SetAppAccountToken sets the appAccountToken of a purchase made before we issued tokens
+func (g *AppStoreGateway) SetAppAccountToken(ctx context.Context, originalTransactionId, appAccountToken string) (models.Environment, error) {
+	return g.lookup(func(client *models.StoreClient) error {
+		status, err := client.SetAppAccountToken(ctx, originalTransactionId, models.UpdateAppAccountTokenRequest{AppAccountToken: appAccountToken})
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("appstore api: set app account token returned status code %d", status)
+		}
+		return nil
+	})
+}
+
+// AppStoreErrorStatus maps an error returned by the gateway to the HTTP status to answer with
+func AppStoreErrorStatus(err error) int {
+	var apiErr *models.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() / 10000 {
+		case 400:
+			return http.StatusBadRequest
+		case 404:
+			return http.StatusNotFound
+		case 429:
+			return http.StatusTooManyRequests
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// AppStoreErrorMessage describes an error returned by the gateway
+func AppStoreErrorMessage(err error) string {
+	var apiErr *models.Error
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("App Store error %d: %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
+	}
+	return err.Error()
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"simvizlab-backend/models"
+	"simvizlab-backend/models/appstoretest"
+)
+
+func TestAppStoreGatewayLookup(t *testing.T) {
+	tests := []struct {
+		name            string
+		transactionId   string
+		fault           *models.Error
+		wantErr         error
+		wantEnvironment models.Environment
+		wantRequests    int
+	}{
+		{name: "production", transactionId: "1000", wantEnvironment: models.Production, wantRequests: 1},
+		{name: "transaction not found falls back", transactionId: "2000", wantEnvironment: models.Sandbox, wantRequests: 2},
+		{name: "original transaction not found falls back", transactionId: "2000", fault: models.OriginalTransactionIdNotFoundError, wantEnvironment: models.Sandbox, wantRequests: 2},
+		{name: "unknown in both", transactionId: "404", wantErr: models.TransactionIdNotFoundError, wantEnvironment: models.Sandbox, wantRequests: 2},
+		{name: "other errors do not fall back", transactionId: "2000", fault: models.InvalidTransactionIdError, wantErr: models.InvalidTransactionIdError, wantEnvironment: models.Production, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := appstoretest.NewServer(t)
+			s.AddTransaction(&models.JWSTransaction{TransactionID: "1000", ProductID: "themes", Environment: models.Production})
+			s.AddTransaction(&models.JWSTransaction{TransactionID: "2000", ProductID: "themes", Environment: models.Sandbox})
+			if tt.fault != nil {
+				s.Fail(appstoretest.Fault{Path: models.PathTransactionInfo, Err: tt.fault, Times: 1})
+			}
+			g := NewAppStoreGatewayWithHTTPClient(s.Config(models.Production), true, s.Client())
+
+			var rsp *models.TransactionInfoResponse
+			var client *models.StoreClient
+			environment, err := g.lookup(func(c *models.StoreClient) (err error) {
+				client = c
+				rsp, err = c.GetTransactionInfo(context.TODO(), tt.transactionId)
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("lookup() error = %v, want %v", err, tt.wantErr)
+			}
+			if environment != tt.wantEnvironment {
+				t.Errorf("lookup() environment = %s, want %s", environment, tt.wantEnvironment)
+			}
+			if got := len(s.Requests()); got != tt.wantRequests {
+				t.Errorf("lookup() sent %d requests, want %d", got, tt.wantRequests)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			transaction, err := client.ParseNotificationV2TransactionInfo(rsp.SignedTransactionInfo)
+			if err != nil {
+				t.Fatalf("ParseNotificationV2TransactionInfo() error = %v", err)
+			}
+			if transaction.TransactionID != tt.transactionId || transaction.Environment != tt.wantEnvironment {
+				t.Errorf("lookup() = %s in %s, want %s in %s", transaction.TransactionID, transaction.Environment, tt.transactionId, tt.wantEnvironment)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+	"simvizlab-backend/utils"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidNotification is returned when a signed notification fails verification or decoding
+var ErrInvalidNotification = errors.New("invalid app store notification")
+
+// DecodeNotification verifies the x5c chain of a signedPayload and decodes it together with
+// the nested signedTransactionInfo and signedRenewalInfo.
+func DecodeNotification(signedPayload string) (*models.AppStoreNotification, error) {
+	payload, err := utils.DecodeSignedNotification(signedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	if payload.NotificationUUID == "" {
+		return nil, fmt.Errorf("%w: missing notificationUUID", ErrInvalidNotification)
+	}
+
+	notification := &models.AppStoreNotification{
+		NotificationUUID:    payload.NotificationUUID,
+		NotificationType:    models.NotificationTypeV2(payload.NotificationType),
+		Subtype:             models.SubtypeV2(payload.Subtype),
+		NotificationVersion: payload.NotificationVersion,
+		Environment:         models.Environment(payload.Data.Environment),
+		BundleId:            payload.Data.BundleID,
+		AppAppleId:          int64(payload.Data.AppAppleID),
+		SignedDate:          payload.SignedDate,
+		SignedPayload:       signedPayload,
+	}
+
+	if payload.Data.SignedTransactionInfo != "" {
+		transaction, err := utils.DecodeSignedTransactionInfo(payload.Data.SignedTransactionInfo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: signedTransactionInfo: %v", ErrInvalidNotification, err)
+		}
+		notification.Transaction = transaction
+		notification.OriginalTransactionId = transaction.OriginalTransactionId
+		notification.TransactionId = transaction.TransactionID
+	}
+
+	if payload.Data.SignedRenewalInfo != "" {
+		renewalInfo, err := utils.DecodeSignedRenewalInfo(payload.Data.SignedRenewalInfo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: signedRenewalInfo: %v", ErrInvalidNotification, err)
+		}
+		notification.RenewalInfo = renewalInfo
+		if notification.OriginalTransactionId == "" {
+			notification.OriginalTransactionId = renewalInfo.OriginalTransactionId
+		}
+	}
+
+	return notification, nil
+}
+
+// IngestNotification verifies and durably stores a signed notification.
+// The returned bool is false when the notification had already been stored.
+func IngestNotification(signedPayload string) (*models.AppStoreNotification, bool, error) {
+	notification, err := DecodeNotification(signedPayload)
+	if err != nil {
+		return nil, false, err
+	}
+	notification.ReceivedAt = time.Now()
+
+	created, err := mongoRepo.SaveNotification(notification)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := mongoRepo.MarkNotificationReceived(notification.NotificationUUID, notification.NotificationType); err != nil {
+		return nil, false, err
+	}
+	return notification, created, nil
+}
+
+// notificationLease bounds how long a claim is held before another replica may take it over
+const notificationLease = 5 * time.Minute
+
+// instanceID identifies this replica as the owner of notification claims
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+// ProcessNotification applies a stored notification exactly once across all replicas.
+// It reports false without error when the notification was already applied or is being
+// applied elsewhere.
+func ProcessNotification(notification *models.AppStoreNotification) (bool, error) {
+	_, claimed, err := mongoRepo.ClaimNotification(notification.NotificationUUID, notification.NotificationType, instanceID, notificationLease)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	if applyErr := applyNotification(notification); applyErr != nil {
+		logger.Errorf("failed to apply notification %s (%s): %v", notification.NotificationUUID, notification.NotificationType, applyErr)
+		if err := mongoRepo.FailNotification(notification.NotificationUUID, instanceID, applyErr); err != nil {
+			return true, err
+		}
+		return true, applyErr
+	}
+
+	return true, mongoRepo.CompleteNotification(notification.NotificationUUID, instanceID)
+}
+
+// applyNotification dispatches a notification to the handlers for its type
+func applyNotification(notification *models.AppStoreNotification) error {
+	logger.Infof("applying notification %s (%s %s)", notification.NotificationUUID, notification.NotificationType, notification.Subtype)
+
+	if err := linkNotificationPurchase(notification); err != nil {
+		return err
+	}
+
+	switch notification.NotificationType {
+	case models.NotificationTypeV2Refund, models.NotificationTypeV2RefundReversed:
+		if err := applyRefundNotification(notification); err != nil {
+			return err
+		}
+	case models.NotificationTypeV2ConsumptionRequest:
+		return respondToConsumptionRequest(notification)
+	}
+
+	return applySubscriptionNotification(notification)
+}
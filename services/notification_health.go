@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NotificationHealthJob names the scheduled health check in the job state collection
+const NotificationHealthJob = "notificationHealthCheck"
+
+const (
+	// testNotificationTimeout bounds how long we wait for Apple to deliver a TEST notification
+	testNotificationTimeout = 2 * time.Minute
+	// testNotificationPollInterval is how often the delivery status is checked while waiting
+	testNotificationPollInterval = 5 * time.Second
+)
+
+// RunNotificationHealthCheck requests a TEST notification, waits for our webhook to store it and for
+// Apple to report the first delivery attempt, and records a pass or fail with the delivery latency.
+func RunNotificationHealthCheck(ctx context.Context) (*models.NotificationHealthCheck, error) {
+	check, err := requestNotificationHealthCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return check, awaitNotificationHealthCheck(ctx, check)
+}
+
+// BeginNotificationHealthCheck requests a TEST notification and records the pending check, then waits for
+// the round trip in the background. The check's token identifies it in the stored health checks.
+func BeginNotificationHealthCheck(ctx context.Context) (*models.NotificationHealthCheck, error) {
+	check, err := requestNotificationHealthCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := *check
+	go func() {
+		// the wait outlives the request that started it
+		if err := awaitNotificationHealthCheck(context.Background(), &pending); err != nil {
+			logger.Errorf("failed to store notification health check %s: %v", pending.TestNotificationToken, err)
+		}
+	}()
+	return check, nil
+}
+
+func requestNotificationHealthCheck(ctx context.Context) (*models.NotificationHealthCheck, error) {
+	requestedAt := time.Now()
+	token, err := Gateway().RequestTestNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &models.NotificationHealthCheck{
+		TestNotificationToken: token,
+		Environment:           Gateway().Environment(),
+		Status:                models.NotificationHealthPending,
+		RequestedAt:           requestedAt,
+	}
+	if err := mongoRepo.SaveNotificationHealthCheck(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// awaitNotificationHealthCheck polls a pending check until it passes, fails or times out and stores the outcome
+func awaitNotificationHealthCheck(ctx context.Context, check *models.NotificationHealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, testNotificationTimeout)
+	defer cancel()
+	ticker := time.NewTicker(testNotificationPollInterval)
+	defer ticker.Stop()
+
+	for check.Status == models.NotificationHealthPending {
+		select {
+		case <-ctx.Done():
+			finishTimedOutHealthCheck(check)
+		case <-ticker.C:
+			if err := refreshHealthCheck(ctx, check); err != nil {
+				check.Error = err.Error()
+			}
+		}
+	}
+
+	completedAt := time.Now()
+	check.CompletedAt = &completedAt
+	if check.Status == models.NotificationHealthFailed {
+		logger.Errorf("notification health check %s failed: first send attempt %q, error %q", check.TestNotificationToken, check.FirstSendAttemptResult, check.Error)
+	} else {
+		logger.Infof("notification health check %s passed in %dms", check.TestNotificationToken, check.LatencyMs)
+	}
+	return mongoRepo.SaveNotificationHealthCheck(check)
+}
+
+// refreshHealthCheck reads the delivery status from Apple and looks the notification up in our store
+func refreshHealthCheck(ctx context.Context, check *models.NotificationHealthCheck) error {
+	rsp, err := Gateway().GetTestNotificationStatus(ctx, check.TestNotificationToken)
+	if errors.Is(err, models.TestNotificationNotFoundError) {
+		// Apple has not attempted delivery yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if check.NotificationUUID == "" {
+		notification, err := DecodeNotification(rsp.SignedPayload)
+		if err != nil {
+			return err
+		}
+		check.NotificationUUID = notification.NotificationUUID
+	}
+	check.SendAttempts = rsp.SendAttempts
+	check.FirstSendAttemptResult = rsp.FirstSendAttemptResult
+	if check.FirstSendAttemptResult == "" && len(rsp.SendAttempts) > 0 {
+		check.FirstSendAttemptResult = rsp.SendAttempts[0].SendAttemptResult
+	}
+
+	if check.ReceivedAt == nil {
+		notification, err := mongoRepo.GetNotification(check.NotificationUUID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if notification != nil {
+			check.ReceivedAt = &notification.ReceivedAt
+			check.LatencyMs = notification.ReceivedAt.Sub(check.RequestedAt).Milliseconds()
+		}
+	}
+
+	check.Status = testNotificationOutcome(check.FirstSendAttemptResult, check.ReceivedAt != nil)
+	if check.Status == models.NotificationHealthFailed {
+		check.Error = fmt.Sprintf("first send attempt result %s", check.FirstSendAttemptResult)
+	}
+	return nil
+}
+
+// testNotificationOutcome passes a check once Apple reports a successful first attempt and our webhook
+// stored the notification, and fails it as soon as Apple reports an unsuccessful first attempt
+func testNotificationOutcome(firstSendAttempt models.FirstSendAttemptResult, received bool) models.NotificationHealthStatus {
+	switch {
+	case firstSendAttempt == "":
+		return models.NotificationHealthPending
+	case firstSendAttempt != models.FirstSendAttemptResultSuccess:
+		return models.NotificationHealthFailed
+	case received:
+		return models.NotificationHealthPassed
+	default:
+		return models.NotificationHealthPending
+	}
+}
+
+func finishTimedOutHealthCheck(check *models.NotificationHealthCheck) {
+	if check.ReceivedAt != nil {
+		// Our webhook stored it even though Apple's status was not available in time
+		check.Status = models.NotificationHealthPassed
+		return
+	}
+	check.Status = models.NotificationHealthFailed
+	if check.FirstSendAttemptResult == models.FirstSendAttemptResultSuccess {
+		check.Error = "Apple delivered the test notification but it was not stored"
+	} else {
+		check.Error = fmt.Sprintf("test notification not received within %s", testNotificationTimeout)
+	}
+}
+
+// StartNotificationHealthCheck runs the health check at the configured interval until ctx is cancelled.
+// Only one replica runs each check.
+func StartNotificationHealthCheck(ctx context.Context) {
+	interval := config.NotificationHealthCheckInterval()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runScheduledHealthCheck(ctx, interval)
+			}
+		}
+	}()
+}
+
+func runScheduledHealthCheck(ctx context.Context, interval time.Duration) {
+	// The lease outlives the check so other replicas skip this round
+	lease := interval / 2
+	if lease < 2*testNotificationTimeout {
+		lease = 2 * testNotificationTimeout
+	}
+	_, claimed, err := mongoRepo.ClaimJob(NotificationHealthJob, instanceID, lease)
+	if err != nil {
+		logger.Errorf("failed to claim notification health check: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	check, runErr := RunNotificationHealthCheck(ctx)
+	if runErr != nil {
+		logger.Errorf("notification health check could not run: %v", runErr)
+	}
+	var ranAt int64
+	if check != nil {
+		ranAt = check.RequestedAt.UnixMilli()
+	}
+	if err := mongoRepo.RecordJobRun(NotificationHealthJob, instanceID, ranAt, runErr); err != nil {
+		logger.Errorf("failed to record notification health check run: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+
+	"simvizlab-backend/models"
+)
+
+func TestTestNotificationOutcome(t *testing.T) {
+	tests := []struct {
+		name             string
+		firstSendAttempt models.FirstSendAttemptResult
+		received         bool
+		want             models.NotificationHealthStatus
+	}{
+		{name: "not attempted yet", want: models.NotificationHealthPending},
+		{name: "delivered and stored", firstSendAttempt: models.FirstSendAttemptResultSuccess, received: true, want: models.NotificationHealthPassed},
+		{name: "delivered but not stored yet", firstSendAttempt: models.FirstSendAttemptResultSuccess, want: models.NotificationHealthPending},
+		{name: "stored before apple reported", received: true, want: models.NotificationHealthPending},
+		{name: "timed out", firstSendAttempt: models.FirstSendAttemptResultTimedOut, want: models.NotificationHealthFailed},
+		{name: "tls issue", firstSendAttempt: models.FirstSendAttemptResultTlsIssue, received: true, want: models.NotificationHealthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testNotificationOutcome(tt.firstSendAttempt, tt.received); got != tt.want {
+				t.Errorf("testNotificationOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/models"
+)
+
+// ErrOfferNotEligible is returned when a user asks for an offer they may not redeem
+var ErrOfferNotEligible = errors.New("user is not eligible for this offer")
+
+// OfferType tells how an offer's eligibility was established
+type OfferType string
+
+const (
+	OfferTypePromotional OfferType = "promotional"
+	OfferTypeWinBack     OfferType = "win_back"
+)
+
+// EligibleOffer is an offer a user may redeem
+type EligibleOffer struct {
+	OfferId                     string    `json:"offerId"`
+	Type                        OfferType `json:"type"`
+	ProductId                   string    `json:"productId"`
+	SubscriptionGroupIdentifier string    `json:"subscriptionGroupIdentifier,omitempty"`
+}
+
+// EligibleOffers are the offers a user may redeem. Promotional offers need a signature from SignOffer.
+// Win-back offers are eligibility info only: StoreKit redeems them without a promotional offer signature.
+type EligibleOffers struct {
+	Promotional []EligibleOffer `json:"offers"`
+	WinBack     []EligibleOffer `json:"winBackOffers"`
+}
+
+var (
+	offerKeySigner     *models.OfferSigner
+	offerKeySignerErr  error
+	offerKeySignerOnce sync.Once
+)
+
+func offerSigner() (*models.OfferSigner, error) {
+	offerKeySignerOnce.Do(func() {
+		keyID, keyContent := config.OfferSigningKey()
+		offerKeySigner, offerKeySignerErr = models.NewOfferSigner(keyContent, keyID, config.AppStoreConfig().BundleID)
+	})
+	return offerKeySigner, offerKeySignerErr
+}
+
+// GetEligibleOffers lists the win-back offers Apple reports for the user's subscriptions and the
+// configured promotional offers of subscription groups the user has subscribed to
+func GetEligibleOffers(ctx context.Context, user *models.User) (*EligibleOffers, error) {
+	promotionalOffers, err := config.PromotionalOffers()
+	if err != nil {
+		return nil, err
+	}
+	entitlements, err := GetUserEntitlements(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return eligibleOffers(entitlements.Products, promotionalOffers), nil
+}
+
+// SignOffer signs a promotional offer the user is eligible for, bound to their appAccountToken.
+// An empty productId signs the offer for the product it was found eligible on. Win-back offers are not signed.
+func SignOffer(ctx context.Context, user *models.User, productId, offerId string) (*models.PromotionalOfferSignature, error) {
+	offers, err := GetEligibleOffers(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var offer *EligibleOffer
+	for i, candidate := range offers.Promotional {
+		if candidate.OfferId == offerId && (productId == "" || productId == candidate.ProductId) {
+			offer = &offers.Promotional[i]
+			break
+		}
+	}
+	if offer == nil || offer.Type != OfferTypePromotional {
+		return nil, ErrOfferNotEligible
+	}
+
+	appAccountToken, err := IssueAppAccountToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := offerSigner()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(offer.ProductId, offer.OfferId, appAccountToken)
+}
+
+// eligibleOffers applies the offer rules to a user's evaluated products. Apple only lets current or
+// former subscribers of a group redeem its promotional offers.
+func eligibleOffers(products []ProductEntitlement, promotionalOffers map[string]config.PromotionalOffer) *EligibleOffers {
+	offers := &EligibleOffers{Promotional: []EligibleOffer{}, WinBack: []EligibleOffer{}}
+	seen := map[string]bool{}
+	add := func(list *[]EligibleOffer, offer EligibleOffer) {
+		if !seen[offer.OfferId] {
+			seen[offer.OfferId] = true
+			*list = append(*list, offer)
+		}
+	}
+
+	subscribed := map[string]bool{}
+	active := map[string]bool{}
+	for _, product := range products {
+		if product.Source != EntitlementSourceSubscription {
+			continue
+		}
+		subscribed[product.SubscriptionGroupIdentifier] = true
+		if product.Active {
+			active[product.SubscriptionGroupIdentifier] = true
+		}
+
+		if product.Renewal == nil {
+			continue
+		}
+		productId := product.Renewal.AutoRenewProductId
+		if productId == "" {
+			productId = product.ProductId
+		}
+		for _, offerId := range product.Renewal.EligibleWinBackOfferIds {
+			add(&offers.WinBack, EligibleOffer{
+				OfferId:                     offerId,
+				Type:                        OfferTypeWinBack,
+				ProductId:                   productId,
+				SubscriptionGroupIdentifier: product.SubscriptionGroupIdentifier,
+			})
+		}
+	}
+
+	offerIds := make([]string, 0, len(promotionalOffers))
+	for offerId := range promotionalOffers {
+		offerIds = append(offerIds, offerId)
+	}
+	sort.Strings(offerIds)
+	for _, offerId := range offerIds {
+		rule := promotionalOffers[offerId]
+		if !subscribed[rule.SubscriptionGroupIdentifier] {
+			continue
+		}
+		if rule.LapsedOnly && active[rule.SubscriptionGroupIdentifier] {
+			continue
+		}
+		add(&offers.Promotional, EligibleOffer{
+			OfferId:                     offerId,
+			Type:                        OfferTypePromotional,
+			ProductId:                   rule.ProductId,
+			SubscriptionGroupIdentifier: rule.SubscriptionGroupIdentifier,
+		})
+	}
+	return offers
+}
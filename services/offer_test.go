@@ -0,0 +1,72 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/models"
+)
+
+func TestEligibleOffers(t *testing.T) {
+	promotionalOffers := map[string]config.PromotionalOffer{
+		"RETAIN50":  {ProductId: "pro.monthly", SubscriptionGroupIdentifier: "pro", LapsedOnly: true},
+		"UPGRADE20": {ProductId: "pro.yearly", SubscriptionGroupIdentifier: "pro"},
+		"TEAMTRIAL": {ProductId: "team.monthly", SubscriptionGroupIdentifier: "team"},
+	}
+
+	tests := []struct {
+		name        string
+		products    []ProductEntitlement
+		want        []string
+		wantWinBack []string
+	}{
+		{
+			name: "never subscribed",
+			products: []ProductEntitlement{
+				{ProductId: "themes", Source: EntitlementSourceNonConsumable, Active: true},
+			},
+			want:        []string{},
+			wantWinBack: []string{},
+		},
+		{
+			name: "active subscriber",
+			products: []ProductEntitlement{
+				{ProductId: "pro.monthly", Source: EntitlementSourceSubscription, SubscriptionGroupIdentifier: "pro", Active: true},
+			},
+			want:        []string{"UPGRADE20"},
+			wantWinBack: []string{},
+		},
+		{
+			name: "lapsed subscriber with a win-back offer",
+			products: []ProductEntitlement{
+				{
+					ProductId:                   "pro.monthly",
+					Source:                      EntitlementSourceSubscription,
+					SubscriptionGroupIdentifier: "pro",
+					Renewal:                     &models.RenewalSummary{EligibleWinBackOfferIds: []string{"WINBACK1"}},
+				},
+			},
+			want:        []string{"RETAIN50", "UPGRADE20"},
+			wantWinBack: []string{"WINBACK1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offers := eligibleOffers(tt.products, promotionalOffers)
+			got, gotWinBack := []string{}, []string{}
+			for _, offer := range offers.Promotional {
+				got = append(got, offer.OfferId)
+			}
+			for _, offer := range offers.WinBack {
+				gotWinBack = append(gotWinBack, offer.OfferId)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("eligibleOffers() promotional = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotWinBack, tt.wantWinBack) {
+				t.Errorf("eligibleOffers() win-back = %v, want %v", gotWinBack, tt.wantWinBack)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+
+	"simvizlab-backend/models"
+)
+
+// orderLookupValid is the OrderLookupStatus of a valid order ID; 1 means invalid
+// https://developer.apple.com/documentation/appstoreserverapi/orderlookupstatus
+const orderLookupValid = 0
+
+// OrderUser identifies the account a purchase is linked to
+type OrderUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// OrderPurchase is one verified in-app purchase of an order
+type OrderPurchase struct {
+	TransactionId         string         `json:"transactionId"`
+	OriginalTransactionId string         `json:"originalTransactionId"`
+	ProductId             string         `json:"productId"`
+	Type                  models.IAPType `json:"type,omitempty"`
+	PurchaseDate          int64          `json:"purchaseDate"`
+	ExpiresDate           int64          `json:"expiresDate,omitempty"`
+	Revoked               bool           `json:"revoked"`
+	User                  *OrderUser     `json:"user"`
+}
+
+// OrderLookup is the support-facing result of an invoice order ID lookup
+type OrderLookup struct {
+	OrderId     string             `json:"orderId"`
+	Status      string             `json:"status"`
+	StatusCode  int                `json:"statusCode"`
+	Environment models.Environment `json:"environment"`
+	Purchases   []OrderPurchase    `json:"purchases"`
+}
+
+// LookupOrder resolves an invoice order ID to its purchases and the users they belong to
+func LookupOrder(ctx context.Context, orderId string) (*OrderLookup, error) {
+	status, transactions, environment, err := Gateway().LookupOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OrderLookup{
+		OrderId:     orderId,
+		Status:      "invalid",
+		StatusCode:  status,
+		Environment: environment,
+		Purchases:   []OrderPurchase{},
+	}
+	if status == orderLookupValid {
+		result.Status = "valid"
+	}
+
+	for _, transaction := range transactions {
+		user, err := FindUserForTransaction(transaction)
+		if err != nil {
+			return nil, err
+		}
+		result.Purchases = append(result.Purchases, OrderPurchase{
+			TransactionId:         transaction.TransactionID,
+			OriginalTransactionId: transaction.OriginalTransactionId,
+			ProductId:             transaction.ProductID,
+			Type:                  transaction.Type,
+			PurchaseDate:          transaction.PurchaseDate,
+			ExpiresDate:           transaction.ExpiresDate,
+			Revoked:               transaction.RevocationDate > 0,
+			User:                  newOrderUser(user),
+		})
+	}
+	return result, nil
+}
+
+func newOrderUser(user *models.User) *OrderUser {
+	if user == nil {
+		return nil
+	}
+	return &OrderUser{ID: user.ID.Hex(), Username: user.Username, Email: user.Email}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// GetRefundHistory fetches a customer's refunded transactions from the App Store without storing them
+func GetRefundHistory(ctx context.Context, originalTransactionId string) ([]*models.RefundRecord, error) {
+	transactions, _, err := Gateway().GetRefundHistory(ctx, originalTransactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := make([]*models.RefundRecord, 0, len(transactions))
+	for _, transaction := range transactions {
+		refunds = append(refunds, newRefundRecord(transaction, models.RefundSourceHistory))
+	}
+	return refunds, nil
+}
+
+// SyncRefundHistory fetches a customer's refunded transactions from the App Store and stores them
+func SyncRefundHistory(ctx context.Context, originalTransactionId string) ([]*models.RefundRecord, error) {
+	refunds, err := GetRefundHistory(ctx, originalTransactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, refund := range refunds {
+		if err := mongoRepo.SaveRefund(refund); err != nil {
+			return nil, err
+		}
+	}
+	return refunds, nil
+}
+
+func newRefundRecord(transaction *models.JWSTransaction, source models.RefundSource) *models.RefundRecord {
+	return &models.RefundRecord{
+		TransactionId:         transaction.TransactionID,
+		OriginalTransactionId: transaction.OriginalTransactionId,
+		ProductId:             transaction.ProductID,
+		Type:                  transaction.Type,
+		Environment:           transaction.Environment,
+		RevocationDate:        transaction.RevocationDate,
+		RevocationReason:      transaction.RevocationReason,
+		Source:                source,
+		Transaction:           transaction,
+	}
+}
+
+// applyRefundNotification records a REFUND and marks the refund reversed on REFUND_REVERSED
+func applyRefundNotification(notification *models.AppStoreNotification) error {
+	transaction := notification.Transaction
+	if transaction == nil {
+		return nil
+	}
+
+	switch notification.NotificationType {
+	case models.NotificationTypeV2Refund:
+		return mongoRepo.SaveRefund(newRefundRecord(transaction, models.RefundSourceNotification))
+	case models.NotificationTypeV2RefundReversed:
+		found, err := mongoRepo.MarkRefundReversed(transaction.TransactionID, time.UnixMilli(notification.SignedDate))
+		if err != nil {
+			return err
+		}
+		if !found {
+			logger.Warnf("refund reversed for transaction %s that has no recorded refund", transaction.TransactionID)
+		}
+	}
+	return nil
+}
+
+// IsTransactionRevoked reports whether access granted by a transaction has been taken back,
+// either because Apple signed it with a revocation date or because we recorded a refund that was not reversed.
+func IsTransactionRevoked(transaction *models.JWSTransaction) (bool, error) {
+	if transaction.RevocationDate > 0 {
+		return true, nil
+	}
+	if transaction.TransactionID == "" {
+		return false, nil
+	}
+	return mongoRepo.HasActiveRefund(transaction.TransactionID)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRenewalExtension is returned when an extension request would be rejected by Apple
+var ErrInvalidRenewalExtension = errors.New("invalid renewal extension request")
+
+// maxExtendByDays is the longest extension Apple grants in one request
+const maxExtendByDays = 90
+
+// MassExtensionPollJob is the job state name of the mass extension poller
+const MassExtensionPollJob = "massExtensionPoll"
+
+const (
+	// massExtensionPollInterval is how often pending mass extensions are checked
+	massExtensionPollInterval = time.Minute
+	// massExtensionPollLease keeps other replicas from polling the same round, and expires before the next tick
+	massExtensionPollLease = massExtensionPollInterval - 10*time.Second
+)
+
+func validateRenewalExtension(extendByDays int32, reason models.ExtendReasonCode) error {
+	if extendByDays < 1 || extendByDays > maxExtendByDays {
+		return fmt.Errorf("%w: extendByDays must be between 1 and %d", ErrInvalidRenewalExtension, maxExtendByDays)
+	}
+	if reason < models.UndeclaredExtendReasonCode || reason > models.ServiceIssueOrOutage {
+		return fmt.Errorf("%w: extendReasonCode must be between %d and %d", ErrInvalidRenewalExtension, models.UndeclaredExtendReasonCode, models.ServiceIssueOrOutage)
+	}
+	return nil
+}
+
+// ExtendSubscription extends the renewal date of one subscription. The request is stored under a
+// new requestIdentifier before Apple is called, so a failed call is still on record.
+func ExtendSubscription(ctx context.Context, originalTransactionId string, extendByDays int32, reason models.ExtendReasonCode) (*models.RenewalExtension, error) {
+	if err := validateRenewalExtension(extendByDays, reason); err != nil {
+		return nil, err
+	}
+
+	extension := &models.RenewalExtension{
+		RequestIdentifier:     uuid.NewString(),
+		Kind:                  models.RenewalExtensionSingle,
+		Environment:           Gateway().Environment(),
+		OriginalTransactionId: originalTransactionId,
+		ExtendByDays:          extendByDays,
+		ExtendReasonCode:      reason,
+		Status:                models.RenewalExtensionPending,
+	}
+	if err := mongoRepo.SaveRenewalExtension(extension); err != nil {
+		return nil, err
+	}
+
+	rsp, environment, err := Gateway().ExtendRenewalDate(ctx, originalTransactionId, models.ExtendRenewalDateRequest{
+		ExtendByDays:      extendByDays,
+		ExtendReasonCode:  reason,
+		RequestIdentifier: extension.RequestIdentifier,
+	})
+	extension.Environment = environment
+	switch {
+	case err != nil:
+		extension.Status = models.RenewalExtensionFailed
+		extension.LastError = AppStoreErrorMessage(err)
+	case rsp.Success:
+		extension.Status = models.RenewalExtensionSucceeded
+		extension.EffectiveDate = rsp.EffectiveDate
+		extension.WebOrderLineItemId = rsp.WebOrderLineItemId
+		extension.SucceededCount = 1
+	default:
+		extension.Status = models.RenewalExtensionFailed
+		extension.FailedCount = 1
+	}
+	if saveErr := mongoRepo.SaveRenewalExtension(extension); saveErr != nil {
+		return nil, saveErr
+	}
+	return extension, err
+}
+
+// MassExtendSubscriptions asks Apple to extend the renewal date of every active subscriber of a product,
+// optionally limited to storefronts. Apple processes the request asynchronously; its progress is
+// tracked by the mass extension poller.
+func MassExtendSubscriptions(ctx context.Context, productId string, storefrontCountryCodes []string, extendByDays int32, reason models.ExtendReasonCode) (*models.RenewalExtension, error) {
+	if err := validateRenewalExtension(extendByDays, reason); err != nil {
+		return nil, err
+	}
+	if productId == "" {
+		return nil, fmt.Errorf("%w: productId is required", ErrInvalidRenewalExtension)
+	}
+	if storefrontCountryCodes != nil && len(storefrontCountryCodes) == 0 {
+		return nil, fmt.Errorf("%w: storefrontCountryCodes must not be empty when provided", ErrInvalidRenewalExtension)
+	}
+
+	extension := &models.RenewalExtension{
+		RequestIdentifier:      uuid.NewString(),
+		Kind:                   models.RenewalExtensionMass,
+		Environment:            Gateway().Environment(),
+		ProductId:              productId,
+		StorefrontCountryCodes: storefrontCountryCodes,
+		ExtendByDays:           extendByDays,
+		ExtendReasonCode:       reason,
+		Status:                 models.RenewalExtensionPending,
+	}
+	if err := mongoRepo.SaveRenewalExtension(extension); err != nil {
+		return nil, err
+	}
+
+	err := Gateway().MassExtendRenewalDate(ctx, models.MassExtendRenewalDateRequest{
+		RequestIdentifier:      extension.RequestIdentifier,
+		ExtendByDays:           extendByDays,
+		ExtendReasonCode:       int32(reason),
+		ProductId:              productId,
+		StorefrontCountryCodes: storefrontCountryCodes,
+	})
+	if err != nil {
+		extension.Status = models.RenewalExtensionFailed
+		extension.LastError = AppStoreErrorMessage(err)
+		if saveErr := mongoRepo.SaveRenewalExtension(extension); saveErr != nil {
+			return nil, saveErr
+		}
+		return extension, err
+	}
+	return extension, nil
+}
+
+// RefreshMassExtension fetches the progress of a mass extension from Apple and stores its counts
+func RefreshMassExtension(ctx context.Context, extension *models.RenewalExtension) error {
+	rsp, err := Gateway().GetMassExtensionStatus(ctx, extension.Environment, extension.ProductId, extension.RequestIdentifier)
+	now := time.Now()
+	extension.LastPolledAt = &now
+	if err != nil {
+		extension.LastError = AppStoreErrorMessage(err)
+		if saveErr := mongoRepo.SaveRenewalExtension(extension); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	extension.LastError = ""
+	extension.SucceededCount = rsp.SucceededCount
+	extension.FailedCount = rsp.FailedCount
+	if rsp.Complete {
+		extension.Status = models.RenewalExtensionComplete
+		extension.CompleteDate = rsp.CompleteDate
+	}
+	return mongoRepo.SaveRenewalExtension(extension)
+}
+
+// StartMassExtensionPoller polls pending mass extensions until Apple reports them complete.
+// One replica polls each round, whichever claims the job first. It runs until ctx is cancelled.
+func StartMassExtensionPoller(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(massExtensionPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pollMassExtensions(ctx)
+			}
+		}
+	}()
+}
+
+func pollMassExtensions(ctx context.Context) {
+	_, claimed, err := mongoRepo.ClaimJob(MassExtensionPollJob, instanceID, massExtensionPollLease)
+	if err != nil {
+		logger.Errorf("failed to claim mass extension poll: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	ranAt := time.Now()
+	runErr := refreshPendingMassExtensions(ctx)
+	if err := mongoRepo.RecordJobRun(MassExtensionPollJob, instanceID, ranAt.UnixMilli(), runErr); err != nil {
+		logger.Errorf("failed to record mass extension poll run: %v", err)
+	}
+}
+
+func refreshPendingMassExtensions(ctx context.Context) error {
+	extensions, err := mongoRepo.GetPendingMassExtensions()
+	if err != nil {
+		logger.Errorf("failed to load pending mass extensions: %v", err)
+		return err
+	}
+	for _, extension := range extensions {
+		if err := RefreshMassExtension(ctx, extension); err != nil {
+			logger.Warnf("failed to refresh mass extension %s: %v", extension.RequestIdentifier, err)
+			continue
+		}
+		if extension.Status == models.RenewalExtensionComplete {
+			logger.Infof("mass extension %s of %s complete: %d succeeded, %d failed",
+				extension.RequestIdentifier, extension.ProductId, extension.SucceededCount, extension.FailedCount)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"simvizlab-backend/models"
+)
+
+func TestValidateRenewalExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    int32
+		reason  models.ExtendReasonCode
+		wantErr error
+	}{
+		{name: "outage", days: 7, reason: models.ServiceIssueOrOutage},
+		{name: "maximum days", days: maxExtendByDays, reason: models.CustomerSatisfaction},
+		{name: "zero days", days: 0, reason: models.OtherReasons, wantErr: ErrInvalidRenewalExtension},
+		{name: "too many days", days: maxExtendByDays + 1, reason: models.OtherReasons, wantErr: ErrInvalidRenewalExtension},
+		{name: "unknown reason", days: 7, reason: 9, wantErr: ErrInvalidRenewalExtension},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRenewalExtension(tt.days, tt.reason); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateRenewalExtension() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// ErrInvalidReportRange is returned for a report date range that cannot be served
+var ErrInvalidReportRange = errors.New("invalid report date range")
+
+const (
+	// reportDayLayout is the format of report date parameters and of the days reports group by
+	reportDayLayout = "2006-01-02"
+	// defaultReportDays is the range covered when no start date is given
+	defaultReportDays = 30
+	// maxReportDays bounds the range a single report scans
+	maxReportDays = 366
+)
+
+// ReportRange selects the UTC days a report covers, both ends inclusive, and the App Store environment
+type ReportRange struct {
+	StartDate   string             `json:"startDate"`
+	EndDate     string             `json:"endDate"`
+	Environment models.Environment `json:"environment"`
+
+	start time.Time
+	end   time.Time
+}
+
+// ParseReportRange reads a YYYY-MM-DD start and end date. The end date defaults to today and the
+// start date to the 30 days ending on the end date. An empty environment means the gateway's.
+func ParseReportRange(startDate, endDate, environment string, now time.Time) (ReportRange, error) {
+	end := now.UTC().Truncate(24 * time.Hour)
+	if endDate != "" {
+		parsed, err := time.Parse(reportDayLayout, endDate)
+		if err != nil {
+			return ReportRange{}, fmt.Errorf("%w: endDate must be YYYY-MM-DD", ErrInvalidReportRange)
+		}
+		end = parsed
+	}
+
+	start := end.AddDate(0, 0, 1-defaultReportDays)
+	if startDate != "" {
+		parsed, err := time.Parse(reportDayLayout, startDate)
+		if err != nil {
+			return ReportRange{}, fmt.Errorf("%w: startDate must be YYYY-MM-DD", ErrInvalidReportRange)
+		}
+		start = parsed
+	}
+
+	if end.Before(start) {
+		return ReportRange{}, fmt.Errorf("%w: startDate is after endDate", ErrInvalidReportRange)
+	}
+	if days := int(end.Sub(start).Hours()/24) + 1; days > maxReportDays {
+		return ReportRange{}, fmt.Errorf("%w: at most %d days can be reported at once", ErrInvalidReportRange, maxReportDays)
+	}
+
+	env := models.Environment(environment)
+	switch env {
+	case "":
+		env = Gateway().Environment()
+	case models.Production, models.Sandbox:
+	default:
+		return ReportRange{}, fmt.Errorf("%w: unknown environment %q", ErrInvalidReportRange, environment)
+	}
+
+	return ReportRange{
+		StartDate:   start.Format(reportDayLayout),
+		EndDate:     end.Format(reportDayLayout),
+		Environment: env,
+		start:       start,
+		end:         end.AddDate(0, 0, 1),
+	}, nil
+}
+
+// bounds returns the range as epoch milliseconds, the end exclusive
+func (r ReportRange) bounds() (int64, int64) {
+	return r.start.UnixMilli(), r.end.UnixMilli()
+}
+
+// days lists every day of the range
+func (r ReportRange) days() []string {
+	days := []string{}
+	for day := r.start; day.Before(r.end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(reportDayLayout))
+	}
+	return days
+}
+
+// ActiveSubscribersReport counts the subscriptions with access at the end of the range, or now when that is earlier
+type ActiveSubscribersReport struct {
+	ReportRange
+	AsOf     int64                           `json:"asOf"`
+	Total    int64                           `json:"total"`
+	Products []*models.ActiveSubscriberCount `json:"products"`
+}
+
+// GetActiveSubscribersReport counts the active subscribers of each product
+func GetActiveSubscribersReport(r ReportRange, now time.Time) (*ActiveSubscribersReport, error) {
+	_, end := r.bounds()
+	asOf := min(end-1, now.UnixMilli())
+
+	counts, err := mongoRepo.GetActiveSubscriberCounts(r.Environment, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ActiveSubscribersReport{ReportRange: r, AsOf: asOf, Products: counts}
+	for _, count := range counts {
+		report.Total += count.Subscribers
+	}
+	return report, nil
+}
+
+// DailyActivityReport lists, for every day of the range, the new, renewing and churned subscriptions
+type DailyActivityReport struct {
+	ReportRange
+	Days []*models.DailyActivity `json:"days"`
+}
+
+// GetDailyActivityReport counts subscription purchases, renewals and lapses per day.
+// Churn is only counted up to now, as a subscription expiring later may still renew.
+func GetDailyActivityReport(r ReportRange, now time.Time) (*DailyActivityReport, error) {
+	start, end := r.bounds()
+	purchases, err := mongoRepo.GetDailyPurchaseCounts(r.Environment, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	churn := []*models.DailyActivity{}
+	if churnEnd := min(end, now.UnixMilli()); churnEnd > start {
+		churn, err = mongoRepo.GetDailyChurnCounts(r.Environment, start, churnEnd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &DailyActivityReport{ReportRange: r, Days: mergeDailyActivity(r.days(), purchases, churn)}, nil
+}
+
+// mergeDailyActivity combines the purchase and churn counts into one entry per day, zero-filling days without activity
+func mergeDailyActivity(days []string, purchases, churn []*models.DailyActivity) []*models.DailyActivity {
+	byDay := make(map[string]*models.DailyActivity, len(days))
+	merged := make([]*models.DailyActivity, 0, len(days))
+	for _, day := range days {
+		activity := &models.DailyActivity{Day: day}
+		byDay[day] = activity
+		merged = append(merged, activity)
+	}
+
+	for _, p := range purchases {
+		if activity, ok := byDay[p.Day]; ok {
+			activity.New += p.New
+			activity.Renewing += p.Renewing
+		}
+	}
+	for _, c := range churn {
+		if activity, ok := byDay[c.Day]; ok {
+			activity.Churned += c.Churned
+		}
+	}
+	return merged
+}
+
+// TrialConversionReport counts the free trials started in the range and how many converted to a paid period
+type TrialConversionReport struct {
+	ReportRange
+	Trials    int64                     `json:"trials"`
+	Converted int64                     `json:"converted"`
+	Rate      float64                   `json:"rate"`
+	Products  []*models.TrialConversion `json:"products"`
+}
+
+// GetTrialConversionReport counts trial-to-paid conversion per product.
+// Trials are told apart by their FREE_TRIAL offerDiscountType.
+func GetTrialConversionReport(r ReportRange) (*TrialConversionReport, error) {
+	start, end := r.bounds()
+	conversions, err := mongoRepo.GetTrialConversions(r.Environment, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TrialConversionReport{ReportRange: r, Products: conversions}
+	for _, conversion := range conversions {
+		conversion.Rate = conversionRate(conversion.Converted, conversion.Trials)
+		report.Trials += conversion.Trials
+		report.Converted += conversion.Converted
+	}
+	report.Rate = conversionRate(report.Converted, report.Trials)
+	return report, nil
+}
+
+func conversionRate(converted, trials int64) float64 {
+	if trials == 0 {
+		return 0
+	}
+	return float64(converted) / float64(trials)
+}
+
+// RevenueReport is the revenue of the range by currency and storefront, with every amount also converted
+// to the reporting currency at the exchange rate of its purchase day. MissingRates lists the currencies
+// left out of the converted amounts for lack of a rate, and MissingTaxRates the storefronts whose prices
+// were taken as tax-free for lack of a tax rate. Refunded is gross and left out of the proceeds.
+type RevenueReport struct {
+	ReportRange
+	ReportingCurrency string                 `json:"reportingCurrency"`
+	Revenue           models.Money           `json:"revenue"`
+	Refunded          models.Money           `json:"refunded"`
+	Tax               models.Money           `json:"tax"`
+	Commission        models.Money           `json:"commission"`
+	Proceeds          models.Money           `json:"proceeds"`
+	Totals            []*models.RevenueTotal `json:"totals"`
+	MissingRates      []string               `json:"missingRates,omitempty"`
+	MissingTaxRates   []string               `json:"missingTaxRates,omitempty"`
+}
+
+// GetRevenueReport sums transaction prices by currency and storefront and normalizes them to the reporting currency.
+// The tax included in storefront prices is deducted before Apple's commission and proceeds.
+func GetRevenueReport(r ReportRange) (*RevenueReport, error) {
+	currency, err := config.ReportingCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := r.bounds()
+	buckets, err := mongoRepo.GetRevenueBuckets(r.Environment, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RevenueReport{ReportRange: r}
+	if err := summarizeRevenue(report, buckets, CurrentExchangeRates(), CurrentTaxRates(), currency, config.AppStoreSmallBusinessProgram()); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestParseReportRange(t *testing.T) {
+	now := time.Date(2024, 3, 15, 13, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		startDate string
+		endDate   string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{name: "defaults to the last 30 days", wantStart: "2024-02-15", wantEnd: "2024-03-15"},
+		{name: "start only", startDate: "2024-03-01", wantStart: "2024-03-01", wantEnd: "2024-03-15"},
+		{name: "end only", endDate: "2024-01-31", wantStart: "2024-01-02", wantEnd: "2024-01-31"},
+		{name: "single day", startDate: "2024-03-10", endDate: "2024-03-10", wantStart: "2024-03-10", wantEnd: "2024-03-10"},
+		{name: "start after end", startDate: "2024-03-11", endDate: "2024-03-10", wantErr: true},
+		{name: "not a date", startDate: "1710460800000", wantErr: true},
+		{name: "longer than a year", startDate: "2022-01-01", endDate: "2024-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseReportRange(tt.startDate, tt.endDate, string(models.Production), now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReportRange) {
+					t.Fatalf("ParseReportRange() error = %v, want ErrInvalidReportRange", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReportRange() error = %v", err)
+			}
+			if r.StartDate != tt.wantStart || r.EndDate != tt.wantEnd {
+				t.Errorf("ParseReportRange() = %s..%s, want %s..%s", r.StartDate, r.EndDate, tt.wantStart, tt.wantEnd)
+			}
+			days := r.days()
+			if days[0] != tt.wantStart || days[len(days)-1] != tt.wantEnd {
+				t.Errorf("days() = %s..%s, want %s..%s", days[0], days[len(days)-1], tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	if _, err := ParseReportRange("", "", "Staging", now); !errors.Is(err, ErrInvalidReportRange) {
+		t.Errorf("ParseReportRange() with unknown environment error = %v, want ErrInvalidReportRange", err)
+	}
+}
+
+func TestMergeDailyActivity(t *testing.T) {
+	days := []string{"2024-03-01", "2024-03-02", "2024-03-03"}
+	purchases := []*models.DailyActivity{
+		{Day: "2024-03-01", New: 2, Renewing: 5},
+		{Day: "2024-03-03", New: 1},
+	}
+	churn := []*models.DailyActivity{
+		{Day: "2024-03-02", Churned: 3},
+		{Day: "2024-04-01", Churned: 9},
+	}
+
+	got := mergeDailyActivity(days, purchases, churn)
+	want := []*models.DailyActivity{
+		{Day: "2024-03-01", New: 2, Renewing: 5},
+		{Day: "2024-03-02", Churned: 3},
+		{Day: "2024-03-03", New: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeDailyActivity() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+// Apple's commission on the price of a sale,
+// https://developer.apple.com/app-store/small-business-program/
+const (
+	standardCommission = 0.30
+	// reducedCommission applies to subscriptions after a year of paid service and to every sale
+	// of developers in the Small Business Program
+	reducedCommission = 0.15
+)
+
+// commissionRate returns the share of a sale Apple keeps
+func commissionRate(reduced, smallBusiness bool) float64 {
+	if reduced || smallBusiness {
+		return reducedCommission
+	}
+	return standardCommission
+}
+
+// summarizeRevenue fills report with the totals of buckets per currency and storefront, converting each
+// bucket to currency at the rate of its day, taking out the tax of its storefront and deducting the
+// commission of its tier from the price before tax
+func summarizeRevenue(report *RevenueReport, buckets []*models.RevenueBucket, rates *ExchangeRates, taxes *TaxRates, currency string, smallBusiness bool) error {
+	report.ReportingCurrency = currency
+	report.Revenue = models.NewMoney(0, currency)
+	report.Refunded = models.NewMoney(0, currency)
+	report.Tax = models.NewMoney(0, currency)
+	report.Commission = models.NewMoney(0, currency)
+	report.Proceeds = models.NewMoney(0, currency)
+	report.Totals = []*models.RevenueTotal{}
+
+	type totalKey struct{ currency, storefront string }
+	totals := map[totalKey]*models.RevenueTotal{}
+	missing := map[string]bool{}
+	missingTax := map[string]bool{}
+
+	var sumErr error
+	add := func(sum *models.Money, amount models.Money) {
+		if sumErr == nil {
+			*sum, sumErr = sum.Add(amount)
+		}
+	}
+
+	for _, bucket := range buckets {
+		key := totalKey{bucket.Currency, bucket.Storefront}
+		total, ok := totals[key]
+		if !ok {
+			total = &models.RevenueTotal{
+				Currency:          bucket.Currency,
+				Storefront:        bucket.Storefront,
+				Revenue:           models.NewMoney(0, bucket.Currency),
+				Refunded:          models.NewMoney(0, bucket.Currency),
+				ReportingRevenue:  models.NewMoney(0, currency),
+				ReportingRefunded: models.NewMoney(0, currency),
+				Tax:               models.NewMoney(0, currency),
+				Commission:        models.NewMoney(0, currency),
+				Proceeds:          models.NewMoney(0, currency),
+			}
+			totals[key] = total
+			report.Totals = append(report.Totals, total)
+		}
+
+		revenue := models.NewMoney(bucket.Revenue, bucket.Currency)
+		refunded := models.NewMoney(bucket.Refunded, bucket.Currency)
+		total.Transactions += bucket.Transactions
+		add(&total.Revenue, revenue)
+		add(&total.Refunded, refunded)
+
+		day, err := time.Parse(reportDayLayout, bucket.Day)
+		if err != nil {
+			return fmt.Errorf("invalid revenue day %q: %w", bucket.Day, err)
+		}
+		rate, ok := rates.Rate(bucket.Currency, currency, day)
+		if !ok {
+			missing[bucket.Currency] = true
+			continue
+		}
+		taxRate, ok := taxes.Rate(bucket.Storefront)
+		if !ok {
+			missingTax[bucket.Storefront] = true
+		}
+
+		converted := revenue.Convert(currency, rate)
+		beforeTax := converted.Scale(1 / (1 + taxRate))
+		commission := beforeTax.Scale(commissionRate(bucket.ReducedCommission, smallBusiness))
+		add(&total.ReportingRevenue, converted)
+		add(&total.ReportingRefunded, refunded.Convert(currency, rate))
+		add(&total.Tax, models.NewMoney(converted.Milliunits-beforeTax.Milliunits, currency))
+		add(&total.Commission, commission)
+		add(&total.Proceeds, models.NewMoney(beforeTax.Milliunits-commission.Milliunits, currency))
+	}
+
+	for _, total := range report.Totals {
+		add(&report.Revenue, total.ReportingRevenue)
+		add(&report.Refunded, total.ReportingRefunded)
+		add(&report.Tax, total.Tax)
+		add(&report.Commission, total.Commission)
+		add(&report.Proceeds, total.Proceeds)
+	}
+	if sumErr != nil {
+		return sumErr
+	}
+
+	for code := range missing {
+		report.MissingRates = append(report.MissingRates, code)
+	}
+	sort.Strings(report.MissingRates)
+	for storefront := range missingTax {
+		report.MissingTaxRates = append(report.MissingTaxRates, storefront)
+	}
+	sort.Strings(report.MissingTaxRates)
+	return nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"simvizlab-backend/models"
+)
+
+func TestSummarizeRevenue(t *testing.T) {
+	rates := NewExchangeRates([]*models.ExchangeRate{
+		{Date: "2024-03-01", Base: "USD", Currency: "EUR", Rate: 0.8},
+	})
+	taxes := NewTaxRates([]*models.TaxRate{
+		{Storefront: "DEU", Rate: 0.25},
+		{Storefront: "USA", Rate: 0},
+	})
+	buckets := []*models.RevenueBucket{
+		{Day: "2024-03-01", Currency: "EUR", Storefront: "DEU", Transactions: 2, Revenue: 8000, Refunded: 4000},
+		{Day: "2024-03-02", Currency: "EUR", Storefront: "DEU", ReducedCommission: true, Transactions: 1, Revenue: 8000},
+		{Day: "2024-03-01", Currency: "USD", Storefront: "USA", Transactions: 1, Revenue: 9990},
+		{Day: "2024-03-01", Currency: "JPY", Storefront: "JPN", Transactions: 1, Revenue: 500000},
+	}
+
+	report := &RevenueReport{}
+	if err := summarizeRevenue(report, buckets, rates, taxes, "USD", false); err != nil {
+		t.Fatalf("summarizeRevenue() error = %v", err)
+	}
+
+	deu := report.Totals[0]
+	if deu.Transactions != 3 || deu.Revenue != models.NewMoney(16000, "EUR") || deu.Refunded != models.NewMoney(4000, "EUR") {
+		t.Errorf("DEU local totals = %+v", deu)
+	}
+	// 8.00 EUR at 30% and 8.00 EUR at 15%, each worth 10.00 USD of which 2.00 USD is tax
+	if deu.ReportingRevenue != models.NewMoney(20000, "USD") || deu.Tax != models.NewMoney(4000, "USD") {
+		t.Errorf("DEU reporting totals = %+v", deu)
+	}
+	if deu.Commission != models.NewMoney(3600, "USD") || deu.Proceeds != models.NewMoney(12400, "USD") {
+		t.Errorf("DEU commission %v and proceeds %v, want 3.60 USD and 12.40 USD", deu.Commission, deu.Proceeds)
+	}
+	if deu.ReportingRefunded != models.NewMoney(5000, "USD") {
+		t.Errorf("DEU refunded = %v, want 5.00 USD", deu.ReportingRefunded)
+	}
+
+	if report.Revenue != models.NewMoney(29990, "USD") || report.Tax != models.NewMoney(4000, "USD") {
+		t.Errorf("report revenue %v and tax %v", report.Revenue, report.Tax)
+	}
+	if report.Commission != models.NewMoney(6597, "USD") || report.Proceeds != models.NewMoney(19393, "USD") {
+		t.Errorf("report commission %v and proceeds %v", report.Commission, report.Proceeds)
+	}
+	if !reflect.DeepEqual(report.MissingRates, []string{"JPY"}) || report.MissingTaxRates != nil {
+		t.Errorf("MissingRates = %v and MissingTaxRates = %v, want [JPY] and none", report.MissingRates, report.MissingTaxRates)
+	}
+
+	report = &RevenueReport{}
+	if err := summarizeRevenue(report, buckets[2:3], rates, NewTaxRates(nil), "USD", true); err != nil {
+		t.Fatal(err)
+	}
+	if report.Commission != models.NewMoney(1499, "USD") {
+		t.Errorf("small business commission = %v, want 1.499 USD", report.Commission)
+	}
+	if !reflect.DeepEqual(report.MissingTaxRates, []string{"USA"}) {
+		t.Errorf("MissingTaxRates = %v, want [USA]", report.MissingTaxRates)
+	}
+
+	// a refund is reported gross and leaves the proceeds of the sale as they were
+	refunded := []*models.RevenueBucket{{Day: "2024-03-01", Currency: "USD", Storefront: "USA", Transactions: 1, Revenue: 9990, Refunded: 9990}}
+	report = &RevenueReport{}
+	if err := summarizeRevenue(report, refunded, rates, taxes, "USD", false); err != nil {
+		t.Fatal(err)
+	}
+	if report.Refunded != models.NewMoney(9990, "USD") || report.Revenue != models.NewMoney(9990, "USD") {
+		t.Errorf("refunded %v and revenue %v, want 9.99 USD each", report.Refunded, report.Revenue)
+	}
+	if report.Proceeds != models.NewMoney(6993, "USD") {
+		t.Errorf("proceeds with a refund = %v, want 6.993 USD", report.Proceeds)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"simvizlab-backend/infra/logger"
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidSubscriptionTransition is returned when a notification does not apply to the current state
+var ErrInvalidSubscriptionTransition = errors.New("invalid subscription state transition")
+
+// errNoSubscriptionTransition marks notifications that do not change the subscription state
+var errNoSubscriptionTransition = errors.New("notification does not change subscription state")
+
+// anySubscriptionState allows a transition from every state, including a subscription we have not seen yet
+var anySubscriptionState = []models.SubscriptionState{
+	models.SubscriptionStateNone,
+	models.SubscriptionStateActive,
+	models.SubscriptionStateGracePeriod,
+	models.SubscriptionStateBillingRetry,
+	models.SubscriptionStateExpired,
+	models.SubscriptionStateRefunded,
+	models.SubscriptionStateRevoked,
+}
+
+// renewingSubscriptionStates are the states in which the App Store is still trying to renew
+var renewingSubscriptionStates = []models.SubscriptionState{
+	models.SubscriptionStateActive,
+	models.SubscriptionStateGracePeriod,
+	models.SubscriptionStateBillingRetry,
+}
+
+// NextSubscriptionState returns the state a subscription moves to when a notification is applied.
+// It returns errNoSubscriptionTransition for informational notifications and
+// ErrInvalidSubscriptionTransition when the notification is not valid in the current state.
+func NextSubscriptionState(current models.SubscriptionState, notificationType models.NotificationTypeV2, subtype models.SubtypeV2) (models.SubscriptionState, error) {
+	var next models.SubscriptionState
+	var from []models.SubscriptionState
+
+	switch notificationType {
+	case models.NotificationTypeV2Subscribed:
+		next, from = models.SubscriptionStateActive, anySubscriptionState
+	case models.NotificationTypeV2DidRenew:
+		// BILLING_RECOVERY renews a subscription that already expired after billing retry
+		next, from = models.SubscriptionStateActive, append(renewingSubscriptionStates, models.SubscriptionStateNone, models.SubscriptionStateExpired)
+	case models.NotificationTypeV2DidFailToRenew:
+		next = models.SubscriptionStateBillingRetry
+		if subtype == models.SubTypeV2GracePeriod {
+			next = models.SubscriptionStateGracePeriod
+		}
+		from = append(renewingSubscriptionStates, models.SubscriptionStateNone)
+	case models.NotificationTypeV2GracePeriodExpired:
+		next, from = models.SubscriptionStateBillingRetry, []models.SubscriptionState{models.SubscriptionStateNone, models.SubscriptionStateGracePeriod, models.SubscriptionStateBillingRetry}
+	case models.NotificationTypeV2Expired:
+		next, from = models.SubscriptionStateExpired, append(renewingSubscriptionStates, models.SubscriptionStateNone, models.SubscriptionStateExpired)
+	case models.NotificationTypeV2RenewalExtended:
+		next, from = models.SubscriptionStateActive, append(renewingSubscriptionStates, models.SubscriptionStateNone)
+	case models.NotificationTypeV2Refund:
+		next, from = models.SubscriptionStateRefunded, anySubscriptionState
+	case models.NotificationTypeV2Revoke:
+		next, from = models.SubscriptionStateRevoked, anySubscriptionState
+	case models.NotificationTypeV2RefundReversed:
+		// ApplySubscriptionTransition restores the state held before the refund
+		next, from = models.SubscriptionStateActive, []models.SubscriptionState{models.SubscriptionStateRefunded}
+	default:
+		// PRICE_INCREASE, DID_CHANGE_RENEWAL_PREF, DID_CHANGE_RENEWAL_STATUS, OFFER_REDEEMED,
+		// REFUND_DECLINED, CONSUMPTION_REQUEST, RENEWAL_EXTENSION and TEST leave the state as is
+		return current, errNoSubscriptionTransition
+	}
+
+	for _, state := range from {
+		if state == current {
+			return next, nil
+		}
+	}
+	return current, fmt.Errorf("%w: %s(%s) from %q", ErrInvalidSubscriptionTransition, notificationType, subtype, current)
+}
+
+// ApplySubscriptionTransition applies a notification to an entitlement in memory, recording
+// the change in its history. Notifications signed before the last applied one are ignored.
+func ApplySubscriptionTransition(entitlement *models.SubscriptionEntitlement, notification *models.AppStoreNotification, now time.Time) (bool, error) {
+	if notification.SignedDate < entitlement.LastEventDate {
+		return false, nil
+	}
+
+	next, err := NextSubscriptionState(entitlement.State, notification.NotificationType, notification.Subtype)
+	if err != nil && !errors.Is(err, errNoSubscriptionTransition) {
+		return false, err
+	}
+	if err == nil && notification.NotificationType == models.NotificationTypeV2RefundReversed {
+		next = stateBeforeRefund(entitlement, now)
+	}
+
+	if tx := notification.Transaction; tx != nil {
+		if tx.ProductID != "" {
+			entitlement.ProductId = tx.ProductID
+		}
+		if tx.SubscriptionGroupIdentifier != "" {
+			entitlement.SubscriptionGroupIdentifier = tx.SubscriptionGroupIdentifier
+		}
+		if tx.ExpiresDate > 0 {
+			entitlement.ExpiresDate = tx.ExpiresDate
+		}
+	}
+	if notification.BundleId != "" {
+		entitlement.BundleId = notification.BundleId
+	}
+	if notification.Environment != "" {
+		entitlement.Environment = notification.Environment
+	}
+	entitlement.LastNotificationUUID = notification.NotificationUUID
+	entitlement.LastEventDate = notification.SignedDate
+	entitlement.UpdatedAt = now
+
+	if next == entitlement.State {
+		return false, nil
+	}
+
+	entitlement.History = append(entitlement.History, models.SubscriptionTransition{
+		From:             entitlement.State,
+		To:               next,
+		NotificationType: notification.NotificationType,
+		Subtype:          notification.Subtype,
+		NotificationUUID: notification.NotificationUUID,
+		EventDate:        notification.SignedDate,
+		TransitionedAt:   now,
+	})
+	entitlement.State = next
+	return true, nil
+}
+
+// stateBeforeRefund finds the state a refunded subscription was in, expiring it if its period ended meanwhile
+func stateBeforeRefund(entitlement *models.SubscriptionEntitlement, now time.Time) models.SubscriptionState {
+	restored := models.SubscriptionStateActive
+	for i := len(entitlement.History) - 1; i >= 0; i-- {
+		if entitlement.History[i].To == models.SubscriptionStateRefunded {
+			if from := entitlement.History[i].From; from != models.SubscriptionStateNone {
+				restored = from
+			}
+			break
+		}
+	}
+	if restored.HasAccess() && entitlement.ExpiresDate > 0 && entitlement.ExpiresDate < now.UnixMilli() {
+		return models.SubscriptionStateExpired
+	}
+	return restored
+}
+
+// maxEntitlementWriteAttempts bounds retries when two notifications for one subscription race
+const maxEntitlementWriteAttempts = 3
+
+// applySubscriptionNotification moves the stored entitlement of the notified subscription through
+// the state machine and mirrors the result onto the transactionApple record.
+func applySubscriptionNotification(notification *models.AppStoreNotification) error {
+	if notification.OriginalTransactionId == "" {
+		return nil
+	}
+	// Refunds and one-time charges also arrive for consumables and non-consumables
+	if tx := notification.Transaction; tx != nil && tx.Type != "" && tx.Type != models.AutoRenewable {
+		return nil
+	}
+
+	// Kept for the reports, which aggregate over every stored subscription transaction
+	if notification.Transaction != nil && notification.Transaction.TransactionID != "" {
+		if err := mongoRepo.SaveTransaction(notification.Transaction); err != nil {
+			return fmt.Errorf("failed to save transaction: %w", err)
+		}
+	}
+
+	// DID_CHANGE_RENEWAL_STATUS, PRICE_INCREASE and the like only change the renewal info
+	if notification.RenewalInfo != nil {
+		if err := mongoRepo.UpdateTransactionAppleRenewal(notification.OriginalTransactionId, models.NewRenewalSummary(notification.RenewalInfo)); err != nil {
+			return fmt.Errorf("failed to update transactionApple renewal: %w", err)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		entitlement, err := mongoRepo.GetSubscriptionEntitlement(notification.OriginalTransactionId)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			entitlement = &models.SubscriptionEntitlement{
+				OriginalTransactionId: notification.OriginalTransactionId,
+				CreatedAt:             time.Now(),
+			}
+		} else if err != nil {
+			return err
+		}
+
+		changed, err := ApplySubscriptionTransition(entitlement, notification, time.Now())
+		if errors.Is(err, ErrInvalidSubscriptionTransition) {
+			// Apple will not send a different notification, so retrying cannot fix this
+			logger.Warnf("ignoring notification %s: %v", notification.NotificationUUID, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = mongoRepo.SaveSubscriptionEntitlement(entitlement)
+		if errors.Is(err, mongoRepo.ErrEntitlementConflict) && attempt < maxEntitlementWriteAttempts {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			return syncTransactionAppleStatus(entitlement)
+		}
+		return nil
+	}
+}
+
+// syncTransactionAppleStatus keeps the status returned by /user/login-status in line with the entitlement
+func syncTransactionAppleStatus(entitlement *models.SubscriptionEntitlement) error {
+	status := entitlement.State.AppleStatus()
+	err := mongoRepo.Update(
+		"transactionApple",
+		bson.M{"originalTransactionId": entitlement.OriginalTransactionId},
+		bson.M{"status": status, "statusText": models.StatusText(status), "updatedAt": entitlement.UpdatedAt},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update transactionApple status: %w", err)
+	}
+	return nil
+}
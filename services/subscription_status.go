@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"simvizlab-backend/models"
+	"simvizlab-backend/utils"
+)
+
+// ErrUnverifiedSubscriptionStatus is returned when a signed transaction or renewal info Apple sent fails verification
+var ErrUnverifiedSubscriptionStatus = errors.New("failed to verify subscription status from apple")
+
+// SubscriptionGroupStatus is the status of one subscription group, taken from the subscription in it
+// that grants the most access
+type SubscriptionGroupStatus struct {
+	SubscriptionGroupIdentifier string                 `json:"subscriptionGroupIdentifier"`
+	OriginalTransactionId       string                 `json:"originalTransactionId"`
+	ProductId                   string                 `json:"productId"`
+	Status                      int32                  `json:"status"`
+	StatusText                  string                 `json:"statusText"`
+	Active                      bool                   `json:"active"`
+	Renewal                     *models.RenewalSummary `json:"renewal,omitempty"`
+}
+
+// SummarizeSubscriptionStatuses evaluates every transaction of every group Apple reports and returns one
+// status per group. A refund we recorded but Apple's status does not reflect yet still revokes access.
+func SummarizeSubscriptionStatuses(statuses *models.StatusResponse, now time.Time) ([]SubscriptionGroupStatus, error) {
+	groups := []SubscriptionGroupStatus{}
+	for _, group := range statuses.Data {
+		candidates := make([]SubscriptionGroupStatus, 0, len(group.LastTransactions))
+		for _, item := range group.LastTransactions {
+			status, err := evaluateSubscriptionStatus(group.SubscriptionGroupIdentifier, item, now)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, status)
+		}
+		if best := BestSubscriptionStatus(candidates); best != nil {
+			groups = append(groups, *best)
+		}
+	}
+	return groups, nil
+}
+
+// BestSubscriptionStatus picks the status granting the most access: active, then grace period, billing
+// retry, expired and revoked. The first one wins a tie. It returns nil for no statuses.
+func BestSubscriptionStatus(statuses []SubscriptionGroupStatus) *SubscriptionGroupStatus {
+	var best *SubscriptionGroupStatus
+	for i := range statuses {
+		if best == nil || subscriptionStatusRank(statuses[i].Status) > subscriptionStatusRank(best.Status) {
+			best = &statuses[i]
+		}
+	}
+	return best
+}
+
+// subscriptionStatusRank orders App Store status codes by how much access they grant
+func subscriptionStatusRank(status int32) int {
+	switch status {
+	case 1:
+		return 5
+	case 4:
+		return 4
+	case 3:
+		return 3
+	case 2:
+		return 2
+	case 5:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func evaluateSubscriptionStatus(groupIdentifier string, item models.LastTransactionsItem, now time.Time) (SubscriptionGroupStatus, error) {
+	transaction, err := utils.DecodeSignedTransactionInfo(item.SignedTransactionInfo)
+	if err != nil {
+		return SubscriptionGroupStatus{}, fmt.Errorf("%w: %v", ErrUnverifiedSubscriptionStatus, err)
+	}
+
+	var renewalInfo *models.JWSRenewalInfoDecodedPayload
+	if item.SignedRenewalInfo != "" {
+		renewalInfo, err = utils.DecodeSignedRenewalInfo(item.SignedRenewalInfo)
+		if err != nil {
+			return SubscriptionGroupStatus{}, fmt.Errorf("%w: %v", ErrUnverifiedSubscriptionStatus, err)
+		}
+	}
+
+	revoked, err := IsTransactionRevoked(transaction)
+	if err != nil {
+		return SubscriptionGroupStatus{}, err
+	}
+
+	state := subscriptionEntitlementState(item.Status, renewalInfo, revoked, now)
+	status := SubscriptionGroupStatus{
+		SubscriptionGroupIdentifier: groupIdentifier,
+		OriginalTransactionId:       item.OriginalTransactionId,
+		ProductId:                   transaction.ProductID,
+		Status:                      state.AppleStatus(),
+		StatusText:                  models.StatusText(state.AppleStatus()),
+		Active:                      state.HasAccess(),
+	}
+	if renewalInfo != nil {
+		status.Renewal = models.NewRenewalSummary(renewalInfo)
+	}
+	return status, nil
+}
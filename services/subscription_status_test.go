@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestBestSubscriptionStatus(t *testing.T) {
+	status := func(id string, code int32) SubscriptionGroupStatus {
+		return SubscriptionGroupStatus{OriginalTransactionId: id, Status: code}
+	}
+
+	tests := []struct {
+		name     string
+		statuses []SubscriptionGroupStatus
+		wantId   string
+	}{
+		{name: "none", statuses: nil},
+		{name: "active beats expired listed first", statuses: []SubscriptionGroupStatus{status("a", 2), status("b", 1)}, wantId: "b"},
+		{name: "grace period beats billing retry", statuses: []SubscriptionGroupStatus{status("a", 3), status("b", 4)}, wantId: "b"},
+		{name: "expired beats revoked", statuses: []SubscriptionGroupStatus{status("a", 5), status("b", 2)}, wantId: "b"},
+		{name: "first wins a tie", statuses: []SubscriptionGroupStatus{status("a", 1), status("b", 1)}, wantId: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best := BestSubscriptionStatus(tt.statuses)
+			var got string
+			if best != nil {
+				got = best.OriginalTransactionId
+			}
+			if got != tt.wantId {
+				t.Errorf("BestSubscriptionStatus() = %q, want %q", got, tt.wantId)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestNextSubscriptionState(t *testing.T) {
+	tests := []struct {
+		name             string
+		current          models.SubscriptionState
+		notificationType models.NotificationTypeV2
+		subtype          models.SubtypeV2
+		want             models.SubscriptionState
+		wantErr          error
+	}{
+		{
+			name:             "initial buy",
+			current:          models.SubscriptionStateNone,
+			notificationType: models.NotificationTypeV2Subscribed,
+			subtype:          models.SubTypeV2InitialBuy,
+			want:             models.SubscriptionStateActive,
+		},
+		{
+			name:             "failed renewal with grace period",
+			current:          models.SubscriptionStateActive,
+			notificationType: models.NotificationTypeV2DidFailToRenew,
+			subtype:          models.SubTypeV2GracePeriod,
+			want:             models.SubscriptionStateGracePeriod,
+		},
+		{
+			name:             "failed renewal without grace period",
+			current:          models.SubscriptionStateActive,
+			notificationType: models.NotificationTypeV2DidFailToRenew,
+			want:             models.SubscriptionStateBillingRetry,
+		},
+		{
+			name:             "grace period expired",
+			current:          models.SubscriptionStateGracePeriod,
+			notificationType: models.NotificationTypeV2GracePeriodExpired,
+			want:             models.SubscriptionStateBillingRetry,
+		},
+		{
+			name:             "billing recovery after expiry",
+			current:          models.SubscriptionStateExpired,
+			notificationType: models.NotificationTypeV2DidRenew,
+			subtype:          models.SubTypeV2BillingRecovery,
+			want:             models.SubscriptionStateActive,
+		},
+		{
+			name:             "refund",
+			current:          models.SubscriptionStateActive,
+			notificationType: models.NotificationTypeV2Refund,
+			want:             models.SubscriptionStateRefunded,
+		},
+		{
+			name:             "renewal extended on expired subscription",
+			current:          models.SubscriptionStateExpired,
+			notificationType: models.NotificationTypeV2RenewalExtended,
+			want:             models.SubscriptionStateExpired,
+			wantErr:          ErrInvalidSubscriptionTransition,
+		},
+		{
+			name:             "renew after refund",
+			current:          models.SubscriptionStateRefunded,
+			notificationType: models.NotificationTypeV2DidRenew,
+			want:             models.SubscriptionStateRefunded,
+			wantErr:          ErrInvalidSubscriptionTransition,
+		},
+		{
+			name:             "price increase keeps state",
+			current:          models.SubscriptionStateActive,
+			notificationType: models.NotificationTypeV2PriceIncrease,
+			subtype:          models.SubTypeV2Pending,
+			want:             models.SubscriptionStateActive,
+			wantErr:          errNoSubscriptionTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextSubscriptionState(tt.current, tt.notificationType, tt.subtype)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NextSubscriptionState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NextSubscriptionState() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySubscriptionTransition(t *testing.T) {
+	now := time.Now()
+	entitlement := &models.SubscriptionEntitlement{OriginalTransactionId: "1000"}
+
+	notifications := []*models.AppStoreNotification{
+		{NotificationUUID: "a", NotificationType: models.NotificationTypeV2Subscribed, SignedDate: 100,
+			Transaction: &models.JWSTransaction{ProductID: "monthly", ExpiresDate: 1000}},
+		{NotificationUUID: "b", NotificationType: models.NotificationTypeV2DidFailToRenew, Subtype: models.SubTypeV2GracePeriod, SignedDate: 300},
+		// Delivered out of order, signed before the last applied notification
+		{NotificationUUID: "c", NotificationType: models.NotificationTypeV2DidRenew, SignedDate: 200},
+	}
+	for _, n := range notifications {
+		if _, err := ApplySubscriptionTransition(entitlement, n, now); err != nil {
+			t.Fatalf("ApplySubscriptionTransition(%s) error = %v", n.NotificationUUID, err)
+		}
+	}
+
+	if entitlement.State != models.SubscriptionStateGracePeriod {
+		t.Errorf("State = %q, want %q", entitlement.State, models.SubscriptionStateGracePeriod)
+	}
+	if len(entitlement.History) != 2 {
+		t.Fatalf("len(History) = %d, want 2", len(entitlement.History))
+	}
+	if entitlement.ProductId != "monthly" || entitlement.ExpiresDate != 1000 {
+		t.Errorf("ProductId = %q, ExpiresDate = %d", entitlement.ProductId, entitlement.ExpiresDate)
+	}
+	if entitlement.LastNotificationUUID != "b" {
+		t.Errorf("LastNotificationUUID = %q, want %q", entitlement.LastNotificationUUID, "b")
+	}
+}
+
+func TestApplySubscriptionTransition_RefundReversed(t *testing.T) {
+	now := time.Now()
+	entitlement := &models.SubscriptionEntitlement{
+		OriginalTransactionId: "1000",
+		State:                 models.SubscriptionStateGracePeriod,
+		ExpiresDate:           now.Add(time.Hour).UnixMilli(),
+	}
+
+	refund := &models.AppStoreNotification{NotificationUUID: "a", NotificationType: models.NotificationTypeV2Refund, SignedDate: 100}
+	reversed := &models.AppStoreNotification{NotificationUUID: "b", NotificationType: models.NotificationTypeV2RefundReversed, SignedDate: 200}
+	for _, n := range []*models.AppStoreNotification{refund, reversed} {
+		if _, err := ApplySubscriptionTransition(entitlement, n, now); err != nil {
+			t.Fatalf("ApplySubscriptionTransition(%s) error = %v", n.NotificationUUID, err)
+		}
+	}
+
+	if entitlement.State != models.SubscriptionStateGracePeriod {
+		t.Errorf("State = %q, want %q", entitlement.State, models.SubscriptionStateGracePeriod)
+	}
+}
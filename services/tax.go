@@ -0,0 +1,116 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+)
+
+// TaxRates holds the tax included in the prices of each storefront
+type TaxRates struct {
+	storefronts map[string]float64
+}
+
+// NewTaxRates indexes rates by storefront. Rows with a negative rate are skipped.
+func NewTaxRates(rates []*models.TaxRate) *TaxRates {
+	t := &TaxRates{storefronts: map[string]float64{}}
+	for _, rate := range rates {
+		if rate.Rate < 0 {
+			continue
+		}
+		t.storefronts[rate.Storefront] = rate.Rate
+	}
+	return t
+}
+
+// Rate returns the tax rate of a storefront
+func (t *TaxRates) Rate(storefront string) (float64, bool) {
+	rate, ok := t.storefronts[storefront]
+	return rate, ok
+}
+
+// taxRates holds the rates loaded last, swapped whole on every reload
+var taxRates atomic.Pointer[TaxRates]
+
+// CurrentTaxRates returns the rates loaded last, empty before the first load
+func CurrentTaxRates() *TaxRates {
+	if t := taxRates.Load(); t != nil {
+		return t
+	}
+	return NewTaxRates(nil)
+}
+
+// ReloadTaxRates replaces the loaded rates with those of the taxRates collection
+func ReloadTaxRates() error {
+	rates, err := mongoRepo.GetTaxRates()
+	if err != nil {
+		return err
+	}
+	taxRates.Store(NewTaxRates(rates))
+	return nil
+}
+
+// ParseTaxRatesCSV reads rates from CSV with a storefront,rate header, such as
+//
+//	storefront,rate
+//	DEU,0.19
+func ParseTaxRatesCSV(r io.Reader) ([]*models.TaxRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax rates header: %w", err)
+	}
+	if strings.ToLower(strings.Join(header, ",")) != "storefront,rate" {
+		return nil, fmt.Errorf("tax rates header must be storefront,rate, got %s", strings.Join(header, ","))
+	}
+
+	rates := []*models.TaxRate{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record[0]) != 3 {
+			return nil, fmt.Errorf("line %d: storefront must be an ISO 3166-1 alpha-3 code", line)
+		}
+		rate, err := strconv.ParseFloat(record[1], 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("line %d: rate must be a non-negative number", line)
+		}
+		rates = append(rates, &models.TaxRate{
+			Storefront: strings.ToUpper(record[0]),
+			Rate:       rate,
+		})
+	}
+}
+
+// ImportTaxRatesFile upserts the rates of a CSV file into the taxRates collection
+func ImportTaxRatesFile(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	rates, err := ParseTaxRatesCSV(file)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tax rates file %s: %w", path, err)
+	}
+	return mongoRepo.SaveTaxRates(rates)
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTaxRatesCSV(t *testing.T) {
+	rates, err := ParseTaxRatesCSV(strings.NewReader("storefront,rate\ndeu,0.19\n USA, 0\n"))
+	if err != nil {
+		t.Fatalf("ParseTaxRatesCSV() error = %v", err)
+	}
+	if len(rates) != 2 || rates[0].Storefront != "DEU" || rates[0].Rate != 0.19 || rates[1].Storefront != "USA" {
+		t.Errorf("ParseTaxRatesCSV() = %+v", rates)
+	}
+
+	invalid := []string{
+		"country,vat\n",
+		"storefront,rate\nDE,0.19\n",
+		"storefront,rate\nDEU,-0.19\n",
+		"storefront,rate\nDEU\n",
+	}
+	for _, input := range invalid {
+		if _, err := ParseTaxRatesCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseTaxRatesCSV(%q) succeeded, want error", input)
+		}
+	}
+}
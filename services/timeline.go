@@ -0,0 +1,336 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"simvizlab-backend/models"
+	mongoRepo "simvizlab-backend/repository/mongo"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TimelineEventType tells which record a timeline event comes from
+type TimelineEventType string
+
+const (
+	TimelineTransaction        TimelineEventType = "transaction"
+	TimelineNotification       TimelineEventType = "notification"
+	TimelineRenewalInfo        TimelineEventType = "renewal_info"
+	TimelineRefund             TimelineEventType = "refund"
+	TimelineRefundReversed     TimelineEventType = "refund_reversed"
+	TimelineRenewalExtension   TimelineEventType = "renewal_extension"
+	TimelineSubscriptionStatus TimelineEventType = "subscription_status"
+)
+
+// TimelineEvent is one entry of a customer's purchase history. Time is in epoch milliseconds
+// and Data holds the record the event was taken from.
+type TimelineEvent struct {
+	Time                  int64             `json:"time"`
+	Type                  TimelineEventType `json:"type"`
+	OriginalTransactionId string            `json:"originalTransactionId,omitempty"`
+	TransactionId         string            `json:"transactionId,omitempty"`
+	Description           string            `json:"description"`
+	Data                  interface{}       `json:"data,omitempty"`
+}
+
+// UserTimeline is everything we know about a user's purchases, oldest first
+type UserTimeline struct {
+	UserId                 string           `json:"userId"`
+	OriginalTransactionIds []string         `json:"originalTransactionIds"`
+	Events                 []*TimelineEvent `json:"events"`
+}
+
+// timelineSources are the stored records a timeline is built from
+type timelineSources struct {
+	transactions  []*models.JWSTransaction
+	notifications []*models.AppStoreNotification
+	refunds       []*models.RefundRecord
+	extensions    []*models.RenewalExtension
+	statuses      []*models.TransactionApple
+}
+
+// GetUserTimeline merges the stored transactions, notifications, renewal infos, refunds and renewal-date
+// extensions of the user's original transactions into one chronological list of events
+func GetUserTimeline(user *models.User) (*UserTimeline, error) {
+	linked := timelineOriginalTransactionIds(user, nil)
+
+	var sources timelineSources
+	var err error
+	sources.transactions, err = mongoRepo.GetCustomerTransactions(linked, user.AppAccountToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+
+	// Purchases carrying the user's appAccountToken may belong to other original transactions
+	originalTransactionIds := timelineOriginalTransactionIds(user, sources.transactions)
+
+	timeline := &UserTimeline{UserId: user.ID.Hex(), OriginalTransactionIds: originalTransactionIds, Events: []*TimelineEvent{}}
+	if len(originalTransactionIds) == 0 {
+		return timeline, nil
+	}
+
+	if sources.notifications, err = mongoRepo.GetNotificationsByOriginalTransactions(originalTransactionIds); err != nil {
+		return nil, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+	if sources.refunds, err = mongoRepo.GetRefundsByOriginalTransactions(originalTransactionIds); err != nil {
+		return nil, fmt.Errorf("failed to fetch refunds: %w", err)
+	}
+	if sources.extensions, err = mongoRepo.GetRenewalExtensions(bson.M{"originalTransactionId": bson.M{"$in": originalTransactionIds}}, 0); err != nil {
+		return nil, fmt.Errorf("failed to fetch renewal extensions: %w", err)
+	}
+	if sources.statuses, err = mongoRepo.GetTransactionApples(originalTransactionIds); err != nil {
+		return nil, fmt.Errorf("failed to fetch subscription status: %w", err)
+	}
+
+	timeline.Events = buildTimeline(sources)
+	return timeline, nil
+}
+
+// timelineOriginalTransactionIds lists the original transaction linked to a user and those of their
+// stored transactions, never nil so a user without purchases queries and answers an empty list
+func timelineOriginalTransactionIds(user *models.User, transactions []*models.JWSTransaction) []string {
+	ids := []string{}
+	if user.OriginalTransactionId != "" {
+		ids = append(ids, user.OriginalTransactionId)
+	}
+	for _, transaction := range transactions {
+		if transaction.OriginalTransactionId != "" && !slices.Contains(ids, transaction.OriginalTransactionId) {
+			ids = append(ids, transaction.OriginalTransactionId)
+		}
+	}
+	return ids
+}
+
+// buildTimeline turns every source record into events and sorts them by time. Events at the same
+// time keep the order of their sources, so a purchase comes before the notification announcing it.
+func buildTimeline(sources timelineSources) []*TimelineEvent {
+	events := []*TimelineEvent{}
+	for _, transaction := range sources.transactions {
+		events = append(events, &TimelineEvent{
+			Time:                  transaction.PurchaseDate,
+			Type:                  TimelineTransaction,
+			OriginalTransactionId: transaction.OriginalTransactionId,
+			TransactionId:         transaction.TransactionID,
+			Description:           transactionDescription(transaction),
+			Data:                  transaction,
+		})
+	}
+
+	for _, notification := range sources.notifications {
+		events = append(events, &TimelineEvent{
+			Time:                  notification.SignedDate,
+			Type:                  TimelineNotification,
+			OriginalTransactionId: notification.OriginalTransactionId,
+			TransactionId:         notification.TransactionId,
+			Description:           notificationDescription(notification.NotificationType, notification.Subtype),
+			Data:                  notification,
+		})
+		if renewal := models.NewRenewalSummary(notification.RenewalInfo); renewal != nil {
+			events = append(events, &TimelineEvent{
+				Time:                  renewal.SignedDate,
+				Type:                  TimelineRenewalInfo,
+				OriginalTransactionId: notification.OriginalTransactionId,
+				Description:           renewalDescription(renewal),
+				Data:                  renewal,
+			})
+		}
+	}
+
+	for _, refund := range sources.refunds {
+		events = append(events, &TimelineEvent{
+			Time:                  refund.RevocationDate,
+			Type:                  TimelineRefund,
+			OriginalTransactionId: refund.OriginalTransactionId,
+			TransactionId:         refund.TransactionId,
+			Description:           fmt.Sprintf("%s %s: %s", refundVerb(refund.Transaction), productName(refund.ProductId), models.RevocationReasonText(refund.RevocationReason)),
+			Data:                  refund,
+		})
+		if refund.Reversed && refund.ReversedAt != nil {
+			events = append(events, &TimelineEvent{
+				Time:                  refund.ReversedAt.UnixMilli(),
+				Type:                  TimelineRefundReversed,
+				OriginalTransactionId: refund.OriginalTransactionId,
+				TransactionId:         refund.TransactionId,
+				Description:           fmt.Sprintf("Refund of %s reversed", productName(refund.ProductId)),
+				Data:                  refund,
+			})
+		}
+	}
+
+	for _, extension := range sources.extensions {
+		events = append(events, &TimelineEvent{
+			Time:                  extension.CreatedAt.UnixMilli(),
+			Type:                  TimelineRenewalExtension,
+			OriginalTransactionId: extension.OriginalTransactionId,
+			Description:           extensionDescription(extension),
+			Data:                  extension,
+		})
+	}
+
+	for _, status := range sources.statuses {
+		events = append(events, &TimelineEvent{
+			Time:                  status.UpdatedAt.UnixMilli(),
+			Type:                  TimelineSubscriptionStatus,
+			OriginalTransactionId: status.OriginalTransactionId,
+			Description:           "Subscription status: " + models.StatusText(status.Status),
+			Data:                  status,
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	return events
+}
+
+func productName(productId string) string {
+	if productId == "" {
+		return "unknown product"
+	}
+	return productId
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("2006-01-02 15:04 MST")
+}
+
+// transactionDescription summarises a purchase, such as "Renewed com.example.pro.monthly for 9.99 USD"
+func transactionDescription(transaction *models.JWSTransaction) string {
+	verb := "Purchased"
+	switch {
+	case transaction.OfferDiscountType == models.OfferDiscountTypeFreeTrial:
+		verb = "Started free trial of"
+	case transaction.TransactionReason == models.TransactionReasonRenewal:
+		verb = "Renewed"
+	}
+
+	description := fmt.Sprintf("%s %s", verb, productName(transaction.ProductID))
+	if transaction.Price > 0 && transaction.Currency != "" {
+		description += " for " + models.TransactionPrice(transaction).String()
+	}
+	if transaction.ExpiresDate > 0 {
+		description += ", expires " + formatMillis(transaction.ExpiresDate)
+	}
+	if transaction.RevocationDate > 0 {
+		description += " (revoked)"
+	}
+	return description
+}
+
+func refundVerb(transaction *models.JWSTransaction) string {
+	if transaction != nil && transaction.InAppOwnershipType == "FAMILY_SHARED" {
+		return "Family Sharing access revoked for"
+	}
+	return "Refunded"
+}
+
+// notificationDescription describes an App Store Server Notification by its type and subtype
+// https://developer.apple.com/documentation/appstoreservernotifications/notificationtype
+func notificationDescription(notificationType models.NotificationTypeV2, subtype models.SubtypeV2) string {
+	switch notificationType {
+	case models.NotificationTypeV2Subscribed:
+		if subtype == models.SubTypeV2Resubscribe {
+			return "Resubscribed"
+		}
+		return "Subscribed"
+	case models.NotificationTypeV2DidRenew:
+		if subtype == models.SubTypeV2BillingRecovery {
+			return "Renewed after a billing issue was resolved"
+		}
+		return "Renewed"
+	case models.NotificationTypeV2DidFailToRenew:
+		if subtype == models.SubTypeV2GracePeriod {
+			return "Renewal failed, in billing grace period"
+		}
+		return "Renewal failed, in billing retry"
+	case models.NotificationTypeV2GracePeriodExpired:
+		return "Billing grace period ended"
+	case models.NotificationTypeV2Expired:
+		switch subtype {
+		case models.SubTypeV2Voluntary:
+			return "Expired after the customer turned off auto-renew"
+		case models.SubTypeV2BillingRetry:
+			return "Expired after billing retry ended"
+		case models.SubTypeV2PriceIncrease:
+			return "Expired as the customer did not consent to a price increase"
+		}
+		return "Expired"
+	case models.NotificationTypeV2DidChangeRenewalStatus:
+		if subtype == models.SubTypeV2AutoRenewDisabled {
+			return "Auto-renew turned off"
+		}
+		return "Auto-renew turned on"
+	case models.NotificationTypeV2DidChangeRenewalPref:
+		switch subtype {
+		case models.SubTypeV2Upgrade:
+			return "Upgraded to another subscription, effective immediately"
+		case models.SubTypeV2Downgrade:
+			return "Downgraded to another subscription from the next renewal"
+		}
+		return "Cancelled a pending plan change"
+	case models.NotificationTypeV2PriceIncrease:
+		if subtype == models.SubTypeV2Accepted {
+			return "Price increase accepted"
+		}
+		return "Price increase pending the customer's consent"
+	case models.NotificationTypeV2OfferRedeemed:
+		return "Redeemed an offer"
+	case models.NotificationTypeV2Refund:
+		return "Refunded by Apple"
+	case models.NotificationTypeV2RefundDeclined:
+		return "Refund request declined by Apple"
+	case models.NotificationTypeV2RefundReversed:
+		return "Refund reversed by Apple"
+	case models.NotificationTypeV2ConsumptionRequest:
+		return "Refund requested, Apple asked for consumption information"
+	case models.NotificationTypeV2Revoke:
+		return "Family Sharing access revoked"
+	case models.NotificationTypeV2RenewalExtended:
+		return "Renewal date extended"
+	case models.NotificationTypeV2RenewalExtension:
+		return "Renewal-date extension for all subscribers progressed"
+	case models.NotificationTypeV2OneTimeCharge:
+		return "One-time purchase"
+	case models.NotificationTypeV2Test:
+		return "Test notification"
+	}
+
+	description := strings.ReplaceAll(string(notificationType), "_", " ")
+	if subtype != "" {
+		description += " (" + strings.ReplaceAll(string(subtype), "_", " ") + ")"
+	}
+	return description
+}
+
+// renewalDescription summarises decoded renewal info, such as "Auto-renew off: Customer canceled"
+func renewalDescription(renewal *models.RenewalSummary) string {
+	var description string
+	if renewal.WillAutoRenew {
+		description = "Auto-renew on for " + productName(renewal.AutoRenewProductId)
+		if renewal.RenewalDate > 0 {
+			description += ", renews " + formatMillis(renewal.RenewalDate)
+		}
+	} else {
+		description = "Auto-renew off"
+		if renewal.ExpirationIntentText != "" {
+			description += ": " + renewal.ExpirationIntentText
+		}
+	}
+	if renewal.GracePeriodExpiresDate > 0 {
+		description += ", grace period until " + formatMillis(renewal.GracePeriodExpiresDate)
+	}
+	if renewal.PriceIncreasePending {
+		description += ", price increase awaiting consent"
+	}
+	return description
+}
+
+func extensionDescription(extension *models.RenewalExtension) string {
+	description := fmt.Sprintf("Renewal date extension by %d days %s", extension.ExtendByDays, extension.Status)
+	if extension.LastError != "" {
+		description += ": " + extension.LastError
+	}
+	return description
+}
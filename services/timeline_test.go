@@ -0,0 +1,111 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"simvizlab-backend/models"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	purchased := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	reversedAt := purchased.Add(72 * time.Hour)
+	reason := int32(1)
+
+	sources := timelineSources{
+		transactions: []*models.JWSTransaction{
+			{TransactionID: "2", OriginalTransactionId: "1", ProductID: "pro.monthly", PurchaseDate: purchased.AddDate(0, 1, 0).UnixMilli(), TransactionReason: models.TransactionReasonRenewal, Price: 9990, Currency: "USD"},
+			{TransactionID: "1", OriginalTransactionId: "1", ProductID: "pro.monthly", PurchaseDate: purchased.UnixMilli(), OfferDiscountType: models.OfferDiscountTypeFreeTrial},
+		},
+		notifications: []*models.AppStoreNotification{
+			{
+				NotificationType:      models.NotificationTypeV2Subscribed,
+				Subtype:               models.SubTypeV2InitialBuy,
+				OriginalTransactionId: "1",
+				TransactionId:         "1",
+				SignedDate:            purchased.Add(time.Minute).UnixMilli(),
+				RenewalInfo:           &models.JWSRenewalInfoDecodedPayload{AutoRenewStatus: 0, ExpirationIntent: 1, SignedDate: purchased.Add(time.Minute).UnixMilli()},
+			},
+		},
+		refunds: []*models.RefundRecord{
+			{TransactionId: "1", OriginalTransactionId: "1", ProductId: "pro.monthly", RevocationDate: purchased.Add(48 * time.Hour).UnixMilli(), RevocationReason: &reason, Reversed: true, ReversedAt: &reversedAt},
+		},
+		statuses: []*models.TransactionApple{
+			{OriginalTransactionId: "1", Status: 2, UpdatedAt: purchased.AddDate(0, 2, 0)},
+		},
+	}
+
+	want := []struct {
+		eventType   TimelineEventType
+		description string
+	}{
+		{TimelineTransaction, "Started free trial of pro.monthly"},
+		{TimelineNotification, "Subscribed"},
+		{TimelineRenewalInfo, "Auto-renew off: Customer canceled"},
+		{TimelineRefund, "Refunded pro.monthly: Refunded for an actual or perceived issue within the app"},
+		{TimelineRefundReversed, "Refund of pro.monthly reversed"},
+		{TimelineTransaction, "Renewed pro.monthly for 9.99 USD"},
+		{TimelineSubscriptionStatus, "Subscription status: Expired"},
+	}
+
+	events := buildTimeline(sources)
+	if len(events) != len(want) {
+		t.Fatalf("buildTimeline() returned %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		if events[i].Type != w.eventType || events[i].Description != w.description {
+			t.Errorf("event %d = %s %q, want %s %q", i, events[i].Type, events[i].Description, w.eventType, w.description)
+		}
+		if i > 0 && events[i].Time < events[i-1].Time {
+			t.Errorf("event %d is earlier than event %d", i, i-1)
+		}
+	}
+}
+
+func TestTimelineOriginalTransactionIds(t *testing.T) {
+	byToken := []*models.JWSTransaction{
+		{TransactionID: "12", OriginalTransactionId: "10"},
+		{TransactionID: "11", OriginalTransactionId: "10"},
+		{TransactionID: "21", OriginalTransactionId: "20"},
+	}
+
+	tests := []struct {
+		name         string
+		user         *models.User
+		transactions []*models.JWSTransaction
+		want         []string
+	}{
+		{name: "no linked transaction", user: &models.User{}, want: []string{}},
+		{name: "linked transaction", user: &models.User{OriginalTransactionId: "20"}, want: []string{"20"}},
+		{name: "no linked transaction, purchases by token", user: &models.User{}, transactions: byToken, want: []string{"10", "20"}},
+		{name: "linked transaction first", user: &models.User{OriginalTransactionId: "20"}, transactions: byToken, want: []string{"20", "10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := timelineOriginalTransactionIds(tt.user, tt.transactions)
+			if got == nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("timelineOriginalTransactionIds() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationDescription(t *testing.T) {
+	tests := []struct {
+		notificationType models.NotificationTypeV2
+		subtype          models.SubtypeV2
+		want             string
+	}{
+		{models.NotificationTypeV2Subscribed, models.SubTypeV2Resubscribe, "Resubscribed"},
+		{models.NotificationTypeV2DidFailToRenew, models.SubTypeV2GracePeriod, "Renewal failed, in billing grace period"},
+		{models.NotificationTypeV2Expired, models.SubTypeV2Voluntary, "Expired after the customer turned off auto-renew"},
+		{models.NotificationTypeV2DidChangeRenewalStatus, models.SubTypeV2AutoRenewDisabled, "Auto-renew turned off"},
+		{models.NotificationTypeV2ExternalPurchaseToken, "UNREPORTED", "EXTERNAL PURCHASE TOKEN (UNREPORTED)"},
+	}
+	for _, tt := range tests {
+		if got := notificationDescription(tt.notificationType, tt.subtype); got != tt.want {
+			t.Errorf("notificationDescription(%s, %s) = %q, want %q", tt.notificationType, tt.subtype, got, tt.want)
+		}
+	}
+}
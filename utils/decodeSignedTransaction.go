@@ -1,31 +1,45 @@
 package utils
 
 import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"strings"
+	"os"
+	"sync"
+
+	"simvizlab-backend/config"
+	"simvizlab-backend/models"
 )
 
-func DecodeSignedTransactionInfo(jws string) (map[string]interface{}, error) {
-	parts := strings.Split(jws, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid JWS format")
-	}
+var (
+	verifier     *models.SignedDataVerifier
+	verifierOnce sync.Once
+)
 
-	payload := parts[1]
+// signedDataVerifier trusts Apple Root CA - G3, or the configured test roots, and expects our bundle in the configured environments
+func signedDataVerifier() *models.SignedDataVerifier {
+	verifierOnce.Do(func() {
+		environments := []models.Environment{models.Production}
+		if config.AppStoreSandbox() {
+			environments = []models.Environment{models.Sandbox}
+		} else if config.AppStoreSandboxFallback() {
+			environments = append(environments, models.Sandbox)
+		}
+		trustedRoots, _ := config.AppStoreTrustedRoots()
+		verifier = models.NewSignedDataVerifier(trustedRoots, os.Getenv("APPSTORE_BUNDLE_ID"), environments...)
+	})
+	return verifier
+}
 
-	// Base64URL decode
-	decoded, err := base64.RawURLEncoding.DecodeString(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode payload: %v", err)
-	}
+// DecodeSignedTransactionInfo verifies a signedTransactionInfo JWS and decodes its payload.
+// Failures wrap one of the models.ErrVerification* errors.
+func DecodeSignedTransactionInfo(jws string) (*models.JWSTransaction, error) {
+	return signedDataVerifier().VerifyTransaction(jws)
+}
 
-	// Unmarshal JSON
-	var result map[string]interface{}
-	if err := json.Unmarshal(decoded, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
-	}
+// DecodeSignedRenewalInfo verifies a signedRenewalInfo JWS and decodes its payload
+func DecodeSignedRenewalInfo(jws string) (*models.JWSRenewalInfoDecodedPayload, error) {
+	return signedDataVerifier().VerifyRenewalInfo(jws)
+}
 
-	return result, nil
+// DecodeSignedNotification verifies the signedPayload of an App Store Server Notification and decodes it
+func DecodeSignedNotification(jws string) (*models.NotificationPayload, error) {
+	return signedDataVerifier().VerifyNotification(jws)
 }